@@ -3,6 +3,10 @@ package authentication
 import (
 	"cloud-solutions-api/models"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
@@ -30,6 +34,21 @@ func CheckPasswordHash(password, passwordHash string) bool {
 	return err == nil
 }
 
+// GenerateShareToken creates a random token for a public share link. Only its hash is stored.
+func GenerateShareToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// HashShareToken hashes a share token so that it can be looked up without being stored.
+func HashShareToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
 func GetCurrentUsername(c echo.Context) (string, error) {
 	user := c.Get("user")
 	token, ok := user.(*jwt.Token)
@@ -0,0 +1,127 @@
+package chat
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/lib/pq"
+)
+
+// EventChannel is the Postgres notification channel chat events are published on.
+const EventChannel = "chat_events"
+
+// subscriberBufferSize is how many events a subscriber can lag behind before it is dropped.
+const subscriberBufferSize = 64
+
+// Broker delivers chat events to the subscribers of this process. Events are published with
+// NOTIFY on EventChannel, usually within the transaction making the change so they are only sent
+// once it commits, and the broker LISTENs on the channel, so an event published by any replica
+// reaches the subscribers of every replica.
+type Broker struct {
+	listener    *pq.Listener
+	mutex       sync.Mutex
+	subscribers map[int32]map[chan Event]struct{}
+}
+
+// NewBroker connects to the database and starts listening for chat events.
+func NewBroker(connStr string) (*Broker, error) {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Errorf("chat event listener: %s", err)
+		}
+	})
+	if err := listener.Listen(EventChannel); err != nil {
+		if closeErr := listener.Close(); closeErr != nil {
+			log.Error(closeErr)
+		}
+		return nil, err
+	}
+
+	broker := &Broker{listener: listener, subscribers: map[int32]map[chan Event]struct{}{}}
+	go broker.run()
+	return broker, nil
+}
+
+// run dispatches notifications until the listener is closed. After the connection was lost,
+// subscribers may have missed events and are told to resync.
+func (b *Broker) run() {
+	for notification := range b.listener.Notify {
+		if notification == nil {
+			b.broadcastResync()
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+			log.Errorf("invalid chat event: %s", err)
+			continue
+		}
+		b.dispatch(event)
+	}
+}
+
+// Subscribe returns the events of a chat and a function to call once they are no longer needed.
+// The channel is closed when the subscriber lags too far behind, the events it missed are lost.
+func (b *Broker) Subscribe(chatID int32) (<-chan Event, func()) {
+	events := make(chan Event, subscriberBufferSize)
+
+	b.mutex.Lock()
+	if b.subscribers[chatID] == nil {
+		b.subscribers[chatID] = map[chan Event]struct{}{}
+	}
+	b.subscribers[chatID][events] = struct{}{}
+	b.mutex.Unlock()
+
+	unsubscribe := func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		b.remove(chatID, events)
+	}
+	return events, unsubscribe
+}
+
+// remove drops a subscriber and closes its channel, unless it was already dropped. The mutex must
+// be held.
+func (b *Broker) remove(chatID int32, events chan Event) {
+	if _, ok := b.subscribers[chatID][events]; !ok {
+		return
+	}
+	delete(b.subscribers[chatID], events)
+	if len(b.subscribers[chatID]) == 0 {
+		delete(b.subscribers, chatID)
+	}
+	close(events)
+}
+
+func (b *Broker) dispatch(event Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for events := range b.subscribers[event.ChatID] {
+		select {
+		case events <- event:
+		default:
+			b.remove(event.ChatID, events)
+		}
+	}
+}
+
+func (b *Broker) broadcastResync() {
+	b.mutex.Lock()
+	chatIDs := make([]int32, 0, len(b.subscribers))
+	for chatID := range b.subscribers {
+		chatIDs = append(chatIDs, chatID)
+	}
+	b.mutex.Unlock()
+
+	for _, chatID := range chatIDs {
+		b.dispatch(Event{Type: Resync, ChatID: chatID})
+	}
+}
+
+// Close stops listening for chat events.
+func (b *Broker) Close() error {
+	return b.listener.Close()
+}
@@ -0,0 +1,117 @@
+package chat
+
+import (
+	"cloud-solutions-api/models"
+	"encoding/json"
+)
+
+type EventType string
+
+const (
+	// MessageAppended carries a message added to a chat.
+	MessageAppended EventType = "message-appended"
+	// MessagesDeleted carries the IDs of messages removed from a chat.
+	MessagesDeleted EventType = "messages-deleted"
+	// BranchChanged carries the new active message of a chat, whose conversation should be loaded
+	// again.
+	BranchChanged EventType = "branch-changed"
+	// ChatUpdated carries a chat whose title, pinned or archived state changed.
+	ChatUpdated EventType = "chat-updated"
+	// TokenDelta carries a piece of an answer the assistant is still writing.
+	TokenDelta EventType = "token-delta"
+	// ReadState carries whether a chat has unread messages.
+	ReadState EventType = "read-state"
+	// Processing carries whether the assistant is working on an answer.
+	Processing EventType = "processing"
+	// Resync tells subscribers events may have been missed and the chat should be loaded again.
+	Resync EventType = "resync"
+)
+
+// maxEventPayloadSize keeps events under the 8000 bytes Postgres allows in a notification.
+const maxEventPayloadSize = 7900
+
+// Event is something that happened to a chat, sent to the clients following the chat.
+type Event struct {
+	Type   EventType `json:"type"`
+	ChatID int32     `json:"chatId"`
+	// MessageID is the message a message-appended event is about. It lets subscribers load the
+	// message when it was too large to be sent along.
+	MessageID string          `json:"messageId,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+type MessagesDeletedData struct {
+	MessageIDs []string `json:"messageIds"`
+}
+
+type BranchChangedData struct {
+	ActiveMessageID string `json:"activeMessageId"`
+}
+
+type TokenDeltaData struct {
+	ReplyID string `json:"replyId"`
+	Delta   string `json:"delta"`
+}
+
+type ReadStateData struct {
+	Unread bool `json:"unread"`
+}
+
+type ProcessingData struct {
+	Processing bool `json:"processing"`
+}
+
+func newEvent(eventType EventType, chatID int32, data any) (Event, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: eventType, ChatID: chatID, Data: encoded}, nil
+}
+
+func NewMessageAppendedEvent(chatID int32, message models.Message) (Event, error) {
+	event, err := newEvent(MessageAppended, chatID, message)
+	event.MessageID = message.ID
+	return event, err
+}
+
+func NewMessagesDeletedEvent(chatID int32, messageIDs []string) (Event, error) {
+	return newEvent(MessagesDeleted, chatID, MessagesDeletedData{MessageIDs: messageIDs})
+}
+
+func NewBranchChangedEvent(chatID int32, activeMessageID string) (Event, error) {
+	return newEvent(BranchChanged, chatID, BranchChangedData{ActiveMessageID: activeMessageID})
+}
+
+func NewChatUpdatedEvent(updatedChat models.Chat) (Event, error) {
+	return newEvent(ChatUpdated, updatedChat.ID, updatedChat)
+}
+
+func NewTokenDeltaEvent(chatID int32, replyID string, delta string) (Event, error) {
+	return newEvent(TokenDelta, chatID, TokenDeltaData{ReplyID: replyID, Delta: delta})
+}
+
+func NewReadStateEvent(chatID int32, unread bool) (Event, error) {
+	return newEvent(ReadState, chatID, ReadStateData{Unread: unread})
+}
+
+func NewProcessingEvent(chatID int32, processing bool) (Event, error) {
+	return newEvent(Processing, chatID, ProcessingData{Processing: processing})
+}
+
+// EventPayload encodes an event as a notification payload. The data of events too large for a
+// notification is left out, subscribers load it themselves.
+func EventPayload(event Event) (string, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) > maxEventPayloadSize {
+		event.Data = nil
+		payload, err = json.Marshal(event)
+		if err != nil {
+			return "", err
+		}
+	}
+	return string(payload), nil
+}
@@ -0,0 +1,101 @@
+package chat
+
+import (
+	"cloud-solutions-api/models"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		newEvent func() (Event, error)
+		wantType EventType
+		wantData string
+	}{
+		{
+			name:     "messages deleted",
+			newEvent: func() (Event, error) { return NewMessagesDeletedEvent(3, []string{"a", "b"}) },
+			wantType: MessagesDeleted,
+			wantData: `{"messageIds":["a","b"]}`,
+		},
+		{
+			name:     "branch changed",
+			newEvent: func() (Event, error) { return NewBranchChangedEvent(3, "m-1") },
+			wantType: BranchChanged,
+			wantData: `{"activeMessageId":"m-1"}`,
+		},
+		{
+			name:     "branch changed to an empty chat",
+			newEvent: func() (Event, error) { return NewBranchChangedEvent(3, "") },
+			wantType: BranchChanged,
+			wantData: `{"activeMessageId":""}`,
+		},
+		{
+			name:     "read state",
+			newEvent: func() (Event, error) { return NewReadStateEvent(3, true) },
+			wantType: ReadState,
+			wantData: `{"unread":true}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := tt.newEvent()
+			if err != nil {
+				t.Fatalf("creating event: %v", err)
+			}
+			if event.Type != tt.wantType || event.ChatID != 3 || string(event.Data) != tt.wantData {
+				t.Errorf("event = %s %d %s, want %s 3 %s", event.Type, event.ChatID, event.Data, tt.wantType, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestNewMessageAppendedEvent(t *testing.T) {
+	event, err := NewMessageAppendedEvent(3, models.Message{ID: "m-1", Sender: models.User, Text: "Hi"})
+	if err != nil {
+		t.Fatalf("NewMessageAppendedEvent() error = %v", err)
+	}
+	if event.Type != MessageAppended || event.MessageID != "m-1" {
+		t.Errorf("event = %s about %q, want %s about m-1", event.Type, event.MessageID, MessageAppended)
+	}
+	var message models.Message
+	if err := json.Unmarshal(event.Data, &message); err != nil || message.Text != "Hi" {
+		t.Errorf("event data = %s, %v", event.Data, err)
+	}
+}
+
+func TestEventPayload(t *testing.T) {
+	small, err := NewMessageAppendedEvent(3, models.Message{ID: "m-1", Text: "Hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := EventPayload(small)
+	if err != nil {
+		t.Fatalf("EventPayload() error = %v", err)
+	}
+	var decoded Event
+	if err := json.Unmarshal([]byte(payload), &decoded); err != nil || decoded.Data == nil {
+		t.Errorf("EventPayload() = %s, want the data kept", payload)
+	}
+
+	large, err := NewMessageAppendedEvent(3, models.Message{ID: "m-2", Text: strings.Repeat("a", maxEventPayloadSize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err = EventPayload(large)
+	if err != nil {
+		t.Fatalf("EventPayload() error = %v", err)
+	}
+	if len(payload) > maxEventPayloadSize {
+		t.Errorf("EventPayload() is %d bytes, more than %d", len(payload), maxEventPayloadSize)
+	}
+	decoded = Event{}
+	if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+	if decoded.Data != nil || decoded.MessageID != "m-2" || decoded.Type != MessageAppended {
+		t.Errorf("EventPayload() = %s, want the data left out and the message ID kept", payload)
+	}
+}
@@ -0,0 +1,24 @@
+package chat
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDerivedTitleLength is the number of characters a title derived from a message is cut to.
+const maxDerivedTitleLength = 80
+
+// TitleFromMessage derives a title for a chat from the first message of the user: its text on a
+// single line, cut at a word boundary when it is too long.
+func TitleFromMessage(text string) string {
+	title := strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(title) <= maxDerivedTitleLength {
+		return title
+	}
+
+	title = string([]rune(title)[:maxDerivedTitleLength])
+	if i := strings.LastIndex(title, " "); i > len(title)/2 {
+		title = title[:i]
+	}
+	return title + "…"
+}
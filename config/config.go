@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"github.com/joho/godotenv"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -19,6 +22,43 @@ type Config struct {
 	Secret                string
 	ProtocolPrefix        string
 	Port                  string
+	DocxHeadersAndFooters bool
+	DocxFootnotes         bool
+	TrashRetention        time.Duration
+	UsageLimits           UsageLimits
+	BlobOrphanGracePeriod time.Duration
+	BlobOrphanDelete      bool
+	EncryptionKeyFile     string
+	InternalAPIToken      string
+	AllowedOrigins        []string
+}
+
+// redacted replaces the secrets of the configuration when it is printed.
+const redacted = "[redacted]"
+
+// String prints the configuration with its secrets redacted.
+func (c Config) String() string {
+	type plainConfig Config
+	printed := plainConfig(c)
+	for _, secret := range []*string{
+		&printed.DbPassword,
+		&printed.Secret,
+		&printed.EncryptionKeyFile,
+		&printed.InternalAPIToken,
+	} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return fmt.Sprintf("%+v", printed)
+}
+
+// UsageLimits are the limits of the plan accounts are on. A limit of zero means unlimited.
+type UsageLimits struct {
+	MaxDocuments      int64
+	MaxStorageBytes   int64
+	MaxChats          int64
+	MaxMessagesPerDay int64
 }
 
 var config *Config
@@ -47,8 +87,64 @@ func GetConfig() *Config {
 	if config.Port == "" {
 		config.Port = "80"
 	}
+	config.DocxHeadersAndFooters = getEnvBool("DOCX_INCLUDE_HEADERS_FOOTERS", false)
+	config.DocxFootnotes = getEnvBool("DOCX_INCLUDE_FOOTNOTES", true)
+	config.TrashRetention = time.Duration(getEnvInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour
+	config.BlobOrphanGracePeriod = time.Duration(getEnvInt("BLOB_ORPHAN_GRACE_HOURS", 24)) * time.Hour
+	config.BlobOrphanDelete = getEnvBool("BLOB_ORPHAN_DELETE", false)
+	config.EncryptionKeyFile = os.Getenv("ENCRYPTION_KEYFILE")
+	config.InternalAPIToken = os.Getenv("INTERNAL_API_TOKEN")
+	config.AllowedOrigins = getEnvList("ALLOWED_ORIGINS", []string{"*"})
+	config.UsageLimits = UsageLimits{
+		MaxDocuments:      getEnvInt64("QUOTA_MAX_DOCUMENTS", 0),
+		MaxStorageBytes:   getEnvInt64("QUOTA_MAX_STORAGE_BYTES", 0),
+		MaxChats:          getEnvInt64("QUOTA_MAX_CHATS", 0),
+		MaxMessagesPerDay: getEnvInt64("QUOTA_MAX_MESSAGES_PER_DAY", 0),
+	}
 
 	fmt.Println(config)
 
 	return config
 }
+
+// getEnvBool reads a boolean environment variable, returning fallback when it is unset or invalid.
+func getEnvBool(name string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvInt reads an integer environment variable, returning fallback when it is unset or invalid.
+func getEnvInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvInt64 reads a 64-bit integer environment variable, returning fallback when it is unset or invalid.
+func getEnvInt64(name string, fallback int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(name), 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList reads a comma separated environment variable, returning fallback when it is unset or
+// empty.
+func getEnvList(name string, fallback []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestConfigStringRedactsSecrets(t *testing.T) {
+	configuration := &Config{
+		DbHost:            "db.internal",
+		DbPassword:        "db-password",
+		Secret:            "jwt-secret",
+		EncryptionKeyFile: "/secrets/keys.json",
+		InternalAPIToken:  "internal-token",
+	}
+
+	printed := fmt.Sprint(configuration)
+	for _, secret := range []string{"db-password", "jwt-secret", "/secrets/keys.json", "internal-token"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("printed configuration %q contains %q", printed, secret)
+		}
+	}
+	if !strings.Contains(printed, "DbHost:db.internal") || !strings.Contains(printed, "Secret:"+redacted) {
+		t.Errorf("printed configuration %q lacks its settings", printed)
+	}
+	if configuration.Secret != "jwt-secret" {
+		t.Error("printing the configuration changed it")
+	}
+}
+
+func TestGetEnvList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{value: "", want: []string{"*"}},
+		{value: " , ", want: []string{"*"}},
+		{value: "https://a.example.com", want: []string{"https://a.example.com"}},
+		{value: "https://a.example.com, https://b.example.com,", want: []string{"https://a.example.com", "https://b.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_LIST", tt.value)
+		if got := getEnvList("TEST_LIST", []string{"*"}); !slices.Equal(got, tt.want) {
+			t.Errorf("getEnvList() with %q = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
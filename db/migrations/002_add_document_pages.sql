@@ -0,0 +1,11 @@
+ALTER TABLE documents
+    ADD COLUMN page_count      INTEGER,
+    ADD COLUMN source_metadata JSONB;
+
+CREATE TABLE document_pages
+(
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    page_number INTEGER NOT NULL,
+    text        TEXT    NOT NULL,
+    PRIMARY KEY (document_id, page_number)
+);
@@ -0,0 +1,31 @@
+ALTER TABLE documents
+    ADD COLUMN description     TEXT,
+    ADD COLUMN file_name       TEXT,
+    ADD COLUMN size_bytes      BIGINT,
+    ADD COLUMN content_hash    TEXT,
+    ADD COLUMN current_version INTEGER NOT NULL DEFAULT 1;
+
+UPDATE documents
+SET file_name = name;
+
+ALTER TABLE documents
+    ALTER COLUMN file_name SET NOT NULL;
+
+CREATE TABLE document_versions
+(
+    id             SERIAL PRIMARY KEY,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    document_id    INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    version_number INTEGER NOT NULL,
+    file_name      TEXT    NOT NULL,
+    file_path      TEXT,
+    text           TEXT,
+    content_hash   TEXT,
+    size_bytes     BIGINT,
+    UNIQUE (document_id, version_number)
+);
+
+-- Every existing document becomes the first version of itself.
+INSERT INTO document_versions (created_at, document_id, version_number, file_name, file_path, text)
+SELECT created_at, id, 1, name, file_path, text
+FROM documents;
@@ -0,0 +1,25 @@
+CREATE TABLE collections
+(
+    id         SERIAL PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    name       TEXT    NOT NULL,
+    account_id INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    parent_id  INTEGER REFERENCES collections (id) ON DELETE CASCADE
+);
+
+CREATE INDEX collections_parent_id_idx ON collections (parent_id);
+
+CREATE TABLE collection_documents
+(
+    collection_id INTEGER NOT NULL REFERENCES collections (id) ON DELETE CASCADE,
+    document_id   INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    added_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, document_id)
+);
+
+CREATE INDEX collection_documents_document_id_idx ON collection_documents (document_id);
+
+ALTER TABLE chats
+    ADD COLUMN collection_id INTEGER REFERENCES collections (id) ON DELETE SET NULL;
+
+CREATE INDEX documents_search_idx ON documents USING GIN (to_tsvector('simple', name || ' ' || COALESCE(text, '')));
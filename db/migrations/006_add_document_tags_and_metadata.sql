@@ -0,0 +1,13 @@
+ALTER TABLE documents
+    ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX documents_metadata_idx ON documents USING GIN (metadata);
+
+CREATE TABLE document_tags
+(
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    tag         TEXT    NOT NULL,
+    PRIMARY KEY (document_id, tag)
+);
+
+CREATE INDEX document_tags_tag_idx ON document_tags (tag);
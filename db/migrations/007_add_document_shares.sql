@@ -0,0 +1,10 @@
+CREATE TABLE document_shares
+(
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    account_id  INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    permission  TEXT    NOT NULL CHECK (permission IN ('viewer', 'editor')),
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (document_id, account_id)
+);
+
+CREATE INDEX document_shares_account_id_idx ON document_shares (account_id);
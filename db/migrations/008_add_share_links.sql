@@ -0,0 +1,14 @@
+CREATE TABLE share_links
+(
+    id             SERIAL PRIMARY KEY,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    token_hash     TEXT    NOT NULL UNIQUE,
+    account_id     INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    document_id    INTEGER REFERENCES documents (id) ON DELETE CASCADE,
+    chat_id        INTEGER REFERENCES chats (id) ON DELETE CASCADE,
+    expires_at     TIMESTAMP,
+    password_hash  TEXT,
+    allow_download BOOLEAN NOT NULL DEFAULT false,
+    revoked_at     TIMESTAMP,
+    CHECK ((document_id IS NULL) <> (chat_id IS NULL))
+);
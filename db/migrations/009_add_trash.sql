@@ -0,0 +1,8 @@
+ALTER TABLE documents
+    ADD COLUMN deleted_at TIMESTAMP;
+
+ALTER TABLE chats
+    ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX documents_deleted_at_idx ON documents (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX chats_deleted_at_idx ON chats (deleted_at) WHERE deleted_at IS NOT NULL;
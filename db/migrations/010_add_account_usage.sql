@@ -0,0 +1,27 @@
+CREATE TABLE account_usage
+(
+    account_id     INTEGER PRIMARY KEY REFERENCES accounts (id) ON DELETE CASCADE,
+    document_count INTEGER NOT NULL DEFAULT 0,
+    stored_bytes   BIGINT  NOT NULL DEFAULT 0,
+    chat_count     INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE account_message_usage
+(
+    account_id    INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    day           DATE    NOT NULL,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (account_id, day)
+);
+
+-- Versions restored from an earlier one share its file, so each file is counted once
+INSERT INTO account_usage (account_id, document_count, stored_bytes, chat_count)
+SELECT accounts.id,
+       (SELECT COUNT(*) FROM documents WHERE documents.account_id = accounts.id),
+       (SELECT COALESCE(SUM(files.size_bytes), 0)
+        FROM (SELECT DISTINCT document_versions.file_path, document_versions.size_bytes
+              FROM document_versions
+                       JOIN documents ON documents.id = document_versions.document_id
+              WHERE documents.account_id = accounts.id) files),
+       (SELECT COUNT(*) FROM chats WHERE chats.account_id = accounts.id)
+FROM accounts;
@@ -0,0 +1,10 @@
+CREATE TABLE pending_blob_deletions
+(
+    id              SERIAL PRIMARY KEY,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    object_name     TEXT    NOT NULL UNIQUE,
+    is_prefix       BOOLEAN NOT NULL DEFAULT false,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    last_attempt_at TIMESTAMP
+);
@@ -0,0 +1,7 @@
+-- Documents stored with envelope encryption keep the key of their files wrapped by a master key,
+-- along with the ID of that master key.
+ALTER TABLE documents
+    ADD COLUMN data_key    BYTEA,
+    ADD COLUMN data_key_id TEXT;
+
+CREATE INDEX documents_data_key_id_idx ON documents (data_key_id) WHERE data_key IS NOT NULL;
@@ -0,0 +1,8 @@
+-- Replies of the assistant already added to a chat, so a redelivered reply is not added twice
+CREATE TABLE chat_replies
+(
+    reply_id   TEXT    NOT NULL,
+    chat_id    INTEGER NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, reply_id)
+);
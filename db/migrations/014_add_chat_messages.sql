@@ -0,0 +1,36 @@
+-- Messages move from the messages array of chats to their own table. Sequence numbers order the
+-- messages of a chat, and last_message_sequence hands them out: incrementing it locks the chat, so
+-- concurrent messages never get the same number.
+-- The messages column is no longer written but kept, so the previous release still reads the chats
+-- if it is rolled back to; it is dropped by a later migration.
+CREATE TABLE chat_messages
+(
+    id         UUID PRIMARY KEY   DEFAULT gen_random_uuid(),
+    chat_id    INTEGER   NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    sequence   INTEGER   NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sender     TEXT      NOT NULL,
+    text       TEXT      NOT NULL,
+    UNIQUE (chat_id, sequence)
+);
+
+ALTER TABLE chats
+    ADD COLUMN last_message_sequence INTEGER NOT NULL DEFAULT 0;
+
+-- Copied messages get IDs derived from their chat and position rather than random ones, so they
+-- are the same wherever the migration runs.
+INSERT INTO chat_messages (id, chat_id, sequence, created_at, sender, text)
+SELECT md5('chat-message:' || chats.id || ':' || message.position)::UUID,
+       chats.id,
+       message.position,
+       COALESCE((message.value ->> 'timestamp')::TIMESTAMPTZ::TIMESTAMP, chats.created_at, CURRENT_TIMESTAMP),
+       COALESCE(message.value ->> 'sender', 'user'),
+       COALESCE(message.value ->> 'text', '')
+FROM chats,
+     jsonb_array_elements(CASE WHEN jsonb_typeof(chats.messages) = 'array' THEN chats.messages ELSE '[]' END)
+         WITH ORDINALITY AS message(value, position);
+
+UPDATE chats
+SET last_message_sequence = counts.message_count
+FROM (SELECT chat_id, MAX(sequence) AS message_count FROM chat_messages GROUP BY chat_id) counts
+WHERE chats.id = counts.chat_id;
@@ -0,0 +1,14 @@
+-- Messages form a tree: each message answers or follows its parent, and messages sharing a parent
+-- are branches of the conversation. Edited messages and regenerated answers are added next to the
+-- original ones, which are kept. The conversation shown and sent to the assistant is the branch
+-- leading to the latest message.
+ALTER TABLE chat_messages
+    ADD COLUMN parent_id UUID REFERENCES chat_messages (id) ON DELETE CASCADE;
+
+CREATE INDEX chat_messages_parent_id_idx ON chat_messages (parent_id);
+
+UPDATE chat_messages
+SET parent_id = ordered.previous_id
+FROM (SELECT id, LAG(id) OVER (PARTITION BY chat_id ORDER BY sequence) AS previous_id
+      FROM chat_messages) ordered
+WHERE chat_messages.id = ordered.id;
@@ -0,0 +1,11 @@
+-- The chat tracks the leaf of the branch being followed, the messages from the root to that leaf are
+-- the conversation shown and sent to the assistant.
+ALTER TABLE chats
+    ADD COLUMN active_message_id UUID REFERENCES chat_messages (id) ON DELETE SET NULL;
+
+UPDATE chats
+SET active_message_id = (SELECT id
+                         FROM chat_messages
+                         WHERE chat_id = chats.id
+                         ORDER BY sequence DESC
+                         LIMIT 1);
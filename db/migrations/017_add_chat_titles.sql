@@ -0,0 +1,22 @@
+-- Chats get a title, derived from the first message of the user until the assistant or the user
+-- give a better one, and can be pinned or archived. updated_at tracks the last message added, to
+-- list the chats with the latest activity first.
+ALTER TABLE chats
+    ADD COLUMN title        TEXT      NOT NULL DEFAULT '',
+    ADD COLUMN title_edited BOOLEAN   NOT NULL DEFAULT false,
+    ADD COLUMN pinned       BOOLEAN   NOT NULL DEFAULT false,
+    ADD COLUMN archived     BOOLEAN   NOT NULL DEFAULT false,
+    ADD COLUMN updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE chats
+SET updated_at = COALESCE((SELECT MAX(created_at) FROM chat_messages WHERE chat_id = chats.id),
+                          chats.created_at,
+                          CURRENT_TIMESTAMP),
+    title      = COALESCE((SELECT LEFT(REGEXP_REPLACE(TRIM(text), '\s+', ' ', 'g'), 80)
+                           FROM chat_messages
+                           WHERE chat_id = chats.id
+                             AND sender = 'user'
+                           ORDER BY sequence
+                           LIMIT 1), '');
+
+CREATE INDEX chats_account_id_activity_idx ON chats (account_id, pinned DESC, updated_at DESC) WHERE deleted_at IS NULL;
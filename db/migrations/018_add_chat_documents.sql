@@ -0,0 +1,10 @@
+-- Documents attached to a chat, which the assistant looks into first to answer it.
+CREATE TABLE chat_documents
+(
+    chat_id     INTEGER NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    attached_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, document_id)
+);
+
+CREATE INDEX chat_documents_document_id_idx ON chat_documents (document_id);
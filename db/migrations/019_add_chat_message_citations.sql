@@ -0,0 +1,20 @@
+-- Assistant messages record how they were written and the passages of documents they are based on.
+ALTER TABLE chat_messages
+    ADD COLUMN model             TEXT,
+    ADD COLUMN prompt_tokens     INTEGER,
+    ADD COLUMN completion_tokens INTEGER,
+    ADD COLUMN latency_ms        INTEGER;
+
+CREATE TABLE chat_message_citations
+(
+    message_id  UUID    NOT NULL REFERENCES chat_messages (id) ON DELETE CASCADE,
+    position    INTEGER NOT NULL,
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    page_number INTEGER,
+    chunk_index INTEGER,
+    quote       TEXT    NOT NULL DEFAULT '',
+    score       REAL,
+    PRIMARY KEY (message_id, position)
+);
+
+CREATE INDEX chat_message_citations_document_id_idx ON chat_message_citations (document_id);
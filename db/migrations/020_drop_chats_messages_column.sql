@@ -0,0 +1,4 @@
+-- Chats have kept the messages array they had before messages got their own table, in case of a
+-- rollback. Nothing reads or writes it anymore.
+ALTER TABLE chats
+    DROP COLUMN messages;
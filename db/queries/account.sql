@@ -19,6 +19,13 @@ FROM accounts
 WHERE username = $1;
 
 
+-- name: GetAccountByUsernameOrEmail :one
+SELECT *
+FROM accounts
+WHERE username = $1
+   OR email = $1;
+
+
 -- name: CreateAccount :one
 INSERT INTO accounts (username, password_hash, email)
 VALUES ($1, $2, $3)
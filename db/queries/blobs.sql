@@ -0,0 +1,38 @@
+-- Record an object, or every object under a prefix, whose deletion failed so it is retried later
+-- name: RecordPendingBlobDeletion :exec
+INSERT INTO pending_blob_deletions (object_name, is_prefix, last_error, last_attempt_at)
+VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+ON CONFLICT (object_name) DO NOTHING;
+
+
+-- Least retried deletions first, so that deletions failing over and over do not hold back newer ones
+-- name: ListPendingBlobDeletions :many
+SELECT id, created_at, object_name, is_prefix, attempts, last_error, last_attempt_at
+FROM pending_blob_deletions
+ORDER BY attempts, last_attempt_at NULLS FIRST, id
+LIMIT $1;
+
+
+-- name: DeletePendingBlobDeletion :exec
+DELETE
+FROM pending_blob_deletions
+WHERE id = $1;
+
+
+-- name: RecordBlobDeletionFailure :exec
+UPDATE pending_blob_deletions
+SET attempts        = attempts + 1,
+    last_error      = $1,
+    last_attempt_at = CURRENT_TIMESTAMP
+WHERE id = $2;
+
+
+-- Every file path documents and their versions point to, including trashed documents that can still be restored
+-- name: ListReferencedFilePaths :many
+SELECT file_path
+FROM documents
+WHERE file_path IS NOT NULL
+UNION
+SELECT file_path
+FROM document_versions
+WHERE file_path IS NOT NULL;
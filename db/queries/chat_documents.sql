@@ -0,0 +1,65 @@
+-- name: AttachDocumentToChat :exec
+INSERT INTO chat_documents (chat_id, document_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+
+-- name: DetachDocumentFromChat :exec
+DELETE
+FROM chat_documents
+WHERE chat_id = $1
+  AND document_id = $2;
+
+
+-- Attach the documents of a chat to another one
+-- name: CopyChatDocuments :exec
+INSERT INTO chat_documents (chat_id, document_id)
+SELECT @target_chat_id::integer, document_id
+FROM chat_documents
+WHERE chat_id = @chat_id::integer
+ON CONFLICT DO NOTHING;
+
+
+-- Documents attached to a chat that its owner still has
+-- name: GetChatDocuments :many
+SELECT documents.id, documents.created_at, documents.name, documents.text, documents.file_path, documents.embedding, documents.account_id, documents.page_count, documents.source_metadata, documents.thumbnail_path, documents.description, documents.file_name, documents.size_bytes, documents.content_hash, documents.current_version, documents.metadata, documents.deleted_at, documents.data_key, documents.data_key_id
+FROM documents
+         JOIN chat_documents ON chat_documents.document_id = documents.id
+         JOIN chats ON chats.id = chat_documents.chat_id
+WHERE chat_documents.chat_id = @chat_id
+  AND documents.account_id = chats.account_id
+  AND documents.deleted_at IS NULL
+ORDER BY documents.name, documents.id
+LIMIT @page_limit OFFSET @page_offset;
+
+
+-- name: GetChatDocumentIDs :many
+SELECT documents.id
+FROM documents
+         JOIN chat_documents ON chat_documents.document_id = documents.id
+         JOIN chats ON chats.id = chat_documents.chat_id
+WHERE chat_documents.chat_id = @chat_id
+  AND documents.account_id = chats.account_id
+  AND documents.deleted_at IS NULL
+ORDER BY documents.id;
+
+
+-- Pages of the documents attached to a chat matching any word of a query, best matches first. The
+-- words are OR-ed, questions rarely contain every word of the passage answering them
+-- name: SearchChatDocumentPages :many
+WITH query AS (SELECT replace(plainto_tsquery('simple', @query)::TEXT, '&', '|')::TSQUERY AS words)
+SELECT document_pages.document_id,
+       document_pages.page_number,
+       LEFT(document_pages.text, @max_text_length)::TEXT AS text,
+       ts_rank(to_tsvector('simple', document_pages.text), query.words)::REAL AS score
+FROM document_pages
+         CROSS JOIN query
+         JOIN chat_documents ON chat_documents.document_id = document_pages.document_id
+         JOIN documents ON documents.id = document_pages.document_id
+         JOIN chats ON chats.id = chat_documents.chat_id
+WHERE chat_documents.chat_id = @chat_id
+  AND documents.account_id = chats.account_id
+  AND documents.deleted_at IS NULL
+  AND to_tsvector('simple', document_pages.text) @@ query.words
+ORDER BY score DESC, document_pages.document_id, document_pages.page_number
+LIMIT @page_limit;
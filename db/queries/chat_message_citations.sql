@@ -0,0 +1,11 @@
+-- name: CreateChatMessageCitation :exec
+INSERT INTO chat_message_citations (message_id, position, document_id, page_number, chunk_index, quote, score)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+
+-- Citations of the given messages, in the order they were given
+-- name: ListChatMessageCitations :many
+SELECT *
+FROM chat_message_citations
+WHERE message_id = ANY (@message_ids::UUID[])
+ORDER BY message_id, position;
@@ -0,0 +1,194 @@
+-- Append a message to a chat under the given parent. Incrementing the last sequence number of the
+-- chat locks it, so concurrent messages get consecutive sequence numbers
+-- name: AppendChatMessage :one
+WITH chat AS (
+    UPDATE chats
+        SET last_message_sequence = last_message_sequence + 1,
+            updated_at            = CURRENT_TIMESTAMP
+        WHERE id = @chat_id
+        RETURNING id, last_message_sequence)
+INSERT
+INTO chat_messages (chat_id, parent_id, sequence, sender, text)
+SELECT id, sqlc.narg('parent_id')::UUID, last_message_sequence, @sender, @text
+FROM chat
+RETURNING *;
+
+
+-- name: GetChatMessage :one
+SELECT *
+FROM chat_messages
+WHERE id = $1
+  AND chat_id = $2;
+
+
+-- Messages of the active branch of a chat, from its root to the active message
+-- name: ListActiveChatMessages :many
+WITH RECURSIVE path AS (SELECT chat_messages.*
+                        FROM chat_messages
+                                 JOIN chats ON chats.active_message_id = chat_messages.id
+                        WHERE chats.id = @chat_id
+                        UNION ALL
+                        SELECT parent.*
+                        FROM chat_messages parent
+                                 JOIN path ON path.parent_id = parent.id)
+SELECT *
+FROM path
+ORDER BY sequence;
+
+
+-- Messages of the active branch of a chat after the given sequence number, in order. A null limit
+-- returns all of them
+-- name: ListChatMessagesAfter :many
+WITH RECURSIVE path AS (SELECT chat_messages.*
+                        FROM chat_messages
+                                 JOIN chats ON chats.active_message_id = chat_messages.id
+                        WHERE chats.id = @chat_id
+                        UNION ALL
+                        SELECT parent.*
+                        FROM chat_messages parent
+                                 JOIN path ON path.parent_id = parent.id)
+SELECT *
+FROM path
+WHERE sequence > @after_sequence
+ORDER BY sequence
+LIMIT sqlc.narg('page_limit')::INTEGER;
+
+
+-- Messages of the active branch of a chat before the given sequence number, the most recent first
+-- name: ListChatMessagesBefore :many
+WITH RECURSIVE path AS (SELECT chat_messages.*
+                        FROM chat_messages
+                                 JOIN chats ON chats.active_message_id = chat_messages.id
+                        WHERE chats.id = @chat_id
+                        UNION ALL
+                        SELECT parent.*
+                        FROM chat_messages parent
+                                 JOIN path ON path.parent_id = parent.id)
+SELECT *
+FROM path
+WHERE sequence < @before_sequence
+ORDER BY sequence DESC
+LIMIT @page_limit;
+
+
+-- Messages of the active branches of several chats, grouped by chat and in order
+-- name: ListActiveMessagesOfChats :many
+WITH RECURSIVE path AS (SELECT chat_messages.*
+                        FROM chat_messages
+                                 JOIN chats ON chats.active_message_id = chat_messages.id
+                        WHERE chats.id = ANY (@chat_ids::INTEGER[])
+                        UNION ALL
+                        SELECT parent.*
+                        FROM chat_messages parent
+                                 JOIN path ON path.parent_id = parent.id)
+SELECT *
+FROM path
+ORDER BY chat_id, sequence;
+
+
+-- The messages sharing their parent with the given messages, themselves included, the oldest first
+-- name: ListChatMessageSiblings :many
+SELECT siblings.id, siblings.chat_id, siblings.parent_id
+FROM chat_messages siblings
+         JOIN chat_messages messages
+              ON messages.chat_id = siblings.chat_id
+                  AND messages.parent_id IS NOT DISTINCT FROM siblings.parent_id
+WHERE messages.id = ANY (@message_ids::UUID[])
+ORDER BY siblings.created_at, siblings.sequence;
+
+
+-- The latest message under the given one, or in the whole chat without one. Messages are created
+-- after their parent, so it is always a leaf
+-- name: GetLatestChatLeaf :one
+WITH RECURSIVE tree AS (SELECT *
+                        FROM chat_messages
+                        WHERE chat_id = @chat_id
+                          AND (id = sqlc.narg('message_id')::UUID
+                            OR (sqlc.narg('message_id')::UUID IS NULL AND parent_id IS NULL))
+                        UNION ALL
+                        SELECT child.*
+                        FROM chat_messages child
+                                 JOIN tree ON child.parent_id = tree.id)
+SELECT *
+FROM tree
+ORDER BY sequence DESC
+LIMIT 1;
+
+
+-- Delete a message of a chat along with the messages under it
+-- name: DeleteChatMessageTree :many
+WITH RECURSIVE tree AS (SELECT id
+                        FROM chat_messages
+                        WHERE id = @id
+                          AND chat_id = @chat_id
+                        UNION ALL
+                        SELECT child.id
+                        FROM chat_messages child
+                                 JOIN tree ON child.parent_id = tree.id)
+DELETE
+FROM chat_messages
+WHERE id IN (SELECT id FROM tree)
+RETURNING id;
+
+
+-- Copy the messages of a chat from its root to the given message, along with their citations, into
+-- another chat, where the copy of the message becomes the active one
+-- name: ForkChatMessages :one
+WITH RECURSIVE path AS (SELECT *
+                        FROM chat_messages
+                        WHERE id = @message_id
+                          AND chat_id = @chat_id
+                        UNION ALL
+                        SELECT parent.*
+                        FROM chat_messages parent
+                                 JOIN path ON path.parent_id = parent.id),
+               copies AS MATERIALIZED (SELECT gen_random_uuid()                              AS copy_id,
+                                              ROW_NUMBER() OVER (ORDER BY sequence)::INTEGER AS copy_sequence,
+                                              path.*
+                                       FROM path),
+               inserted AS (
+                   INSERT INTO chat_messages (id, chat_id, parent_id, sequence, created_at, sender, text, model,
+                                              prompt_tokens, completion_tokens, latency_ms)
+                       SELECT copies.copy_id,
+                              @fork_chat_id::INTEGER,
+                              parents.copy_id,
+                              copies.copy_sequence,
+                              copies.created_at,
+                              copies.sender,
+                              copies.text,
+                              copies.model,
+                              copies.prompt_tokens,
+                              copies.completion_tokens,
+                              copies.latency_ms
+                       FROM copies
+                                LEFT JOIN copies parents ON parents.id = copies.parent_id
+                       RETURNING id, sequence),
+               copied_citations AS (
+                   INSERT INTO chat_message_citations (message_id, position, document_id, page_number, chunk_index,
+                                                       quote, score)
+                       SELECT copies.copy_id,
+                              citations.position,
+                              citations.document_id,
+                              citations.page_number,
+                              citations.chunk_index,
+                              citations.quote,
+                              citations.score
+                       FROM copies
+                                JOIN chat_message_citations citations ON citations.message_id = copies.id)
+UPDATE chats
+SET active_message_id     = (SELECT id FROM inserted ORDER BY sequence DESC LIMIT 1),
+    last_message_sequence = (SELECT COUNT(*) FROM inserted),
+    title                 = (SELECT title FROM chats source WHERE source.id = @chat_id)
+WHERE id = @fork_chat_id
+RETURNING *;
+
+
+-- Record how the assistant wrote a message
+-- name: SetChatMessageMetadata :one
+UPDATE chat_messages
+SET model             = sqlc.narg('model'),
+    prompt_tokens     = sqlc.narg('prompt_tokens'),
+    completion_tokens = sqlc.narg('completion_tokens'),
+    latency_ms        = sqlc.narg('latency_ms')
+WHERE id = @id
+    RETURNING *;
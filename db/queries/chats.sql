@@ -2,26 +2,30 @@
 -- name: GetChatByID :one
 SELECT *
 FROM chats
-WHERE id = $1;
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+-- Get a chat and lock it until the end of the transaction
+-- name: GetChatForUpdate :one
+SELECT *
+FROM chats
+WHERE id = $1
+  AND deleted_at IS NULL
+    FOR UPDATE;
 
 -- List all chats by account_id
 -- name: ListChatsByAccountID :many
 SELECT *
 FROM chats
 WHERE account_id = $1
+  AND deleted_at IS NULL
 ORDER BY created_at DESC;
 
 -- Create a new chat
 -- name: CreateChat :one
-INSERT INTO chats (messages, account_id)
+INSERT INTO chats (account_id, collection_id)
 VALUES ($1, $2) RETURNING *;
 
--- Update chat's messages
--- name: UpdateChatMessages :one
-UPDATE chats
-SET messages = $1
-WHERE id = $2 RETURNING *;
-
 -- Delete chat by ID
 -- name: DeleteChat :exec
 DELETE
@@ -33,32 +37,171 @@ WHERE id = $1;
 SELECT EXISTS(SELECT 1
               FROM chats
               WHERE account_id = $1
-                AND id = $2);
+                AND id = $2
+                AND deleted_at IS NULL);
 
 
 -- name: GetChatsByAccountID :many
 SELECT *
 FROM chats
 WHERE account_id = $1
+  AND deleted_at IS NULL
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3;
 
 
--- name: AddMessageToChat :one
+-- Summaries of the chats of an account, the pinned chats first and then the chats with the latest
+-- activity. Archived chats are only listed when asked for
+-- name: ListChatSummaries :many
+SELECT chats.id,
+       chats.created_at,
+       chats.updated_at,
+       chats.collection_id,
+       COALESCE(chats.unread_messages, false)::BOOLEAN AS unread,
+       chats.title,
+       chats.pinned,
+       chats.archived,
+       COALESCE(LEFT(last_message.text, 200), '')::TEXT AS last_message_preview,
+       (WITH RECURSIVE path AS (SELECT id, parent_id
+                                FROM chat_messages
+                                WHERE id = chats.active_message_id
+                                UNION ALL
+                                SELECT parent.id, parent.parent_id
+                                FROM chat_messages parent
+                                         JOIN path ON path.parent_id = parent.id)
+        SELECT COUNT(*)
+        FROM path)::INTEGER AS message_count
+FROM chats
+         LEFT JOIN chat_messages last_message ON last_message.id = chats.active_message_id
+WHERE chats.account_id = @account_id
+  AND chats.deleted_at IS NULL
+  AND chats.archived = @archived
+  AND (sqlc.narg('pinned')::BOOLEAN IS NULL OR chats.pinned = sqlc.narg('pinned')::BOOLEAN)
+ORDER BY chats.pinned DESC, chats.updated_at DESC, chats.id DESC
+LIMIT @page_limit OFFSET @page_offset;
+
+
+-- Change the details of a chat given by the user, leaving those that are null unchanged
+-- name: UpdateChatDetails :one
 UPDATE chats
-SET messages = messages || @newMessage::jsonb
-WHERE id = @chatID
+SET title        = COALESCE(sqlc.narg('title')::TEXT, title),
+    title_edited = title_edited OR sqlc.narg('title')::TEXT IS NOT NULL,
+    pinned       = COALESCE(sqlc.narg('pinned')::BOOLEAN, pinned),
+    archived     = COALESCE(sqlc.narg('archived')::BOOLEAN, archived)
+WHERE id = @id
+  AND deleted_at IS NULL
     RETURNING *;
 
 
+-- Give a title to a chat that has none yet
+-- name: SetChatTitleIfEmpty :execrows
+UPDATE chats
+SET title = @title
+WHERE id = @id
+  AND title = '';
+
+
+-- Replace the title of a chat, unless the user gave it one
+-- name: SetChatTitleUnlessEdited :one
+UPDATE chats
+SET title = @title
+WHERE id = @id
+  AND NOT title_edited
+  AND deleted_at IS NULL
+    RETURNING *;
+
+
+-- name: SetChatActiveMessage :exec
+UPDATE chats
+SET active_message_id = $1
+WHERE id = $2;
+
+
 -- name: MarkAsReadByID :exec
 UPDATE chats
 SET unread_messages= false
 WHERE id = $1;
 
 
+-- name: MarkAsUnreadByID :exec
+UPDATE chats
+SET unread_messages= true
+WHERE id = $1;
+
+
 -- name: IsUnread :one
 SELECT unread_messages
 FROM chats
 WHERE id = $1;
 
+
+
+-- name: UpdateChatCollection :one
+UPDATE chats
+SET collection_id = $1
+WHERE id = $2
+    RETURNING *;
+
+
+-- Move a chat to the trash, it stays there until it is restored or purged
+-- name: TrashChat :exec
+UPDATE chats
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+
+-- name: RestoreChat :one
+UPDATE chats
+SET deleted_at = NULL
+WHERE id = $1
+  AND account_id = $2
+  AND deleted_at IS NOT NULL
+    RETURNING *;
+
+
+-- name: ListTrashedChats :many
+SELECT *
+FROM chats
+WHERE account_id = $1
+  AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id DESC
+LIMIT $2 OFFSET $3;
+
+
+-- name: DeleteTrashedChat :execrows
+DELETE
+FROM chats
+WHERE id = $1
+  AND account_id = $2
+  AND deleted_at IS NOT NULL;
+
+
+-- Permanently delete the chats that have been in the trash since before the given time, releasing their usage
+-- name: PurgeTrashedChats :exec
+WITH purged AS (DELETE FROM chats WHERE deleted_at < @deleted_before::TIMESTAMP RETURNING account_id)
+UPDATE account_usage
+SET chat_count = account_usage.chat_count - purged_counts.chat_count
+FROM (SELECT account_id, COUNT(*) AS chat_count FROM purged GROUP BY account_id) purged_counts
+WHERE account_usage.account_id = purged_counts.account_id;
+
+
+-- The given chats an account owns
+-- name: GetOwnedChatIDs :many
+SELECT id
+FROM chats
+WHERE account_id = @account_id
+  AND id = ANY (@chat_ids::INTEGER[])
+  AND deleted_at IS NULL;
+
+
+-- Record a reply of the assistant, affecting no row when it was already recorded
+-- name: RecordChatReply :execrows
+INSERT INTO chat_replies (reply_id, chat_id)
+VALUES ($1, $2)
+ON CONFLICT (chat_id, reply_id) DO NOTHING;
+
+
+-- Publish a chat event to every replica, once the transaction commits when run in one
+-- name: NotifyChatEvent :exec
+SELECT pg_notify('chat_events', @payload::TEXT);
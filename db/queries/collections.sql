@@ -0,0 +1,135 @@
+-- name: CreateCollection :one
+INSERT INTO collections (name, account_id, parent_id)
+VALUES ($1, $2, $3)
+RETURNING id, created_at, name, account_id, parent_id;
+
+
+-- name: GetCollectionByID :one
+SELECT id, created_at, name, account_id, parent_id
+FROM collections
+WHERE id = $1;
+
+
+-- name: GetCollectionsByAccountID :many
+SELECT id, created_at, name, account_id, parent_id
+FROM collections
+WHERE account_id = $1
+ORDER BY name, id;
+
+
+-- name: UpdateCollection :one
+UPDATE collections
+SET name      = $1,
+    parent_id = $2
+WHERE id = $3
+RETURNING id, created_at, name, account_id, parent_id;
+
+
+-- Sub-collections and memberships are deleted with the collection, documents are kept
+-- name: DeleteCollection :exec
+DELETE
+FROM collections
+WHERE id = $1;
+
+
+-- name: AccountOwnsCollection :one
+SELECT EXISTS(SELECT 1
+              FROM collections
+              WHERE account_id = $1
+                AND id = $2);
+
+
+-- Whether candidate_id is the collection itself or one of its descendants
+-- name: IsCollectionInSubtree :one
+WITH RECURSIVE subtree AS (SELECT collections.id
+                           FROM collections
+                           WHERE collections.id = @collection_id::integer
+                           UNION
+                           SELECT collections.id
+                           FROM collections
+                                    JOIN subtree ON collections.parent_id = subtree.id)
+SELECT EXISTS(SELECT 1 FROM subtree WHERE id = @candidate_id::integer);
+
+
+-- name: ReparentChildCollections :exec
+UPDATE collections
+SET parent_id = sqlc.narg('new_parent_id')
+WHERE parent_id = @collection_id;
+
+
+-- name: AddDocumentToCollection :exec
+INSERT INTO collection_documents (collection_id, document_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+
+-- name: RemoveDocumentFromCollection :exec
+DELETE
+FROM collection_documents
+WHERE collection_id = $1
+  AND document_id = $2;
+
+
+-- Add every document of a collection to another one
+-- name: CopyCollectionDocuments :exec
+INSERT INTO collection_documents (collection_id, document_id)
+SELECT @target_collection_id::integer, document_id
+FROM collection_documents
+WHERE collection_id = @collection_id::integer
+ON CONFLICT DO NOTHING;
+
+
+-- Documents shared with the owner of the collection are left out once they are no longer shared
+-- name: GetCollectionDocuments :many
+WITH RECURSIVE scope AS (SELECT collections.id
+                        FROM collections
+                        WHERE collections.id = @collection_id::integer
+                        UNION
+                        SELECT collections.id
+                        FROM collections
+                                 JOIN scope ON collections.parent_id = scope.id
+                        WHERE @recursive::boolean)
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE id IN (SELECT collection_documents.document_id
+             FROM collection_documents
+             WHERE collection_documents.collection_id IN (SELECT id FROM scope))
+  AND (account_id = (SELECT collections.account_id FROM collections WHERE collections.id = @collection_id::integer)
+    OR id IN (SELECT document_shares.document_id
+              FROM document_shares
+                       JOIN collections ON collections.account_id = document_shares.account_id
+              WHERE collections.id = @collection_id::integer))
+  AND deleted_at IS NULL
+ORDER BY name, id
+LIMIT @page_limit OFFSET @page_offset;
+
+
+-- name: GetCollectionDocumentIDs :many
+WITH RECURSIVE scope AS (SELECT collections.id
+                        FROM collections
+                        WHERE collections.id = @collection_id::integer
+                        UNION
+                        SELECT collections.id
+                        FROM collections
+                                 JOIN scope ON collections.parent_id = scope.id
+                        WHERE @recursive::boolean)
+SELECT id
+FROM documents
+WHERE id IN (SELECT collection_documents.document_id
+             FROM collection_documents
+             WHERE collection_documents.collection_id IN (SELECT id FROM scope))
+  AND (account_id = (SELECT collections.account_id FROM collections WHERE collections.id = @collection_id::integer)
+    OR id IN (SELECT document_shares.document_id
+              FROM document_shares
+                       JOIN collections ON collections.account_id = document_shares.account_id
+              WHERE collections.id = @collection_id::integer))
+  AND deleted_at IS NULL
+ORDER BY id;
+
+
+-- Remove a document from every collection of an account
+-- name: RemoveDocumentFromAccountCollections :exec
+DELETE
+FROM collection_documents
+WHERE document_id = $1
+  AND collection_id IN (SELECT id FROM collections WHERE account_id = $2);
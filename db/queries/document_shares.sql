@@ -0,0 +1,75 @@
+-- The permission an account has on a document: owner, editor or viewer. No rows means no access
+-- name: GetDocumentPermission :one
+SELECT (CASE
+            WHEN documents.account_id = @account_id::integer THEN 'owner'
+            ELSE document_shares.permission END)::TEXT AS permission
+FROM documents
+         LEFT JOIN document_shares
+                   ON document_shares.document_id = documents.id AND document_shares.account_id = @account_id::integer
+WHERE documents.id = @document_id::integer
+  AND documents.deleted_at IS NULL
+  AND (documents.account_id = @account_id::integer OR document_shares.account_id IS NOT NULL);
+
+
+-- name: UpsertDocumentShare :one
+INSERT INTO document_shares (document_id, account_id, permission)
+VALUES ($1, $2, $3)
+ON CONFLICT (document_id, account_id) DO UPDATE SET permission = EXCLUDED.permission
+RETURNING document_id, account_id, permission, created_at;
+
+
+-- name: DeleteDocumentShare :exec
+DELETE
+FROM document_shares
+WHERE document_id = $1
+  AND account_id = $2;
+
+
+-- name: ListDocumentShares :many
+SELECT document_shares.document_id,
+       document_shares.account_id,
+       document_shares.permission,
+       document_shares.created_at,
+       accounts.username,
+       accounts.email
+FROM document_shares
+         JOIN accounts ON accounts.id = document_shares.account_id
+WHERE document_shares.document_id = $1
+ORDER BY accounts.username;
+
+
+-- Documents other accounts shared with an account, most recently shared first
+-- name: ListSharedDocuments :many
+SELECT documents.id, documents.created_at, documents.name, documents.text, documents.file_path, documents.embedding, documents.account_id, documents.page_count, documents.source_metadata, documents.thumbnail_path, documents.description, documents.file_name, documents.size_bytes, documents.content_hash, documents.current_version, documents.metadata, documents.deleted_at, documents.data_key, documents.data_key_id,
+       document_shares.permission,
+       owners.username AS owner_username
+FROM document_shares
+         JOIN documents ON documents.id = document_shares.document_id
+         JOIN accounts owners ON owners.id = documents.account_id
+WHERE document_shares.account_id = $1
+  AND documents.deleted_at IS NULL
+ORDER BY document_shares.created_at DESC, documents.id DESC
+LIMIT $2 OFFSET $3;
+
+
+-- name: GetSharedDocumentIDs :many
+SELECT document_shares.document_id
+FROM document_shares
+         JOIN documents ON documents.id = document_shares.document_id
+WHERE document_shares.account_id = $1
+  AND documents.deleted_at IS NULL
+ORDER BY document_shares.document_id;
+
+
+-- The permission an account has on each of the given documents it can access, documents it cannot access are left out
+-- name: GetDocumentPermissions :many
+SELECT documents.id AS document_id,
+       (CASE
+            WHEN documents.account_id = @account_id::integer THEN 'owner'
+            ELSE document_shares.permission END)::TEXT AS permission
+FROM documents
+         LEFT JOIN document_shares
+                   ON document_shares.document_id = documents.id AND document_shares.account_id = @account_id::integer
+WHERE documents.id = ANY (@document_ids::INTEGER[])
+  AND documents.deleted_at IS NULL
+  AND (documents.account_id = @account_id::integer OR document_shares.account_id IS NOT NULL);
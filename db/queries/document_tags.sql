@@ -0,0 +1,28 @@
+-- name: AddDocumentTag :exec
+INSERT INTO document_tags (document_id, tag)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+
+-- name: RemoveDocumentTag :exec
+DELETE
+FROM document_tags
+WHERE document_id = $1
+  AND tag = $2;
+
+
+-- name: GetDocumentTags :many
+SELECT tag
+FROM document_tags
+WHERE document_id = $1
+ORDER BY tag;
+
+
+-- name: GetAccountTags :many
+SELECT document_tags.tag, COUNT(*) AS document_count
+FROM document_tags
+         JOIN documents ON documents.id = document_tags.document_id
+WHERE documents.account_id = $1
+  AND documents.deleted_at IS NULL
+GROUP BY document_tags.tag
+ORDER BY document_tags.tag;
@@ -0,0 +1,34 @@
+-- name: CreateDocumentVersion :one
+INSERT INTO document_versions (document_id, version_number, file_name, file_path, text, content_hash, size_bytes)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at, document_id, version_number, file_name, file_path, text, content_hash, size_bytes;
+
+
+-- name: GetDocumentVersions :many
+SELECT id, created_at, document_id, version_number, file_name, file_path, text, content_hash, size_bytes
+FROM document_versions
+WHERE document_id = $1
+ORDER BY version_number DESC
+LIMIT $2 OFFSET $3;
+
+
+-- name: GetDocumentVersion :one
+SELECT id, created_at, document_id, version_number, file_name, file_path, text, content_hash, size_bytes
+FROM document_versions
+WHERE document_id = $1
+  AND version_number = $2;
+
+
+-- Restored versions share their file with the version they were restored from
+-- name: GetDocumentVersionFilePaths :many
+SELECT DISTINCT file_path
+FROM document_versions
+WHERE document_id = $1
+  AND file_path IS NOT NULL;
+
+-- Bytes stored for the files of a document, counting files shared between versions once
+-- name: GetDocumentStoredBytes :one
+SELECT COALESCE(SUM(files.size_bytes), 0)::BIGINT AS stored_bytes
+FROM (SELECT DISTINCT file_path, size_bytes
+      FROM document_versions
+      WHERE document_id = $1) files;
@@ -1,14 +1,24 @@
 -- Create a new document
 -- name: CreateDocument :one
-INSERT INTO documents (name, text, file_path, embedding, account_id)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, created_at, name, text, file_path, embedding, account_id;
+INSERT INTO documents (name, text, file_path, embedding, account_id, page_count, source_metadata, file_name,
+                       size_bytes, content_hash, data_key, data_key_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id;
 
 -- Get a document by ID
 -- name: GetDocumentByID :one
-SELECT id, created_at, name, text, file_path, embedding, account_id
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
 FROM documents
-WHERE id = $1;
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+-- Get a document and lock it until the end of the transaction
+-- name: GetDocumentForUpdate :one
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE id = $1
+  AND deleted_at IS NULL
+    FOR UPDATE;
 
 -- Delete a document by ID
 -- name: DeleteDocument :exec
@@ -19,9 +29,11 @@ WHERE id = $1;
 
 -- Get all documents for a specific account
 -- name: GetDocumentsByAccountID :many
-SELECT id, created_at, name, text, file_path, embedding, account_id
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
 FROM documents
 WHERE account_id = $1
+  AND deleted_at IS NULL
+ORDER BY created_at DESC, id DESC
 LIMIT $2 OFFSET $3;
 
 
@@ -29,4 +41,222 @@ LIMIT $2 OFFSET $3;
 SELECT EXISTS(SELECT 1
               FROM documents
               WHERE account_id = $1
-                AND id = $2);
+                AND id = $2
+                AND deleted_at IS NULL);
+
+
+-- name: CreateDocumentPage :exec
+INSERT INTO document_pages (document_id, page_number, text)
+VALUES ($1, $2, $3);
+
+
+-- name: GetDocumentPages :many
+SELECT document_id, page_number, text
+FROM document_pages
+WHERE document_id = $1
+ORDER BY page_number
+LIMIT $2 OFFSET $3;
+
+
+-- name: GetDocumentPage :one
+SELECT document_id, page_number, text
+FROM document_pages
+WHERE document_id = $1
+  AND page_number = $2;
+
+
+-- name: SetDocumentThumbnailPath :exec
+UPDATE documents
+SET thumbnail_path = $1
+WHERE id = $2;
+
+
+-- name: UpdateDocumentDetails :one
+UPDATE documents
+SET name        = $1,
+    description = $2,
+    metadata    = $3
+WHERE id = $4
+RETURNING id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id;
+
+
+-- Point a document at a new file, clearing everything derived from the previous one
+-- name: SetDocumentFile :one
+UPDATE documents
+SET file_name       = $1,
+    file_path       = $2,
+    text            = $3,
+    page_count      = $4,
+    source_metadata = $5,
+    size_bytes      = $6,
+    content_hash    = $7,
+    current_version = $8,
+    embedding       = NULL,
+    thumbnail_path  = NULL
+WHERE id = $9
+RETURNING id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id;
+
+
+-- name: DeleteDocumentPages :exec
+DELETE
+FROM document_pages
+WHERE document_id = $1;
+
+
+-- Full-text search over the name and text of the documents of an account and the ones shared with it, optionally
+-- restricted to a collection and, when recursive, its sub-collections
+-- name: SearchDocuments :many
+WITH RECURSIVE scope AS (SELECT collections.id
+                        FROM collections
+                        WHERE collections.id = sqlc.narg('collection_id')::integer
+                        UNION
+                        SELECT collections.id
+                        FROM collections
+                                 JOIN scope ON collections.parent_id = scope.id
+                        WHERE @recursive::boolean)
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE (account_id = @account_id
+    OR id IN (SELECT document_shares.document_id
+              FROM document_shares
+              WHERE document_shares.account_id = @account_id))
+  AND deleted_at IS NULL
+  AND to_tsvector('simple', name || ' ' || COALESCE(text, '')) @@ websearch_to_tsquery('simple', @query)
+  AND (sqlc.narg('collection_id')::integer IS NULL
+    OR id IN (SELECT document_id
+              FROM collection_documents
+              WHERE collection_id IN (SELECT id FROM scope)))
+ORDER BY ts_rank(to_tsvector('simple', name || ' ' || COALESCE(text, '')), websearch_to_tsquery('simple', @query)) DESC,
+         id
+LIMIT @page_limit OFFSET @page_offset;
+
+
+-- Documents of an account matching every given filter, in a stable order so pages do not overlap
+-- name: ListAccountDocuments :many
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id,
+       COALESCE((SELECT array_agg(document_tags.tag ORDER BY document_tags.tag)
+                 FROM document_tags
+                 WHERE document_tags.document_id = documents.id), '{}')::TEXT[] AS tags
+FROM documents
+WHERE account_id = @account_id
+  AND deleted_at IS NULL
+  AND (cardinality(@tags::TEXT[]) = 0
+    OR id IN (SELECT document_tags.document_id
+              FROM document_tags
+              WHERE document_tags.tag = ANY (@tags::TEXT[])
+              GROUP BY document_tags.document_id
+              HAVING COUNT(*) = cardinality(@tags::TEXT[])))
+  AND strpos(lower(name), lower(@name_contains::TEXT)) > 0
+  AND (@extension::TEXT = '' OR right(lower(file_name), length(@extension::TEXT)) = lower(@extension::TEXT))
+  AND (sqlc.narg('created_after')::TIMESTAMP IS NULL OR created_at >= sqlc.narg('created_after')::TIMESTAMP)
+  AND (sqlc.narg('created_before')::TIMESTAMP IS NULL OR created_at < sqlc.narg('created_before')::TIMESTAMP)
+  AND (@status::TEXT = ''
+    OR (@status::TEXT = 'indexed' AND embedding IS NOT NULL)
+    OR (@status::TEXT = 'pending' AND embedding IS NULL))
+  AND metadata @> @metadata_filter::JSONB
+ORDER BY CASE WHEN @sort_by::TEXT = 'name' AND NOT @descending::BOOLEAN THEN lower(name) END,
+         CASE WHEN @sort_by::TEXT = 'name' AND @descending::BOOLEAN THEN lower(name) END DESC,
+         CASE WHEN @sort_by::TEXT = 'size' AND NOT @descending::BOOLEAN THEN size_bytes END,
+         CASE WHEN @sort_by::TEXT = 'size' AND @descending::BOOLEAN THEN size_bytes END DESC NULLS LAST,
+         CASE WHEN @sort_by::TEXT = 'created_at' AND NOT @descending::BOOLEAN THEN created_at END,
+         CASE WHEN @sort_by::TEXT = 'created_at' AND @descending::BOOLEAN THEN created_at END DESC,
+         CASE WHEN NOT @descending::BOOLEAN THEN id END,
+         CASE WHEN @descending::BOOLEAN THEN id END DESC
+LIMIT @page_limit OFFSET @page_offset;
+
+
+-- Move a document to the trash, it stays there until it is restored or purged
+-- name: TrashDocument :exec
+UPDATE documents
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+
+-- name: RestoreDocument :one
+UPDATE documents
+SET deleted_at = NULL
+WHERE id = $1
+  AND account_id = $2
+  AND deleted_at IS NOT NULL
+RETURNING id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id;
+
+
+-- name: GetTrashedDocument :one
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE id = $1
+  AND account_id = $2
+  AND deleted_at IS NOT NULL;
+
+
+-- Permanently delete a document, unless it was restored or purged meanwhile
+-- name: DeleteTrashedDocument :execrows
+DELETE
+FROM documents
+WHERE id = $1
+  AND deleted_at IS NOT NULL;
+
+
+-- name: ListTrashedDocuments :many
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE account_id = $1
+  AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id DESC
+LIMIT $2 OFFSET $3;
+
+
+-- Documents that have been in the trash since before the given time, oldest first
+-- name: ListPurgeableDocuments :many
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE deleted_at < @deleted_before::TIMESTAMP
+ORDER BY deleted_at, id
+LIMIT @page_limit;
+
+
+-- Store the data key of a document that has none yet, so that a concurrent upload cannot replace
+-- the key its files are already encrypted with
+-- name: SetDocumentDataKeyIfMissing :execrows
+UPDATE documents
+SET data_key    = @data_key,
+    data_key_id = @data_key_id
+WHERE id = @id
+  AND data_key IS NULL;
+
+
+-- Replace the data key of a document with the same key wrapped by another master key
+-- name: RewrapDocumentDataKey :execrows
+UPDATE documents
+SET data_key    = @data_key,
+    data_key_id = @data_key_id
+WHERE id = @id
+  AND data_key_id = @previous_key_id::TEXT;
+
+
+-- Data keys of documents, trashed or not, wrapped by another master key than the given one, in ID
+-- order after the given document
+-- name: ListDocumentDataKeysToRotate :many
+SELECT id, data_key, data_key_id
+FROM documents
+WHERE data_key IS NOT NULL
+  AND data_key_id <> @current_key_id::TEXT
+  AND id > @after_id
+ORDER BY id
+LIMIT @page_limit;
+
+
+-- The given documents an account neither owns nor has been shared
+-- name: ListInaccessibleDocumentIDs :many
+SELECT requested.id
+FROM unnest(@document_ids::INTEGER[]) AS requested(id)
+WHERE NOT EXISTS(SELECT 1
+                 FROM documents
+                 WHERE documents.id = requested.id
+                   AND documents.deleted_at IS NULL
+                   AND (documents.account_id = @account_id
+                     OR documents.id IN (SELECT document_shares.document_id
+                                         FROM document_shares
+                                         WHERE document_shares.account_id = @account_id)))
+ORDER BY requested.id;
@@ -0,0 +1,32 @@
+-- name: CreateShareLink :one
+INSERT INTO share_links (token_hash, account_id, document_id, chat_id, expires_at, password_hash, allow_download)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at, token_hash, account_id, document_id, chat_id, expires_at, password_hash, allow_download, revoked_at;
+
+
+-- Only links that are neither revoked nor expired are returned
+-- name: GetActiveShareLinkByTokenHash :one
+SELECT id, created_at, token_hash, account_id, document_id, chat_id, expires_at, password_hash, allow_download, revoked_at
+FROM share_links
+WHERE token_hash = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP);
+
+
+-- name: ListShareLinks :many
+SELECT id, created_at, token_hash, account_id, document_id, chat_id, expires_at, password_hash, allow_download, revoked_at
+FROM share_links
+WHERE account_id = @account_id
+  AND document_id IS NOT DISTINCT FROM sqlc.narg('document_id')
+  AND chat_id IS NOT DISTINCT FROM sqlc.narg('chat_id')
+  AND revoked_at IS NULL
+ORDER BY created_at DESC, id DESC;
+
+
+-- name: RevokeShareLink :one
+UPDATE share_links
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = $1
+  AND account_id = $2
+  AND revoked_at IS NULL
+RETURNING id, created_at, token_hash, account_id, document_id, chat_id, expires_at, password_hash, allow_download, revoked_at;
@@ -0,0 +1,30 @@
+-- Usage of an account, with no usage recorded yet counting as zero
+-- name: GetAccountUsage :one
+SELECT accounts.id                                               AS account_id,
+       COALESCE(account_usage.document_count, 0)::INTEGER        AS document_count,
+       COALESCE(account_usage.stored_bytes, 0)::BIGINT           AS stored_bytes,
+       COALESCE(account_usage.chat_count, 0)::INTEGER            AS chat_count,
+       COALESCE(account_message_usage.message_count, 0)::INTEGER AS messages_today
+FROM accounts
+         LEFT JOIN account_usage ON account_usage.account_id = accounts.id
+         LEFT JOIN account_message_usage
+                   ON account_message_usage.account_id = accounts.id AND account_message_usage.day = CURRENT_DATE
+WHERE accounts.id = $1;
+
+
+-- Add to the usage counters of an account, negative amounts release usage
+-- name: AddAccountUsage :one
+INSERT INTO account_usage (account_id, document_count, stored_bytes, chat_count)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (account_id) DO UPDATE SET document_count = account_usage.document_count + EXCLUDED.document_count,
+                                       stored_bytes   = account_usage.stored_bytes + EXCLUDED.stored_bytes,
+                                       chat_count     = account_usage.chat_count + EXCLUDED.chat_count
+RETURNING account_id, document_count, stored_bytes, chat_count;
+
+
+-- Count a message sent today and return how many were sent today
+-- name: AddMessageUsage :one
+INSERT INTO account_message_usage (account_id, day, message_count)
+VALUES ($1, CURRENT_DATE, 1)
+ON CONFLICT (account_id, day) DO UPDATE SET message_count = account_message_usage.message_count + 1
+RETURNING message_count;
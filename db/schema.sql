@@ -12,24 +12,210 @@ CREATE TABLE accounts
 
 
 CREATE TABLE documents
+(
+    id              SERIAL PRIMARY KEY,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    name            TEXT    NOT NULL,
+    text            TEXT,
+    file_path       TEXT,
+    embedding       VECTOR(384),
+    account_id      INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    page_count      INTEGER,
+    source_metadata JSONB,
+    thumbnail_path  TEXT,
+    description     TEXT,
+    file_name       TEXT    NOT NULL,
+    size_bytes      BIGINT,
+    content_hash    TEXT,
+    current_version INTEGER NOT NULL DEFAULT 1,
+    metadata        JSONB   NOT NULL DEFAULT '{}',
+    deleted_at      TIMESTAMP,
+    data_key        BYTEA,
+    data_key_id     TEXT
+);
+
+CREATE INDEX documents_metadata_idx ON documents USING GIN (metadata);
+CREATE INDEX documents_deleted_at_idx ON documents (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX documents_data_key_id_idx ON documents (data_key_id) WHERE data_key IS NOT NULL;
+
+CREATE TABLE document_pages
+(
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    page_number INTEGER NOT NULL,
+    text        TEXT    NOT NULL,
+    PRIMARY KEY (document_id, page_number)
+);
+
+CREATE TABLE document_versions
+(
+    id             SERIAL PRIMARY KEY,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    document_id    INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    version_number INTEGER NOT NULL,
+    file_name      TEXT    NOT NULL,
+    file_path      TEXT,
+    text           TEXT,
+    content_hash   TEXT,
+    size_bytes     BIGINT,
+    UNIQUE (document_id, version_number)
+);
+
+CREATE INDEX documents_search_idx ON documents USING GIN (to_tsvector('simple', name || ' ' || COALESCE(text, '')));
+
+CREATE TABLE document_tags
+(
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    tag         TEXT    NOT NULL,
+    PRIMARY KEY (document_id, tag)
+);
+
+CREATE INDEX document_tags_tag_idx ON document_tags (tag);
+
+CREATE TABLE document_shares
+(
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    account_id  INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    permission  TEXT    NOT NULL CHECK (permission IN ('viewer', 'editor')),
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (document_id, account_id)
+);
+
+CREATE INDEX document_shares_account_id_idx ON document_shares (account_id);
+
+CREATE TABLE collections
 (
     id         SERIAL PRIMARY KEY,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     name       TEXT    NOT NULL,
-    text       TEXT,
-    file_path  TEXT,
-    embedding  VECTOR(384),
-    account_id INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE
+    account_id INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    parent_id  INTEGER REFERENCES collections (id) ON DELETE CASCADE
 );
 
+CREATE INDEX collections_parent_id_idx ON collections (parent_id);
+
+CREATE TABLE collection_documents
+(
+    collection_id INTEGER NOT NULL REFERENCES collections (id) ON DELETE CASCADE,
+    document_id   INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    added_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, document_id)
+);
+
+CREATE INDEX collection_documents_document_id_idx ON collection_documents (document_id);
 
 
 CREATE TABLE chats
+(
+    id                    SERIAL PRIMARY KEY,
+    created_at            TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    account_id            INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    unread_messages       BOOLEAN   DEFAULT false,
+    collection_id         INTEGER REFERENCES collections (id) ON DELETE SET NULL,
+    deleted_at            TIMESTAMP,
+    last_message_sequence INTEGER NOT NULL DEFAULT 0,
+    active_message_id     UUID,
+    title                 TEXT      NOT NULL DEFAULT '',
+    title_edited          BOOLEAN   NOT NULL DEFAULT false,
+    pinned                BOOLEAN   NOT NULL DEFAULT false,
+    archived              BOOLEAN   NOT NULL DEFAULT false,
+    updated_at            TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX chats_account_id_activity_idx ON chats (account_id, pinned DESC, updated_at DESC) WHERE deleted_at IS NULL;
+
+CREATE INDEX chats_deleted_at_idx ON chats (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE share_links
+(
+    id             SERIAL PRIMARY KEY,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    token_hash     TEXT    NOT NULL UNIQUE,
+    account_id     INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    document_id    INTEGER REFERENCES documents (id) ON DELETE CASCADE,
+    chat_id        INTEGER REFERENCES chats (id) ON DELETE CASCADE,
+    expires_at     TIMESTAMP,
+    password_hash  TEXT,
+    allow_download BOOLEAN NOT NULL DEFAULT false,
+    revoked_at     TIMESTAMP,
+    CHECK ((document_id IS NULL) <> (chat_id IS NULL))
+);
+
+CREATE TABLE account_usage
+(
+    account_id     INTEGER PRIMARY KEY REFERENCES accounts (id) ON DELETE CASCADE,
+    document_count INTEGER NOT NULL DEFAULT 0,
+    stored_bytes   BIGINT  NOT NULL DEFAULT 0,
+    chat_count     INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE account_message_usage
+(
+    account_id    INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    day           DATE    NOT NULL,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (account_id, day)
+);
+
+CREATE TABLE pending_blob_deletions
 (
     id              SERIAL PRIMARY KEY,
     created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    messages        JSONB,
-    account_id      INTEGER NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
-    unread_messages BOOLEAN   DEFAULT false
+    object_name     TEXT    NOT NULL UNIQUE,
+    is_prefix       BOOLEAN NOT NULL DEFAULT false,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    last_attempt_at TIMESTAMP
+);
+
+CREATE TABLE chat_replies
+(
+    reply_id   TEXT    NOT NULL,
+    chat_id    INTEGER NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, reply_id)
+);
+
+CREATE TABLE chat_messages
+(
+    id         UUID PRIMARY KEY   DEFAULT gen_random_uuid(),
+    chat_id    INTEGER   NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    sequence   INTEGER   NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sender     TEXT      NOT NULL,
+    text       TEXT      NOT NULL,
+    parent_id         UUID REFERENCES chat_messages (id) ON DELETE CASCADE,
+    model             TEXT,
+    prompt_tokens     INTEGER,
+    completion_tokens INTEGER,
+    latency_ms        INTEGER,
+    UNIQUE (chat_id, sequence)
+);
+
+CREATE INDEX chat_messages_parent_id_idx ON chat_messages (parent_id);
+
+ALTER TABLE chats
+    ADD FOREIGN KEY (active_message_id) REFERENCES chat_messages (id) ON DELETE SET NULL;
+
+CREATE TABLE chat_documents
+(
+    chat_id     INTEGER NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    attached_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, document_id)
+);
+
+CREATE INDEX chat_documents_document_id_idx ON chat_documents (document_id);
+
+CREATE TABLE chat_message_citations
+(
+    message_id  UUID    NOT NULL REFERENCES chat_messages (id) ON DELETE CASCADE,
+    position    INTEGER NOT NULL,
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    page_number INTEGER,
+    chunk_index INTEGER,
+    quote       TEXT    NOT NULL DEFAULT '',
+    score       REAL,
+    PRIMARY KEY (message_id, position)
 );
 
+CREATE INDEX chat_message_citations_document_id_idx ON chat_message_citations (document_id);
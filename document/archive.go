@@ -0,0 +1,126 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/labstack/gommon/log"
+)
+
+// maxArchivePartSize bounds how much of a single archive entry is read, so a
+// crafted office document or e-book cannot exhaust memory.
+const maxArchivePartSize = 64 << 20
+
+const relationshipsNamespace = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+// relationship is an entry of an OOXML .rels part.
+type relationship struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+	Mode   string `xml:"TargetMode,attr"`
+}
+
+// openArchive opens an in-memory ZIP container such as DOCX, PPTX, XLSX, ODT or EPUB.
+func openArchive(data []byte) (*zip.Reader, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	return reader, nil
+}
+
+// findArchiveFile looks up an entry by name, ignoring a leading slash.
+func findArchiveFile(archive *zip.Reader, name string) *zip.File {
+	name = strings.TrimPrefix(name, "/")
+	for _, file := range archive.File {
+		if file.Name == name {
+			return file
+		}
+	}
+	return nil
+}
+
+// readArchiveFile returns the content of an archive entry.
+func readArchiveFile(archive *zip.Reader, name string) ([]byte, error) {
+	file := findArchiveFile(archive, name)
+	if file == nil {
+		return nil, fmt.Errorf("archive entry %s not found", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive entry %s: %w", name, err)
+	}
+	defer func(reader io.ReadCloser) {
+		if err := reader.Close(); err != nil {
+			log.Error(err)
+		}
+	}(reader)
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxArchivePartSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive entry %s: %w", name, err)
+	}
+	if len(data) > maxArchivePartSize {
+		return nil, fmt.Errorf("archive entry %s is too large", name)
+	}
+	return data, nil
+}
+
+// readRelationships reads the relationships of an OOXML part, keyed by relationship ID.
+// Missing .rels parts yield an empty map.
+func readRelationships(archive *zip.Reader, partName string) (map[string]relationship, error) {
+	relationshipsPath := path.Join(path.Dir(partName), "_rels", path.Base(partName)+".rels")
+	relationships := map[string]relationship{}
+	if findArchiveFile(archive, relationshipsPath) == nil {
+		return relationships, nil
+	}
+
+	data, err := readArchiveFile(archive, relationshipsPath)
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Relationships []relationship `xml:"Relationship"`
+	}
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", relationshipsPath, err)
+	}
+	for _, rel := range parsed.Relationships {
+		relationships[rel.ID] = rel
+	}
+	return relationships, nil
+}
+
+// resolvePartTarget resolves a relationship target relative to the part that references it.
+func resolvePartTarget(partName string, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(target, "/")
+	}
+	return path.Join(path.Dir(partName), target)
+}
+
+// relationshipAttr returns the value of an r:id style attribute.
+func relationshipAttr(attrs []xml.Attr, local string) string {
+	for _, attr := range attrs {
+		if attr.Name.Space == relationshipsNamespace && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// xmlAttr returns the value of the attribute with the given local name, regardless of namespace.
+func xmlAttr(attrs []xml.Attr, local string) string {
+	for _, attr := range attrs {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
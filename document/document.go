@@ -1,60 +1,75 @@
 package document
 
 import (
-	"bytes"
+	"bufio"
+	"cloud-solutions-api/encryption"
 	"context"
-	"encoding/xml"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/labstack/gommon/log"
 	"io"
 	"math/rand/v2"
 	"mime/multipart"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
-	"github.com/gen2brain/go-fitz"
-	"github.com/nguyenthenguyen/docx"
+	"google.golang.org/api/iterator"
 )
 
 type extension string
 
 const (
-	PDF  extension = ".pdf"
-	TXT  extension = ".txt"
-	MD   extension = ".md"
-	DOCX extension = ".docx"
+	PDF   extension = ".pdf"
+	TXT   extension = ".txt"
+	MD    extension = ".md"
+	DOCX  extension = ".docx"
+	HTML  extension = ".html"
+	HTM   extension = ".htm"
+	XHTML extension = ".xhtml"
+	RTF   extension = ".rtf"
+	ODT   extension = ".odt"
+	PPTX  extension = ".pptx"
+	XLSX  extension = ".xlsx"
+	CSV   extension = ".csv"
+	EPUB  extension = ".epub"
 )
 
-// SaveDocumentFileInBucket uploads a file to GCP Cloud Storage and returns the file URL or an error.
-func SaveDocumentFileInBucket(fileHeader *multipart.FileHeader, bucket *storage.BucketHandle) (string, error) {
-	ctx := context.Background()
-	src, err := fileHeader.Open()
-	if err != nil {
-		return "", fmt.Errorf("failed to open fileHeader: %w", err)
-	}
-	defer func(src multipart.File) {
-		if err := src.Close(); err != nil {
-			log.Error(err)
-		}
-	}(src)
+// UploadsPrefix is the prefix of the objects holding uploaded document files.
+const UploadsPrefix = "uploads/"
 
-	object := bucket.Object(fmt.Sprintf("uploads/%d-%x-%s", time.Now().Unix(), rand.IntN(65535), fileHeader.Filename))
-	writer := object.NewWriter(ctx)
+// StoredObject is an object of the bucket.
+type StoredObject struct {
+	Name    string
+	Created time.Time
+}
 
-	if _, err = io.Copy(writer, src); err != nil {
-		return "", fmt.Errorf("failed to copy fileHeader to storage: %w", err)
+// SaveDocumentDataInBucket uploads the content of a document file to GCP Cloud Storage and returns
+// the file URL or an error. The content is encrypted with dataKey unless it is nil.
+func SaveDocumentDataInBucket(
+	filename string,
+	contentType string,
+	data []byte,
+	dataKey []byte,
+	bucket *storage.BucketHandle,
+) (string, error) {
+	name := uploadObjectName(filename)
+	if err := SaveObjectInBucket(name, contentType, data, dataKey, bucket); err != nil {
+		return "", err
 	}
+	return objectURL(name, bucket), nil
+}
 
-	if err := writer.Close(); err != nil {
-		log.Error(err)
-		return "", fmt.Errorf("failed to close writer: %w", err)
-	}
+// uploadObjectName returns a unique object name for an uploaded document file.
+func uploadObjectName(filename string) string {
+	return fmt.Sprintf("%s%d-%x-%s", UploadsPrefix, time.Now().Unix(), rand.IntN(65535), filename)
+}
 
-	url := fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucket.BucketName(), object.ObjectName())
-	return url, nil
+// objectURL returns the URL documents store for an object of the bucket.
+func objectURL(name string, bucket *storage.BucketHandle) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucket.BucketName(), name)
 }
 
 // extractFilePath extracts the GCS file path from the full URL
@@ -70,6 +85,16 @@ func extractFilePath(gcsURL string, bucket *storage.BucketHandle) (string, error
 	return path, nil
 }
 
+// ObjectNameFromURL returns the name of the object a document file URL points to.
+func ObjectNameFromURL(fileURL string, bucket *storage.BucketHandle) (string, error) {
+	return extractFilePath(fileURL, bucket)
+}
+
+// ObjectURL returns the document file URL of an object of the bucket.
+func ObjectURL(name string, bucket *storage.BucketHandle) string {
+	return objectURL(name, bucket)
+}
+
 // DeleteDocumentFileFromBucket deletes a file from GCP Cloud Storage and optionally returns an error
 func DeleteDocumentFileFromBucket(fileURL string, bucket *storage.BucketHandle) error {
 	ctx := context.Background()
@@ -84,120 +109,173 @@ func DeleteDocumentFileFromBucket(fileURL string, bucket *storage.BucketHandle)
 	return nil
 }
 
-// ExtractTextFromDocumentFile extracts text content from a document file based on its file extension.
-// Supported formats include plain text (TXT, MD) and PDF. Unsupported formats return an error.
-func ExtractTextFromDocumentFile(fileHeader *multipart.FileHeader) (string, error) {
-	fileExtension := extension(filepath.Ext(fileHeader.Filename))
-
-	file, err := fileHeader.Open()
+// ReadDocumentFileFromBucket downloads the content of a document file given its URL, see
+// ReadObjectFromBucket.
+func ReadDocumentFileFromBucket(fileURL string, dataKey []byte, bucket *storage.BucketHandle) ([]byte, error) {
+	filePath, err := extractFilePath(fileURL, bucket)
 	if err != nil {
-		return "", fmt.Errorf("failed to open fileHeader: %w", err)
-	}
-	defer func(file multipart.File) {
-		if err := file.Close(); err != nil {
-			log.Error(err)
-		}
-	}(file)
-	data, err := io.ReadAll(file)
-
-	switch fileExtension {
-	case TXT:
-		return ExtractTextFromPlainText(data), nil
-	case MD:
-		return ExtractTextFromPlainText(data), nil
-	case PDF:
-		return ExtractTextFromPDF(data)
-	case DOCX:
-		return ExtractTextFromDocx(data)
+		return nil, fmt.Errorf("failed to extract file path from URL: %w", err)
 	}
-	return "", errors.New("unsupported format")
+	return ReadObjectFromBucket(filePath, dataKey, bucket)
 }
 
-// ExtractTextFromPlainText reads the content of a plain text file and returns it as a string.
-// It takes the file path as input and returns an error if the operation fails.
-func ExtractTextFromPlainText(data []byte) string {
-	return string(data)
-}
+// SaveObjectInBucket writes data to the named object, replacing it if it exists. When dataKey is not
+// nil the data is encrypted with it as it is written, and the object gets a generic content type.
+func SaveObjectInBucket(name string, contentType string, data []byte, dataKey []byte, bucket *storage.BucketHandle) error {
+	writer := bucket.Object(name).NewWriter(context.Background())
+	writer.ContentType = contentType
 
-// ExtractTextFromPDF extracts text from a PDF file and returns it as a string.
-func ExtractTextFromPDF(data []byte) (string, error) {
-	// Open the PDF file
-	doc, err := fitz.NewFromReader(bytes.NewReader(data))
-	if err != nil {
-		return "", fmt.Errorf("failed to open PDF: %v", err)
-	}
-	defer func(doc *fitz.Document) {
-		if err := doc.Close(); err != nil {
-			log.Error(err)
-		}
-	}(doc)
-
-	// Extract text from each page
-	var extractedText string
-	for i := 0; i < doc.NumPage(); i++ {
-		text, err := doc.Text(i)
+	var dst io.Writer = writer
+	var encryptionWriter *encryption.Writer
+	if dataKey != nil {
+		var err error
+		encryptionWriter, err = encryption.NewWriter(writer, dataKey)
 		if err != nil {
-			return "", fmt.Errorf("failed to extract text from page %d: %v", i+1, err)
+			return fmt.Errorf("failed to encrypt object %s: %w", name, err)
 		}
-		extractedText += text + "\n"
+		writer.ContentType = "application/octet-stream"
+		dst = encryptionWriter
 	}
 
-	return extractedText, nil
+	if _, err := dst.Write(data); err != nil {
+		return fmt.Errorf("failed to write object %s: %w", name, err)
+	}
+	if encryptionWriter != nil {
+		if err := encryptionWriter.Close(); err != nil {
+			return fmt.Errorf("failed to write object %s: %w", name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+	return nil
 }
 
-// ExtractTextFromDocx extracts text from a DOCX file given as []byte.
-func ExtractTextFromDocx(data []byte) (string, error) {
-	// Read DOCX from bytes
-	reader := bytes.NewReader(data)
-
-	// Read DOCX from memory
-	doc, err := docx.ReadDocxFromMemory(reader, int64(len(data)))
+// ReadObjectFromBucket reads the named object, decrypting it with dataKey as it is read when it is
+// encrypted. Objects written before encryption was enabled are read as they are. Missing objects
+// return storage.ErrObjectNotExist.
+func ReadObjectFromBucket(name string, dataKey []byte, bucket *storage.BucketHandle) ([]byte, error) {
+	reader, err := bucket.Object(name).NewReader(context.Background())
 	if err != nil {
-		return "", fmt.Errorf("failed to open DOCX from bytes: %w", err)
+		return nil, fmt.Errorf("failed to open object %s: %w", name, err)
 	}
-	defer func(doc *docx.ReplaceDocx) {
-		if err := doc.Close(); err != nil {
+	defer func(reader *storage.Reader) {
+		if err := reader.Close(); err != nil {
 			log.Error(err)
 		}
-	}(doc)
+	}(reader)
 
-	// Extract raw text
-	text := doc.Editable().GetContent()
+	src, err := decryptingReader(reader, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", name, err)
+	}
 
-	// Remove XML tags (if needed)
-	cleanText, err := removeXMLTags(text)
+	data, err := io.ReadAll(src)
 	if err != nil {
-		return "", fmt.Errorf("failed to remove XML tags: %w", err)
+		return nil, fmt.Errorf("failed to read object %s: %w", name, err)
 	}
+	return data, nil
+}
 
-	return cleanText, nil
+// decryptingReader returns a reader decrypting src with dataKey when src is encrypted, and src
+// itself otherwise.
+func decryptingReader(src io.Reader, dataKey []byte) (io.Reader, error) {
+	buffered := bufio.NewReader(src)
+	header, err := buffered.Peek(encryption.MagicSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !encryption.IsEncrypted(header) {
+		return buffered, nil
+	}
+	if dataKey == nil {
+		return nil, errors.New("object is encrypted but no key was given")
+	}
+	return encryption.NewReader(buffered, dataKey)
 }
 
-// removeXMLTags removes all XML tags and returns the text content.
-func removeXMLTags(xmlContent string) (string, error) {
-	// Create a decoder for the XML content
-	decoder := xml.NewDecoder(strings.NewReader(xmlContent))
+// DeleteObjectFromBucket deletes the named object. Deleting an object that does not exist succeeds.
+func DeleteObjectFromBucket(name string, bucket *storage.BucketHandle) error {
+	err := bucket.Object(name).Delete(context.Background())
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete object %s: %w", name, err)
+	}
+	return nil
+}
 
-	// Buffer to store the extracted text
-	var textContent bytes.Buffer
+// ListObjectsWithPrefix lists every object whose name starts with prefix.
+func ListObjectsWithPrefix(prefix string, bucket *storage.BucketHandle) ([]StoredObject, error) {
+	var objects []StoredObject
+	iter := bucket.Objects(context.Background(), &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects with prefix %s: %w", prefix, err)
+		}
+		objects = append(objects, StoredObject{Name: attrs.Name, Created: attrs.Created})
+	}
+}
 
-	// Iterate through the XML tokens
+// DeleteObjectsWithPrefix deletes every object whose name starts with prefix.
+func DeleteObjectsWithPrefix(prefix string, bucket *storage.BucketHandle) error {
+	ctx := context.Background()
+	objects := bucket.Objects(ctx, &storage.Query{Prefix: prefix})
 	for {
-		token, err := decoder.Token()
-		if err == io.EOF {
-			break // End of XML content
+		attrs, err := objects.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
 		}
 		if err != nil {
-			return "", fmt.Errorf("failed to decode XML: %v", err)
+			return fmt.Errorf("failed to list objects with prefix %s: %w", prefix, err)
 		}
+		if err := bucket.Object(attrs.Name).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+			return fmt.Errorf("failed to delete object %s: %w", attrs.Name, err)
+		}
+	}
+}
 
-		// Extract text content from character data
-		switch t := token.(type) {
-		case xml.CharData:
-			textContent.Write(t)
-			textContent.WriteRune('\n')
+// ReadFileHeader reads the whole content of an uploaded file.
+func ReadFileHeader(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fileHeader: %w", err)
+	}
+	defer func(file multipart.File) {
+		if err := file.Close(); err != nil {
+			log.Error(err)
 		}
+	}(file)
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fileHeader: %w", err)
+	}
+	return data, nil
+}
+
+// ContentHash returns the hex encoded SHA-256 digest of a file, used to detect re-uploads of identical content.
+func ContentHash(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+// ExtractDocumentFile extracts the content of a document file using the extractor
+// registered for its file extension, falling back to its MIME type. Unsupported formats
+// return ErrUnsupportedFormat.
+func ExtractDocumentFile(fileHeader *multipart.FileHeader, options Options) (Extraction, error) {
+	data, err := ReadFileHeader(fileHeader)
+	if err != nil {
+		return Extraction{}, err
 	}
 
-	return strings.TrimSpace(textContent.String()), nil
+	return Extract(fileHeader.Filename, fileHeader.Header.Get("Content-Type"), data, options)
+}
+
+// ExtractTextFromPlainText reads the content of a plain text file and returns it as a string.
+// It takes the file path as input and returns an error if the operation fails.
+func ExtractTextFromPlainText(data []byte) string {
+	return string(data)
 }
@@ -0,0 +1,691 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const wordMLNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+const (
+	// maxListNumber bounds list counters, which Word keeps below 32768 as well.
+	maxListNumber = 32767
+	// maxLetterNumber is the highest list number rendered with letters, higher ones are rendered
+	// as decimals.
+	maxLetterNumber = 260
+)
+
+// DocxOptions controls which parts of a DOCX file are extracted besides the main body.
+type DocxOptions struct {
+	IncludeHeadersAndFooters bool
+	IncludeFootnotes         bool
+}
+
+// ExtractTextFromDocx extracts text from a DOCX file, joining the runs of each paragraph and
+// keeping the document structure: headings become Markdown headings, tables become Markdown
+// tables and numbered or bulleted paragraphs keep their list markers.
+func ExtractTextFromDocx(data []byte, options DocxOptions) (string, error) {
+	archive, err := openArchive(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to open DOCX from bytes: %w", err)
+	}
+
+	const documentPath = "word/document.xml"
+	documentXML, err := readArchiveFile(archive, documentPath)
+	if err != nil {
+		return "", err
+	}
+
+	styles, err := readDocxStyles(archive)
+	if err != nil {
+		return "", err
+	}
+	numbering, err := readDocxNumbering(archive)
+	if err != nil {
+		return "", err
+	}
+	parser := &docxParser{
+		styles:           styles,
+		numbering:        numbering,
+		counters:         map[string][]int{},
+		includeFootnotes: options.IncludeFootnotes,
+	}
+
+	body, err := parser.parsePart(documentXML)
+	if err != nil {
+		return "", err
+	}
+
+	sections := []string{}
+	var headers, footers []string
+	if options.IncludeHeadersAndFooters {
+		headers, footers, err = parser.parseHeadersAndFooters(archive, documentPath)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(headers) > 0 {
+		sections = append(sections, strings.Join(headers, "\n\n"))
+	}
+	sections = append(sections, body.text)
+	if len(footers) > 0 {
+		sections = append(sections, strings.Join(footers, "\n\n"))
+	}
+
+	text := strings.Join(sections, "\n\n---\n\n")
+
+	if options.IncludeFootnotes {
+		notes, err := parser.parseNotes(archive)
+		if err != nil {
+			return "", err
+		}
+		if notes != "" {
+			text += "\n\n" + notes
+		}
+	}
+
+	return strings.TrimSpace(text), nil
+}
+
+// docxStyle is the part of a paragraph style that matters for extraction.
+type docxStyle struct {
+	basedOn      string
+	outlineLevel int // 0 when the style is not a heading
+	numID        string
+	ilvl         int
+}
+
+var headingStyleName = regexp.MustCompile(`^heading\s*([1-9])$`)
+
+// readDocxStyles reads the paragraph styles of word/styles.xml keyed by style ID.
+func readDocxStyles(archive *zip.Reader) (map[string]docxStyle, error) {
+	styles := map[string]docxStyle{}
+	const stylesPath = "word/styles.xml"
+	if findArchiveFile(archive, stylesPath) == nil {
+		return styles, nil
+	}
+	stylesXML, err := readArchiveFile(archive, stylesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed struct {
+		Styles []struct {
+			Type    string   `xml:"type,attr"`
+			ID      string   `xml:"styleId,attr"`
+			Name    docxVal  `xml:"name"`
+			BasedOn docxVal  `xml:"basedOn"`
+			Outline *docxVal `xml:"pPr>outlineLvl"`
+			NumID   docxVal  `xml:"pPr>numPr>numId"`
+			Ilvl    docxVal  `xml:"pPr>numPr>ilvl"`
+		} `xml:"style"`
+	}
+	if err := xml.Unmarshal(stylesXML, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse DOCX styles: %w", err)
+	}
+
+	for _, style := range parsed.Styles {
+		if style.Type != "" && style.Type != "paragraph" {
+			continue
+		}
+		s := docxStyle{basedOn: style.BasedOn.Val, numID: style.NumID.Val}
+		s.ilvl = listLevel(style.Ilvl.Val)
+		name := strings.ToLower(strings.TrimSpace(style.Name.Val))
+		if match := headingStyleName.FindStringSubmatch(name); match != nil {
+			s.outlineLevel, _ = strconv.Atoi(match[1])
+		} else if name == "title" {
+			s.outlineLevel = 1
+		} else if style.Outline != nil {
+			if level, err := strconv.Atoi(style.Outline.Val); err == nil && level < 9 {
+				s.outlineLevel = level + 1
+			}
+		}
+		styles[style.ID] = s
+	}
+	return styles, nil
+}
+
+// headingLevel returns the heading level of a style, following basedOn links.
+func (p *docxParser) headingLevel(styleID string) int {
+	for depth := 0; styleID != "" && depth < 16; depth++ {
+		style, ok := p.styles[styleID]
+		if !ok {
+			return 0
+		}
+		if style.outlineLevel > 0 {
+			return style.outlineLevel
+		}
+		styleID = style.basedOn
+	}
+	return 0
+}
+
+// styleNumbering returns the list numbering attached to a style, following basedOn links.
+func (p *docxParser) styleNumbering(styleID string) (string, int) {
+	for depth := 0; styleID != "" && depth < 16; depth++ {
+		style, ok := p.styles[styleID]
+		if !ok {
+			return "", 0
+		}
+		if style.numID != "" {
+			return style.numID, style.ilvl
+		}
+		styleID = style.basedOn
+	}
+	return "", 0
+}
+
+type docxVal struct {
+	Val string `xml:"val,attr"`
+}
+
+type docxLevel struct {
+	start    int
+	format   string
+	template string
+}
+
+// listLevel parses a list level, which Word numbers from 0 to 8.
+func listLevel(val string) int {
+	level, _ := strconv.Atoi(val)
+	return min(max(level, 0), 8)
+}
+
+// listStart parses the number a list level starts at, 1 when it is missing or invalid.
+func listStart(val string) int {
+	start, err := strconv.Atoi(val)
+	if err != nil {
+		return 1
+	}
+	return min(max(start, 0), maxListNumber)
+}
+
+// docxNumbering maps numbering instances (numId) to their level definitions.
+type docxNumbering map[string]map[int]docxLevel
+
+// readDocxNumbering reads word/numbering.xml, resolving each numId to its abstract definition.
+func readDocxNumbering(archive *zip.Reader) (docxNumbering, error) {
+	numbering := docxNumbering{}
+	const numberingPath = "word/numbering.xml"
+	if findArchiveFile(archive, numberingPath) == nil {
+		return numbering, nil
+	}
+	numberingXML, err := readArchiveFile(archive, numberingPath)
+	if err != nil {
+		return nil, err
+	}
+
+	type level struct {
+		Ilvl    string  `xml:"ilvl,attr"`
+		Start   docxVal `xml:"start"`
+		NumFmt  docxVal `xml:"numFmt"`
+		LvlText docxVal `xml:"lvlText"`
+	}
+	var parsed struct {
+		AbstractNums []struct {
+			ID     string  `xml:"abstractNumId,attr"`
+			Levels []level `xml:"lvl"`
+		} `xml:"abstractNum"`
+		Nums []struct {
+			ID         string  `xml:"numId,attr"`
+			AbstractID docxVal `xml:"abstractNumId"`
+			Overrides  []struct {
+				Ilvl          string  `xml:"ilvl,attr"`
+				StartOverride docxVal `xml:"startOverride"`
+				Level         *level  `xml:"lvl"`
+			} `xml:"lvlOverride"`
+		} `xml:"num"`
+	}
+	if err := xml.Unmarshal(numberingXML, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse DOCX numbering: %w", err)
+	}
+
+	toLevel := func(l level) docxLevel {
+		return docxLevel{start: listStart(l.Start.Val), format: l.NumFmt.Val, template: l.LvlText.Val}
+	}
+
+	abstract := map[string]map[int]docxLevel{}
+	for _, definition := range parsed.AbstractNums {
+		levels := map[int]docxLevel{}
+		for _, l := range definition.Levels {
+			ilvl := listLevel(l.Ilvl)
+			levels[ilvl] = toLevel(l)
+		}
+		abstract[definition.ID] = levels
+	}
+
+	for _, num := range parsed.Nums {
+		levels := map[int]docxLevel{}
+		for ilvl, l := range abstract[num.AbstractID.Val] {
+			levels[ilvl] = l
+		}
+		for _, override := range num.Overrides {
+			ilvl := listLevel(override.Ilvl)
+			if override.Level != nil {
+				levels[ilvl] = toLevel(*override.Level)
+			}
+			if override.StartOverride.Val != "" {
+				l := levels[ilvl]
+				l.start = listStart(override.StartOverride.Val)
+				levels[ilvl] = l
+			}
+		}
+		numbering[num.ID] = levels
+	}
+	return numbering, nil
+}
+
+// docxParser turns WordprocessingML parts into Markdown-flavored text. List counters are
+// shared across parts so numbering continues as it does in Word.
+type docxParser struct {
+	styles           map[string]docxStyle
+	numbering        docxNumbering
+	counters         map[string][]int
+	includeFootnotes bool
+}
+
+type docxParagraph struct {
+	text         strings.Builder
+	styleID      string
+	numID        string
+	ilvl         int
+	hasNumbering bool
+	outlineLevel int
+}
+
+type docxTable struct {
+	rows [][]string
+	cell *textBuilder
+}
+
+// docxPartResult is the text of a part, plus the notes it defines when the part is
+// a footnotes or endnotes part.
+type docxPartResult struct {
+	text  string
+	notes []docxNote
+}
+
+type docxNote struct {
+	label string
+	text  string
+}
+
+func isWordElement(name xml.Name, local string) bool {
+	return name.Space == wordMLNamespace && name.Local == local
+}
+
+// parsePart extracts the text of a document, header, footer, footnotes or endnotes part.
+func (p *docxParser) parsePart(partXML []byte) (docxPartResult, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(partXML))
+	text := &textBuilder{}
+	result := docxPartResult{}
+
+	var paragraphs []*docxParagraph
+	var tables []*docxTable
+	var note *textBuilder
+	noteLabel := ""
+	inText := false
+	inParagraphProperties := false
+	inList := false
+	skipDepth := 0
+
+	target := func() *textBuilder {
+		if len(tables) > 0 && tables[len(tables)-1].cell != nil {
+			return tables[len(tables)-1].cell
+		}
+		if note != nil {
+			return note
+		}
+		return text
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to decode DOCX part: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+			// Alternate content repeats the preferred choice for older readers.
+			if t.Name.Local == "Fallback" {
+				skipDepth = 1
+				continue
+			}
+			var paragraph *docxParagraph
+			if len(paragraphs) > 0 {
+				paragraph = paragraphs[len(paragraphs)-1]
+			}
+
+			switch {
+			case isWordElement(t.Name, "footnote"), isWordElement(t.Name, "endnote"):
+				noteType := xmlAttr(t.Attr, "type")
+				if noteType == "separator" || noteType == "continuationSeparator" || noteType == "continuationNotice" {
+					skipDepth = 1
+					continue
+				}
+				note = &textBuilder{}
+				noteLabel = docxNoteLabel(t.Name.Local, xmlAttr(t.Attr, "id"))
+			case isWordElement(t.Name, "p"):
+				paragraphs = append(paragraphs, &docxParagraph{})
+			case isWordElement(t.Name, "pPr"):
+				inParagraphProperties = true
+			case isWordElement(t.Name, "rPr"), isWordElement(t.Name, "sectPr"):
+				// Run and section properties contain elements named like paragraph properties.
+				skipDepth = 1
+			case isWordElement(t.Name, "pStyle") && inParagraphProperties && paragraph != nil:
+				paragraph.styleID = xmlAttr(t.Attr, "val")
+			case isWordElement(t.Name, "numId") && inParagraphProperties && paragraph != nil:
+				paragraph.numID = xmlAttr(t.Attr, "val")
+				paragraph.hasNumbering = true
+			case isWordElement(t.Name, "ilvl") && inParagraphProperties && paragraph != nil:
+				paragraph.ilvl = listLevel(xmlAttr(t.Attr, "val"))
+			case isWordElement(t.Name, "outlineLvl") && inParagraphProperties && paragraph != nil:
+				if level, err := strconv.Atoi(xmlAttr(t.Attr, "val")); err == nil && level < 9 {
+					paragraph.outlineLevel = level + 1
+				}
+			case isWordElement(t.Name, "t"):
+				inText = true
+			case isWordElement(t.Name, "tab") && !inParagraphProperties && paragraph != nil:
+				paragraph.text.WriteString("\t")
+			case (isWordElement(t.Name, "br") || isWordElement(t.Name, "cr")) && paragraph != nil:
+				paragraph.text.WriteString("\n")
+			case isWordElement(t.Name, "noBreakHyphen") && paragraph != nil:
+				paragraph.text.WriteString("-")
+			case isWordElement(t.Name, "footnoteReference"), isWordElement(t.Name, "endnoteReference"):
+				if p.includeFootnotes && paragraph != nil {
+					local := strings.TrimSuffix(t.Name.Local, "Reference")
+					paragraph.text.WriteString("[^" + docxNoteLabel(local, xmlAttr(t.Attr, "id")) + "]")
+				}
+			case isWordElement(t.Name, "delText"), isWordElement(t.Name, "instrText"),
+				isWordElement(t.Name, "footnoteRef"), isWordElement(t.Name, "endnoteRef"):
+				skipDepth = 1
+			case isWordElement(t.Name, "tbl"):
+				tables = append(tables, &docxTable{})
+			case isWordElement(t.Name, "tr") && len(tables) > 0:
+				tables[len(tables)-1].rows = append(tables[len(tables)-1].rows, []string{})
+			case isWordElement(t.Name, "tc") && len(tables) > 0:
+				tables[len(tables)-1].cell = &textBuilder{}
+			}
+
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			switch {
+			case isWordElement(t.Name, "t"):
+				inText = false
+			case isWordElement(t.Name, "pPr"):
+				inParagraphProperties = false
+			case isWordElement(t.Name, "p") && len(paragraphs) > 0:
+				paragraph := paragraphs[len(paragraphs)-1]
+				paragraphs = paragraphs[:len(paragraphs)-1]
+				inList = p.writeParagraph(target(), paragraph, inList)
+			case isWordElement(t.Name, "tc") && len(tables) > 0:
+				table := tables[len(tables)-1]
+				if table.cell != nil && len(table.rows) > 0 {
+					table.rows[len(table.rows)-1] = append(table.rows[len(table.rows)-1], table.cell.String())
+				}
+				table.cell = nil
+			case isWordElement(t.Name, "tbl") && len(tables) > 0:
+				table := tables[len(tables)-1]
+				tables = tables[:len(tables)-1]
+				out := target()
+				out.ParagraphBreak()
+				if len(tables) > 0 {
+					// Nested tables cannot be expressed in Markdown; flatten them into the outer cell.
+					for _, row := range table.rows {
+						out.WriteText(strings.Join(row, " "))
+						out.LineBreak()
+					}
+				} else {
+					out.WriteRaw(renderMarkdownTable(table.rows))
+				}
+				out.ParagraphBreak()
+				inList = false
+			case (isWordElement(t.Name, "footnote") || isWordElement(t.Name, "endnote")) && note != nil:
+				if noteText := note.String(); noteText != "" {
+					result.notes = append(result.notes, docxNote{label: noteLabel, text: noteText})
+				}
+				note = nil
+				inList = false
+			}
+
+		case xml.CharData:
+			if inText && skipDepth == 0 && len(paragraphs) > 0 {
+				paragraphs[len(paragraphs)-1].text.Write(t)
+			}
+		}
+	}
+
+	result.text = text.String()
+	return result, nil
+}
+
+// writeParagraph writes a finished paragraph as a heading, list item or plain paragraph
+// and reports whether the output is now inside a list.
+func (p *docxParser) writeParagraph(out *textBuilder, paragraph *docxParagraph, inList bool) bool {
+	content := strings.TrimSpace(paragraph.text.String())
+	if content == "" {
+		return inList
+	}
+
+	level := paragraph.outlineLevel
+	if level == 0 {
+		level = p.headingLevel(paragraph.styleID)
+	}
+	numID, ilvl := paragraph.numID, paragraph.ilvl
+	if !paragraph.hasNumbering {
+		numID, ilvl = p.styleNumbering(paragraph.styleID)
+	}
+	if numID == "0" {
+		// numId 0 explicitly removes numbering inherited from the style.
+		numID = ""
+	}
+
+	switch {
+	case level > 0:
+		out.ParagraphBreak()
+		out.WriteRaw(strings.Repeat("#", min(level, 6)) + " " + content)
+		out.ParagraphBreak()
+		return false
+	case numID != "" && p.numbering[numID] != nil:
+		if !inList {
+			out.ParagraphBreak()
+		}
+		out.LineBreak()
+		out.WriteRaw(strings.Repeat("  ", ilvl) + p.nextListMarker(numID, ilvl) + " " + content)
+		out.LineBreak()
+		return true
+	default:
+		out.ParagraphBreak()
+		out.WriteRaw(content)
+		out.ParagraphBreak()
+		return false
+	}
+}
+
+// nextListMarker advances the counter of a list level and renders its marker, e.g. "2.1." or "-".
+func (p *docxParser) nextListMarker(numID string, ilvl int) string {
+	levels := p.numbering[numID]
+	counters := p.counters[numID]
+	for len(counters) <= ilvl {
+		start := 1
+		if l, ok := levels[len(counters)]; ok {
+			start = l.start
+		}
+		counters = append(counters, start-1)
+	}
+	counters[ilvl] = min(counters[ilvl]+1, maxListNumber)
+	// Deeper levels restart when a shallower item follows them.
+	counters = counters[:ilvl+1]
+	p.counters[numID] = counters
+
+	level, ok := levels[ilvl]
+	if !ok || level.format == "bullet" {
+		return "-"
+	}
+	if level.format == "none" {
+		return ""
+	}
+	marker := level.template
+	if marker == "" {
+		marker = "%" + strconv.Itoa(ilvl+1) + "."
+	}
+	for i := 0; i < len(counters); i++ {
+		placeholder := "%" + strconv.Itoa(i+1)
+		if !strings.Contains(marker, placeholder) {
+			continue
+		}
+		format := level.format
+		if l, ok := levels[i]; ok && i != ilvl {
+			format = l.format
+		}
+		marker = strings.ReplaceAll(marker, placeholder, formatListNumber(counters[i], format))
+	}
+	return marker
+}
+
+func formatListNumber(value int, format string) string {
+	switch format {
+	case "lowerLetter":
+		return strings.ToLower(letterNumber(value))
+	case "upperLetter":
+		return letterNumber(value)
+	case "lowerRoman":
+		return strings.ToLower(romanNumber(value))
+	case "upperRoman":
+		return romanNumber(value)
+	default:
+		return strconv.Itoa(value)
+	}
+}
+
+// letterNumber renders 1, 2, ..., 26, 27 as A, B, ..., Z, AA like Word does.
+func letterNumber(value int) string {
+	if value < 1 || value > maxLetterNumber {
+		return strconv.Itoa(value)
+	}
+	letter := string(rune('A' + (value-1)%26))
+	return strings.Repeat(letter, (value-1)/26+1)
+}
+
+func romanNumber(value int) string {
+	if value < 1 || value > 3999 {
+		return strconv.Itoa(value)
+	}
+	numerals := []struct {
+		value  int
+		symbol string
+	}{
+		{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+		{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	}
+	var roman strings.Builder
+	for _, numeral := range numerals {
+		for value >= numeral.value {
+			roman.WriteString(numeral.symbol)
+			value -= numeral.value
+		}
+	}
+	return roman.String()
+}
+
+func docxNoteLabel(kind string, id string) string {
+	if kind == "endnote" {
+		return "e" + id
+	}
+	return id
+}
+
+// parseHeadersAndFooters extracts the distinct headers and footers referenced by the document.
+func (p *docxParser) parseHeadersAndFooters(archive *zip.Reader, documentPath string) ([]string, []string, error) {
+	relationships, err := readRelationships(archive, documentPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Relationship IDs are sorted so the output does not depend on map iteration order.
+	ids := make([]string, 0, len(relationships))
+	for id := range relationships {
+		ids = append(ids, id)
+	}
+	sortRelationshipIDs(ids)
+
+	var headers, footers []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		rel := relationships[id]
+		isHeader := strings.HasSuffix(rel.Type, "/header")
+		isFooter := strings.HasSuffix(rel.Type, "/footer")
+		if !isHeader && !isFooter {
+			continue
+		}
+		partXML, err := readArchiveFile(archive, resolvePartTarget(documentPath, rel.Target))
+		if err != nil {
+			return nil, nil, err
+		}
+		part, err := p.parsePart(partXML)
+		if err != nil {
+			return nil, nil, err
+		}
+		if part.text == "" || seen[part.text] {
+			continue
+		}
+		seen[part.text] = true
+		if isHeader {
+			headers = append(headers, part.text)
+		} else {
+			footers = append(footers, part.text)
+		}
+	}
+	return headers, footers, nil
+}
+
+// sortRelationshipIDs orders IDs such as rId2 and rId10 numerically.
+func sortRelationshipIDs(ids []string) {
+	number := func(id string) int {
+		value, _ := strconv.Atoi(strings.TrimPrefix(id, "rId"))
+		return value
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		return number(ids[i]) < number(ids[j])
+	})
+}
+
+// parseNotes extracts footnotes and endnotes as Markdown footnote definitions.
+func (p *docxParser) parseNotes(archive *zip.Reader) (string, error) {
+	var definitions []string
+	for _, notesPath := range []string{"word/footnotes.xml", "word/endnotes.xml"} {
+		if findArchiveFile(archive, notesPath) == nil {
+			continue
+		}
+		notesXML, err := readArchiveFile(archive, notesPath)
+		if err != nil {
+			return "", err
+		}
+		part, err := p.parsePart(notesXML)
+		if err != nil {
+			return "", err
+		}
+		for _, note := range part.notes {
+			definitions = append(definitions, "[^"+note.label+"]: "+strings.Join(strings.Fields(note.text), " "))
+		}
+	}
+	return strings.Join(definitions, "\n"), nil
+}
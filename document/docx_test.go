@@ -0,0 +1,112 @@
+package document
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractTextFromDocx(t *testing.T) {
+	tests := []struct {
+		name    string
+		options DocxOptions
+		want    string
+	}{
+		{
+			name: "body only",
+			want: structureBody(""),
+		},
+		{
+			name:    "headers, footers and footnotes",
+			options: DocxOptions{IncludeHeadersAndFooters: true, IncludeFootnotes: true},
+			want: "Company header\n\n---\n\n" + structureBody("[^1]") +
+				"\n\n---\n\nPage footer\n\n[^1]: A footnote.",
+		},
+	}
+	data := readFixture(t, "structure.docx")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractTextFromDocx(data, tt.options)
+			if err != nil {
+				t.Fatalf("ExtractTextFromDocx() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractTextFromDocx() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// structureBody is the text of the body of testdata/structure.docx, with noteMarker after the
+// footnote reference.
+func structureBody(noteMarker string) string {
+	return "# Annual report\n\n## Summary\n\nPlain text\n\n" +
+		"See note" + noteMarker + "\n\n" +
+		"1. First\n  1.a) Nested\n2. Second\n- Dot\n32767. Big\n32767. Bigger\n" +
+		strings.Repeat("  ", 8) + "- Deep\n\n" +
+		"| Name | Qty |\n| --- | --- |\n| Pens | 3 |"
+}
+
+func TestListStart(t *testing.T) {
+	tests := []struct {
+		val  string
+		want int
+	}{
+		{"", 1},
+		{"abc", 1},
+		{"0", 0},
+		{"5", 5},
+		{"-3", 0},
+		{"32767", 32767},
+		{"2000000000", maxListNumber},
+		{"99999999999999999999", 1},
+	}
+	for _, tt := range tests {
+		if got := listStart(tt.val); got != tt.want {
+			t.Errorf("listStart(%q) = %d, want %d", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestListLevel(t *testing.T) {
+	tests := []struct {
+		val  string
+		want int
+	}{
+		{"", 0},
+		{"3", 3},
+		{"-1", 0},
+		{"99", 8},
+	}
+	for _, tt := range tests {
+		if got := listLevel(tt.val); got != tt.want {
+			t.Errorf("listLevel(%q) = %d, want %d", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestFormatListNumber(t *testing.T) {
+	tests := []struct {
+		value  int
+		format string
+		want   string
+	}{
+		{3, "decimal", "3"},
+		{1, "upperLetter", "A"},
+		{26, "upperLetter", "Z"},
+		{27, "upperLetter", "AA"},
+		{28, "lowerLetter", "bb"},
+		{maxLetterNumber, "upperLetter", "ZZZZZZZZZZ"},
+		{maxLetterNumber + 1, "upperLetter", "261"},
+		{0, "lowerLetter", "0"},
+		{4, "upperRoman", "IV"},
+		{1994, "lowerRoman", "mcmxciv"},
+		{3999, "upperRoman", "MMMCMXCIX"},
+		{4000, "upperRoman", "4000"},
+		{maxListNumber, "upperRoman", "32767"},
+	}
+	for _, tt := range tests {
+		if got := formatListNumber(tt.value, tt.format); got != tt.want {
+			t.Errorf("formatListNumber(%d, %q) = %q, want %q", tt.value, tt.format, got, tt.want)
+		}
+	}
+}
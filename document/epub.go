@@ -0,0 +1,96 @@
+package document
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ExtractTextFromEPUB extracts the chapters of an e-book in reading (spine) order.
+func ExtractTextFromEPUB(data []byte) (string, error) {
+	archive, err := openArchive(data)
+	if err != nil {
+		return "", err
+	}
+
+	containerXML, err := readArchiveFile(archive, "META-INF/container.xml")
+	if err != nil {
+		return "", err
+	}
+	var container struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(containerXML, &container); err != nil {
+		return "", fmt.Errorf("failed to parse EPUB container: %w", err)
+	}
+	packagePath := ""
+	for _, rootfile := range container.Rootfiles {
+		if rootfile.MediaType == "" || rootfile.MediaType == "application/oebps-package+xml" {
+			packagePath = rootfile.FullPath
+			break
+		}
+	}
+	if packagePath == "" {
+		return "", fmt.Errorf("EPUB container has no package document")
+	}
+
+	packageXML, err := readArchiveFile(archive, packagePath)
+	if err != nil {
+		return "", err
+	}
+	var pkg struct {
+		Manifest []struct {
+			ID        string `xml:"id,attr"`
+			Href      string `xml:"href,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := xml.Unmarshal(packageXML, &pkg); err != nil {
+		return "", fmt.Errorf("failed to parse EPUB package: %w", err)
+	}
+	hrefs := map[string]string{}
+	for _, item := range pkg.Manifest {
+		hrefs[item.ID] = item.Href
+	}
+
+	var result strings.Builder
+	for _, itemRef := range pkg.Spine {
+		// Non-linear items are auxiliary content such as pop-up footnotes.
+		if itemRef.Linear == "no" {
+			continue
+		}
+		href, ok := hrefs[itemRef.IDRef]
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		chapterXML, err := readArchiveFile(archive, path.Join(path.Dir(packagePath), href))
+		if err != nil {
+			return "", err
+		}
+		chapterText, err := extractTextFromHTMLContent(chapterXML)
+		if err != nil {
+			return "", fmt.Errorf("failed to extract chapter %s: %w", href, err)
+		}
+		if chapterText == "" {
+			continue
+		}
+		if result.Len() > 0 {
+			result.WriteString("\n\n")
+		}
+		result.WriteString(chapterText)
+	}
+
+	return result.String(), nil
+}
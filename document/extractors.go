@@ -0,0 +1,134 @@
+package document
+
+import (
+	"errors"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsupportedFormat is returned when no extractor is registered for a document.
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// Extraction is the result of extracting a document. Pages and Metadata are only set
+// for formats that have a page structure, such as PDF.
+type Extraction struct {
+	Text     string
+	Pages    []string
+	Metadata *SourceMetadata
+}
+
+// Options configures the extractors of formats with optional parts.
+type Options struct {
+	Docx DocxOptions
+}
+
+// Extractor turns the raw bytes of a document into its text.
+type Extractor func(data []byte, options Options) (Extraction, error)
+
+// WithoutOptions adapts the extractor of a format that takes no options into an Extractor.
+func WithoutOptions(extract func(data []byte) (Extraction, error)) Extractor {
+	return func(data []byte, _ Options) (Extraction, error) {
+		return extract(data)
+	}
+}
+
+// TextExtractor adapts a function that only produces plain text into an Extractor.
+func TextExtractor(extract func(data []byte) (string, error)) Extractor {
+	return WithoutOptions(func(data []byte) (Extraction, error) {
+		text, err := extract(data)
+		if err != nil {
+			return Extraction{}, err
+		}
+		return Extraction{Text: text}, nil
+	})
+}
+
+var (
+	extractorsByExtension = map[extension]Extractor{}
+	extractorsByMimeType  = map[string]Extractor{}
+)
+
+// RegisterExtractor makes an extractor available for the given file extensions and MIME types.
+// Registering an extension or MIME type twice replaces the previous extractor.
+func RegisterExtractor(extractor Extractor, extensions []extension, mimeTypes []string) {
+	for _, ext := range extensions {
+		extractorsByExtension[extension(strings.ToLower(string(ext)))] = extractor
+	}
+	for _, mimeType := range mimeTypes {
+		extractorsByMimeType[strings.ToLower(mimeType)] = extractor
+	}
+}
+
+// extractorFor looks up an extractor by the file extension first and falls back to the MIME type.
+func extractorFor(filename string, mimeType string) (Extractor, bool) {
+	fileExtension := extension(strings.ToLower(filepath.Ext(filename)))
+	if extractor, ok := extractorsByExtension[fileExtension]; ok {
+		return extractor, true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return nil, false
+	}
+	extractor, ok := extractorsByMimeType[strings.ToLower(mediaType)]
+	return extractor, ok
+}
+
+// IsSupportedFormat reports whether text can be extracted from a file with the given name and MIME type.
+func IsSupportedFormat(filename string, mimeType string) bool {
+	_, ok := extractorFor(filename, mimeType)
+	return ok
+}
+
+// Extract extracts a document using the extractor registered for its extension or,
+// when the extension is unknown, for its MIME type.
+func Extract(filename string, mimeType string, data []byte, options Options) (Extraction, error) {
+	extractor, ok := extractorFor(filename, mimeType)
+	if !ok {
+		return Extraction{}, ErrUnsupportedFormat
+	}
+	return extractor(data, options)
+}
+
+// ExtractText extracts only the text of a document, see Extract.
+func ExtractText(filename string, mimeType string, data []byte, options Options) (string, error) {
+	extraction, err := Extract(filename, mimeType, data, options)
+	if err != nil {
+		return "", err
+	}
+	return extraction.Text, nil
+}
+
+func init() {
+	plainText := TextExtractor(func(data []byte) (string, error) {
+		return ExtractTextFromPlainText(data), nil
+	})
+
+	RegisterExtractor(plainText, []extension{TXT}, []string{"text/plain"})
+	RegisterExtractor(plainText, []extension{MD}, []string{"text/markdown", "text/x-markdown"})
+	RegisterExtractor(WithoutOptions(ExtractPDF), []extension{PDF}, []string{"application/pdf"})
+	RegisterExtractor(
+		func(data []byte, options Options) (Extraction, error) {
+			text, err := ExtractTextFromDocx(data, options.Docx)
+			return Extraction{Text: text}, err
+		},
+		[]extension{DOCX},
+		[]string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	)
+	RegisterExtractor(TextExtractor(ExtractTextFromHTML), []extension{HTML, HTM, XHTML}, []string{"text/html", "application/xhtml+xml"})
+	RegisterExtractor(TextExtractor(ExtractTextFromRTF), []extension{RTF}, []string{"application/rtf", "text/rtf"})
+	RegisterExtractor(TextExtractor(ExtractTextFromODT), []extension{ODT}, []string{"application/vnd.oasis.opendocument.text"})
+	RegisterExtractor(
+		TextExtractor(ExtractTextFromPPTX),
+		[]extension{PPTX},
+		[]string{"application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+	)
+	RegisterExtractor(
+		TextExtractor(ExtractTextFromXLSX),
+		[]extension{XLSX},
+		[]string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	)
+	RegisterExtractor(TextExtractor(ExtractTextFromCSV), []extension{CSV}, []string{"text/csv"})
+	RegisterExtractor(TextExtractor(ExtractTextFromEPUB), []extension{EPUB}, []string{"application/epub+zip"})
+}
@@ -0,0 +1,156 @@
+package document
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("reading fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{
+			file: "page.html",
+			want: "## Report\n\nPlain bold text.\n\n- One\n- Two\n\n| Name | Qty |\n| --- | --- |\n| Pens | 3 |",
+		},
+		{
+			file: "letter.rtf",
+			want: "Hello world.\nCafé € costs\nLast line",
+		},
+		{
+			file: "document.odt",
+			want: "## Overview\n\nTwo   spaces\ttab\n\nWide" + strings.Repeat(" ", maxODTSpaces) + "gap\n\n" +
+				"- First\n- Second\n\n| A | B |\n| --- | --- |\n| 1 | 2 |",
+		},
+		{
+			file: "slides.pptx",
+			want: "## Slide 1\n\nWelcome\n\nPoint one\nPoint two\n\nNotes:\nSay hello\n\n## Slide 2\n\nSecond slide",
+		},
+		{
+			file: "sheet.xlsx",
+			want: "## Sheet: Prices\n\n| Item | Price |\n| --- | --- |\n| Apple | 1.5 |\n| a\\|b | TRUE |",
+		},
+		{
+			file: "list.csv",
+			want: "| name | qty |\n| --- | --- |\n| Pens | 3 |\n| Ink | 1;2 |",
+		},
+		{
+			file: "book.epub",
+			want: "# Prologue\n\nBefore it all.\n\n# Chapter One\n\nIt begins.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := ExtractText(tt.file, "", readFixture(t, tt.file), Options{})
+			if err != nil {
+				t.Fatalf("ExtractText() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractTextUnsupportedFormat(t *testing.T) {
+	if _, err := ExtractText("image.png", "image/png", nil, Options{}); err != ErrUnsupportedFormat {
+		t.Errorf("ExtractText() error = %v, want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestExtractTextFromXLSXKeepsOnlyUsedColumns(t *testing.T) {
+	got, err := ExtractTextFromXLSX(readFixture(t, "sparse.xlsx"))
+	if err != nil {
+		t.Fatalf("ExtractTextFromXLSX() error = %v", err)
+	}
+	lines := strings.Split(got, "\n")
+	// The heading, a blank line, the header row, the separator and the remaining 2999 rows.
+	if len(lines) != 3003 {
+		t.Fatalf("got %d lines, want 3003", len(lines))
+	}
+	for _, line := range lines[2:] {
+		if strings.Count(line, "|") != 2 {
+			t.Fatalf("row %q has more than one column", line)
+		}
+	}
+}
+
+func TestReadWorksheetRowsBudget(t *testing.T) {
+	sheetXML := []byte(`<worksheet xmlns="` + spreadsheetMLNamespace + `"><sheetData>` +
+		`<row r="1"><c r="A1"><v>1</v></c><c r="B1"><v>2</v></c></row>` +
+		`<row r="2"><c r="A2"><v>3</v></c><c r="B2"><v>4</v></c></row>` +
+		`<row r="3"><c r="A3"><v>5</v></c><c r="B3"><v>6</v></c></row>` +
+		`</sheetData></worksheet>`)
+
+	tests := []struct {
+		name   string
+		budget spreadsheetBudget
+		want   [][]string
+	}{
+		{
+			name:   "unlimited",
+			budget: spreadsheetBudget{read: 100, rendered: 100},
+			want:   [][]string{{"1", "2"}, {"3", "4"}, {"5", "6"}},
+		},
+		{
+			name:   "read budget",
+			budget: spreadsheetBudget{read: 3, rendered: 100},
+			want:   [][]string{{"1", "2"}, {"3", ""}},
+		},
+		{
+			name:   "rendered budget",
+			budget: spreadsheetBudget{read: 100, rendered: 5},
+			want:   [][]string{{"1", "2"}, {"3", "4"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readWorksheetRows(sheetXML, nil, &tt.budget)
+			if err != nil {
+				t.Fatalf("readWorksheetRows() error = %v", err)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal[[]string]) {
+				t.Errorf("readWorksheetRows() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		reference string
+		want      int
+		wantOK    bool
+	}{
+		{reference: "A1", want: 0, wantOK: true},
+		{reference: "AB12", want: 27, wantOK: true},
+		{reference: "XFD1", want: maxSpreadsheetColumns - 1, wantOK: true},
+		{reference: "XFE1", want: maxSpreadsheetColumns, wantOK: true},
+		{reference: strings.Repeat("Z", 40) + "1", want: maxSpreadsheetColumns, wantOK: true},
+		{reference: "12", want: 0, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reference, func(t *testing.T) {
+			got, ok := columnIndex(tt.reference)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("columnIndex() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// skippedHTMLElements never contain readable text.
+var skippedHTMLElements = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Canvas:   true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Input:    true,
+	atom.Textarea: true,
+}
+
+// boilerplateHTMLElements hold site chrome rather than page content.
+var boilerplateHTMLElements = map[atom.Atom]bool{
+	atom.Nav:    true,
+	atom.Header: true,
+	atom.Footer: true,
+	atom.Aside:  true,
+	atom.Form:   true,
+	atom.Menu:   true,
+	atom.Dialog: true,
+}
+
+// boilerplateHTMLRoles are ARIA landmark roles for site chrome.
+var boilerplateHTMLRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"search":        true,
+	"menu":          true,
+	"menubar":       true,
+	"dialog":        true,
+}
+
+// blockHTMLElements end the current paragraph.
+var blockHTMLElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Blockquote: true, atom.Dd: true,
+	atom.Details: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true, atom.Hr: true,
+	atom.Main: true, atom.Ol: true, atom.P: true,
+	atom.Pre: true, atom.Section: true, atom.Summary: true, atom.Table: true,
+	atom.Tr: true, atom.Ul: true, atom.Caption: true, atom.Body: true,
+}
+
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// ExtractTextFromHTML extracts the readable text of a web page, dropping scripts, styles,
+// navigation, headers, footers and other boilerplate. When the page marks its content
+// with <main> or <article> only that part is used.
+func ExtractTextFromHTML(data []byte) (string, error) {
+	root, err := parseHTML(data)
+	if err != nil {
+		return "", err
+	}
+
+	content := findHTMLElement(root, atom.Main)
+	if content == nil {
+		content = findHTMLElement(root, atom.Article)
+	}
+	if content == nil {
+		content = root
+	}
+
+	text := &textBuilder{}
+	writeHTMLNode(text, content, true)
+	return text.String(), nil
+}
+
+// extractTextFromHTMLContent extracts all readable text of an HTML document, keeping
+// headers and footers. It is used for e-book chapters where those hold real content.
+func extractTextFromHTMLContent(data []byte) (string, error) {
+	root, err := parseHTML(data)
+	if err != nil {
+		return "", err
+	}
+	text := &textBuilder{}
+	writeHTMLNode(text, root, false)
+	return text.String(), nil
+}
+
+func parseHTML(data []byte) (*html.Node, error) {
+	reader, err := charset.NewReader(bytes.NewReader(data), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect HTML encoding: %w", err)
+	}
+	root, err := html.Parse(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	return root, nil
+}
+
+func findHTMLElement(node *html.Node, element atom.Atom) *html.Node {
+	if node.Type == html.ElementNode && node.DataAtom == element {
+		return node
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if found := findHTMLElement(child, element); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func isHiddenHTMLElement(node *html.Node) bool {
+	for _, attr := range node.Attr {
+		switch attr.Key {
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if attr.Val == "true" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeHTMLNode(text *textBuilder, node *html.Node, stripBoilerplate bool) {
+	switch node.Type {
+	case html.TextNode:
+		text.WriteText(node.Data)
+		return
+	case html.ElementNode:
+	default:
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			writeHTMLNode(text, child, stripBoilerplate)
+		}
+		return
+	}
+
+	if skippedHTMLElements[node.DataAtom] || isHiddenHTMLElement(node) {
+		return
+	}
+	if stripBoilerplate && (boilerplateHTMLElements[node.DataAtom] || boilerplateHTMLRoles[htmlRole(node)]) {
+		return
+	}
+
+	switch {
+	case node.DataAtom == atom.Br:
+		text.LineBreak()
+		return
+	case node.DataAtom == atom.Pre:
+		text.ParagraphBreak()
+		text.WriteRaw(htmlNodeText(node))
+		text.ParagraphBreak()
+		return
+	case node.DataAtom == atom.Table:
+		text.ParagraphBreak()
+		text.WriteRaw(renderMarkdownTable(htmlTableRows(node)))
+		text.ParagraphBreak()
+		return
+	case headingLevels[node.DataAtom] > 0:
+		text.ParagraphBreak()
+		text.StartBlock(strings.Repeat("#", headingLevels[node.DataAtom]) + " ")
+	case node.DataAtom == atom.Li:
+		text.StartBlock("- ")
+	case blockHTMLElements[node.DataAtom]:
+		text.ParagraphBreak()
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeHTMLNode(text, child, stripBoilerplate)
+	}
+
+	if headingLevels[node.DataAtom] > 0 || blockHTMLElements[node.DataAtom] {
+		text.ParagraphBreak()
+	} else if node.DataAtom == atom.Li {
+		text.LineBreak()
+	}
+}
+
+func htmlRole(node *html.Node) string {
+	for _, attr := range node.Attr {
+		if attr.Key == "role" {
+			return strings.ToLower(attr.Val)
+		}
+	}
+	return ""
+}
+
+// htmlNodeText returns the raw text below a node, preserving whitespace.
+func htmlNodeText(node *html.Node) string {
+	var builder strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			builder.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Br {
+			builder.WriteString("\n")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return strings.Trim(builder.String(), "\n")
+}
+
+// htmlTableRows collects the cell texts of a table, ignoring nested tables.
+func htmlTableRows(table *html.Node) [][]string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Table:
+				continue
+			case atom.Tr:
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						cellText := &textBuilder{}
+						writeHTMLNode(cellText, cell, false)
+						row = append(row, cellText.String())
+					}
+				}
+				rows = append(rows, row)
+			default:
+				walk(child)
+			}
+		}
+	}
+	walk(table)
+	return rows
+}
@@ -0,0 +1,146 @@
+package document
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	odfTextNamespace   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	odfTableNamespace  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odfOfficeNamespace = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+)
+
+// maxODTSpaces caps the spaces written for a single text:s element.
+const maxODTSpaces = 1024
+
+// ExtractTextFromODT extracts the text of an OpenDocument text file. Headings are
+// emitted as Markdown headings, list items as bullets and tables as Markdown tables.
+func ExtractTextFromODT(data []byte) (string, error) {
+	archive, err := openArchive(data)
+	if err != nil {
+		return "", err
+	}
+	content, err := readArchiveFile(archive, "content.xml")
+	if err != nil {
+		return "", err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	text := &textBuilder{}
+
+	// Table cells are written to their own builder and collected into rows.
+	var tables []*odtTable
+	current := func() *textBuilder {
+		if len(tables) > 0 && tables[len(tables)-1].cell != nil {
+			return tables[len(tables)-1].cell
+		}
+		return text
+	}
+	skipDepth := 0
+	listDepth := 0
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to decode ODT content: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+			switch {
+			case t.Name.Space == odfOfficeNamespace && t.Name.Local == "annotation",
+				t.Name.Space == odfTextNamespace && (t.Name.Local == "note" || t.Name.Local == "tracked-changes"):
+				skipDepth = 1
+			case t.Name.Space == odfTextNamespace && t.Name.Local == "h":
+				level, err := strconv.Atoi(xmlAttr(t.Attr, "outline-level"))
+				if err != nil || level < 1 {
+					level = 1
+				}
+				current().ParagraphBreak()
+				current().StartBlock(strings.Repeat("#", min(level, 6)) + " ")
+			case t.Name.Space == odfTextNamespace && t.Name.Local == "p":
+				current().LineBreak()
+			case t.Name.Space == odfTextNamespace && t.Name.Local == "list-item":
+				listDepth++
+				current().StartBlock(strings.Repeat("  ", listDepth-1) + "- ")
+			case t.Name.Space == odfTextNamespace && t.Name.Local == "tab":
+				current().WriteRaw("\t")
+			case t.Name.Space == odfTextNamespace && t.Name.Local == "s":
+				count, err := strconv.Atoi(xmlAttr(t.Attr, "c"))
+				if err != nil || count < 1 {
+					count = 1
+				}
+				current().WriteRaw(strings.Repeat(" ", min(count, maxODTSpaces)))
+			case t.Name.Space == odfTextNamespace && t.Name.Local == "line-break":
+				current().LineBreak()
+			case t.Name.Space == odfTableNamespace && t.Name.Local == "table":
+				tables = append(tables, &odtTable{})
+			case t.Name.Space == odfTableNamespace && t.Name.Local == "table-row" && len(tables) > 0:
+				tables[len(tables)-1].rows = append(tables[len(tables)-1].rows, []string{})
+			case t.Name.Space == odfTableNamespace && t.Name.Local == "table-cell" && len(tables) > 0:
+				tables[len(tables)-1].cell = &textBuilder{}
+			}
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			switch {
+			case t.Name.Space == odfTextNamespace && (t.Name.Local == "h" || t.Name.Local == "p"):
+				if listDepth > 0 {
+					current().LineBreak()
+				} else {
+					current().ParagraphBreak()
+				}
+			case t.Name.Space == odfTextNamespace && t.Name.Local == "list-item":
+				listDepth--
+			case t.Name.Space == odfTextNamespace && t.Name.Local == "list" && listDepth == 0:
+				current().ParagraphBreak()
+			case t.Name.Space == odfTableNamespace && t.Name.Local == "table-cell" && len(tables) > 0:
+				table := tables[len(tables)-1]
+				if table.cell != nil && len(table.rows) > 0 {
+					table.rows[len(table.rows)-1] = append(table.rows[len(table.rows)-1], table.cell.String())
+				}
+				table.cell = nil
+			case t.Name.Space == odfTableNamespace && t.Name.Local == "table" && len(tables) > 0:
+				table := tables[len(tables)-1]
+				tables = tables[:len(tables)-1]
+				target := current()
+				target.ParagraphBreak()
+				if len(tables) > 0 {
+					// Nested tables cannot be expressed in Markdown; flatten them into the outer cell.
+					for _, row := range table.rows {
+						target.WriteText(strings.Join(row, " "))
+						target.LineBreak()
+					}
+				} else {
+					target.WriteRaw(renderMarkdownTable(table.rows))
+				}
+				target.ParagraphBreak()
+			}
+		case xml.CharData:
+			if skipDepth == 0 {
+				current().WriteText(string(t))
+			}
+		}
+	}
+
+	return text.String(), nil
+}
+
+type odtTable struct {
+	rows [][]string
+	cell *textBuilder
+}
@@ -0,0 +1,141 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/labstack/gommon/log"
+)
+
+// OutlineEntry is an entry of a PDF's table of contents.
+type OutlineEntry struct {
+	Title string `json:"title"`
+	Level int    `json:"level"`
+	// Page is the 1-based page the entry points to, or 0 when it links outside the document.
+	Page int `json:"page,omitempty"`
+}
+
+// SourceMetadata is the metadata a document file carries about itself.
+type SourceMetadata struct {
+	Title            string         `json:"title,omitempty"`
+	Author           string         `json:"author,omitempty"`
+	Subject          string         `json:"subject,omitempty"`
+	Keywords         string         `json:"keywords,omitempty"`
+	Creator          string         `json:"creator,omitempty"`
+	Producer         string         `json:"producer,omitempty"`
+	CreationDate     *time.Time     `json:"creationDate,omitempty"`
+	ModificationDate *time.Time     `json:"modificationDate,omitempty"`
+	Outline          []OutlineEntry `json:"outline,omitempty"`
+}
+
+// ExtractPDF extracts the text of every page of a PDF file together with its metadata and outline.
+func ExtractPDF(data []byte) (Extraction, error) {
+	doc, err := fitz.NewFromReader(bytes.NewReader(data))
+	if err != nil {
+		return Extraction{}, fmt.Errorf("failed to open PDF: %v", err)
+	}
+	defer func(doc *fitz.Document) {
+		if err := doc.Close(); err != nil {
+			log.Error(err)
+		}
+	}(doc)
+
+	pages := make([]string, 0, doc.NumPage())
+	for i := 0; i < doc.NumPage(); i++ {
+		text, err := doc.Text(i)
+		if err != nil {
+			return Extraction{}, fmt.Errorf("failed to extract text from page %d: %v", i+1, err)
+		}
+		pages = append(pages, text)
+	}
+
+	info := doc.Metadata()
+	field := func(key string) string {
+		return strings.TrimSpace(strings.TrimRight(info[key], "\x00"))
+	}
+	metadata := &SourceMetadata{
+		Title:            field("title"),
+		Author:           field("author"),
+		Subject:          field("subject"),
+		Keywords:         field("keywords"),
+		Creator:          field("creator"),
+		Producer:         field("producer"),
+		CreationDate:     parsePDFDate(field("creationDate")),
+		ModificationDate: parsePDFDate(field("modDate")),
+	}
+
+	// Documents without an outline report an error, which just means there is no table of contents.
+	if toc, err := doc.ToC(); err == nil {
+		for _, entry := range toc {
+			metadata.Outline = append(metadata.Outline, OutlineEntry{
+				Title: strings.TrimSpace(entry.Title),
+				Level: entry.Level,
+				Page:  max(entry.Page+1, 0),
+			})
+		}
+	}
+
+	return Extraction{
+		Text:     joinPages(pages),
+		Pages:    pages,
+		Metadata: metadata,
+	}, nil
+}
+
+// ExtractTextFromPDF extracts text from a PDF file and returns it as a string.
+func ExtractTextFromPDF(data []byte) (string, error) {
+	extraction, err := ExtractPDF(data)
+	if err != nil {
+		return "", err
+	}
+	return extraction.Text, nil
+}
+
+func joinPages(pages []string) string {
+	size := 0
+	for _, page := range pages {
+		size += len(page) + 1
+	}
+	var builder strings.Builder
+	builder.Grow(size)
+	for _, page := range pages {
+		builder.WriteString(page)
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// pdfDateLayouts are the forms of the PDF date format D:YYYYMMDDHHmmSSOHH'mm' seen in practice,
+// after the prefix and apostrophes have been removed.
+var pdfDateLayouts = []string{
+	"20060102150405Z0700",
+	"20060102150405Z",
+	"20060102150405",
+	"200601021504",
+	"2006010215",
+	"20060102",
+	"200601",
+	"2006",
+}
+
+// parsePDFDate parses a PDF date string, returning nil when it is empty or malformed.
+func parsePDFDate(value string) *time.Time {
+	value = strings.TrimPrefix(value, "D:")
+	value = strings.ReplaceAll(value, "'", "")
+	// Some producers write a UTC offset after Z, which is redundant.
+	if i := strings.IndexByte(value, 'Z'); i >= 0 {
+		value = value[:i+1]
+	}
+	if value == "" {
+		return nil
+	}
+	for _, layout := range pdfDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return &parsed
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	drawingMLNamespace        = "http://schemas.openxmlformats.org/drawingml/2006/main"
+	presentationMLNamespace   = "http://schemas.openxmlformats.org/presentationml/2006/main"
+	notesSlideRelationshipEnd = "/notesSlide"
+)
+
+// ExtractTextFromPPTX extracts the text of a PowerPoint presentation in slide order.
+// Each slide starts with a "## Slide N" heading and is followed by its speaker notes.
+func ExtractTextFromPPTX(data []byte) (string, error) {
+	archive, err := openArchive(data)
+	if err != nil {
+		return "", err
+	}
+
+	slidePaths, err := presentationSlidePaths(archive)
+	if err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	for i, slidePath := range slidePaths {
+		slideXML, err := readArchiveFile(archive, slidePath)
+		if err != nil {
+			return "", err
+		}
+		slideText, err := extractTextFromPresentationPart(slideXML, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to extract slide %d: %w", i+1, err)
+		}
+
+		notesText := ""
+		relationships, err := readRelationships(archive, slidePath)
+		if err != nil {
+			return "", err
+		}
+		for _, rel := range relationships {
+			if !strings.HasSuffix(rel.Type, notesSlideRelationshipEnd) {
+				continue
+			}
+			notesXML, err := readArchiveFile(archive, resolvePartTarget(slidePath, rel.Target))
+			if err != nil {
+				return "", err
+			}
+			notesText, err = extractTextFromPresentationPart(notesXML, true)
+			if err != nil {
+				return "", fmt.Errorf("failed to extract notes of slide %d: %w", i+1, err)
+			}
+			break
+		}
+
+		if result.Len() > 0 {
+			result.WriteString("\n\n")
+		}
+		result.WriteString(fmt.Sprintf("## Slide %d", i+1))
+		if slideText != "" {
+			result.WriteString("\n\n")
+			result.WriteString(slideText)
+		}
+		if notesText != "" {
+			result.WriteString("\n\nNotes:\n")
+			result.WriteString(notesText)
+		}
+	}
+
+	return result.String(), nil
+}
+
+// presentationSlidePaths returns the archive paths of the slides in presentation order.
+func presentationSlidePaths(archive *zip.Reader) ([]string, error) {
+	const presentationPath = "ppt/presentation.xml"
+	presentationXML, err := readArchiveFile(archive, presentationPath)
+	if err != nil {
+		return nil, err
+	}
+	relationships, err := readRelationships(archive, presentationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var slidePaths []string
+	decoder := xml.NewDecoder(bytes.NewReader(presentationXML))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode presentation: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Space != presentationMLNamespace || start.Name.Local != "sldId" {
+			continue
+		}
+		rel, ok := relationships[relationshipAttr(start.Attr, "id")]
+		if !ok {
+			continue
+		}
+		slidePaths = append(slidePaths, resolvePartTarget(presentationPath, rel.Target))
+	}
+	return slidePaths, nil
+}
+
+// extractTextFromPresentationPart extracts the paragraphs of a slide or notes slide.
+// For notes only the body placeholder is used, skipping the slide image and number.
+func extractTextFromPresentationPart(partXML []byte, onlyBody bool) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(partXML))
+	text := &textBuilder{}
+	shape := &textBuilder{}
+	inShape := false
+	shapeIsBody := false
+	inText := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to decode slide: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == presentationMLNamespace && t.Name.Local == "sp":
+				inShape = true
+				shapeIsBody = false
+				shape = &textBuilder{}
+			case t.Name.Space == presentationMLNamespace && t.Name.Local == "graphicFrame" && !inShape:
+				shape = &textBuilder{}
+			case t.Name.Space == presentationMLNamespace && t.Name.Local == "ph":
+				placeholderType := xmlAttr(t.Attr, "type")
+				shapeIsBody = placeholderType == "body" || placeholderType == ""
+			case t.Name.Space == drawingMLNamespace && t.Name.Local == "t":
+				inText = true
+			case t.Name.Space == drawingMLNamespace && t.Name.Local == "br":
+				shape.LineBreak()
+			case t.Name.Space == drawingMLNamespace && t.Name.Local == "tab":
+				shape.WriteRaw("\t")
+			}
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == drawingMLNamespace && t.Name.Local == "t":
+				inText = false
+			case t.Name.Space == drawingMLNamespace && t.Name.Local == "p":
+				shape.LineBreak()
+			case t.Name.Space == presentationMLNamespace && t.Name.Local == "sp":
+				if (!onlyBody || shapeIsBody) && shape.Len() > 0 {
+					text.WriteRaw(shape.String())
+					text.ParagraphBreak()
+				}
+				shape = &textBuilder{}
+				inShape = false
+			case t.Name.Space == presentationMLNamespace && t.Name.Local == "graphicFrame" && !inShape && !onlyBody:
+				// Tables and other graphic frames sit outside of shapes.
+				if shape.Len() > 0 {
+					text.WriteRaw(shape.String())
+					text.ParagraphBreak()
+				}
+				shape = &textBuilder{}
+			}
+		case xml.CharData:
+			if inText {
+				shape.WriteRaw(string(t))
+			}
+		}
+	}
+
+	return text.String(), nil
+}
@@ -0,0 +1,209 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// ThumbnailWidth is the width in pixels of the thumbnails rendered at upload time.
+	ThumbnailWidth = 256
+	// DefaultPreviewWidth is the width of page previews when none is requested.
+	DefaultPreviewWidth = 800
+	// maxPreviewPixels bounds the area of rendered pages, so that very tall pages do not render
+	// into huge images.
+	maxPreviewPixels = 2000 * 2000 * 4
+)
+
+// previewWidths are the widths page previews are rendered at, smallest first. Requested widths are
+// snapped to one of them so that few previews are cached per page.
+var previewWidths = []int{200, 400, 800, 1200, 1600, 2000}
+
+// ErrPageOutOfRange is returned when a preview is requested for a page the document does not have.
+var ErrPageOutOfRange = errors.New("page out of range")
+
+// ErrPageTooLarge is returned when a page would render into an image larger than maxPreviewPixels.
+var ErrPageTooLarge = errors.New("page too large to render")
+
+// placeholderAccents color the header band of placeholders by file type.
+var placeholderAccents = map[extension]color.RGBA{
+	PDF:   {R: 0xd9, G: 0x3f, B: 0x3f, A: 0xff},
+	DOCX:  {R: 0x2b, G: 0x57, B: 0x9a, A: 0xff},
+	ODT:   {R: 0x2b, G: 0x57, B: 0x9a, A: 0xff},
+	RTF:   {R: 0x2b, G: 0x57, B: 0x9a, A: 0xff},
+	TXT:   {R: 0x6b, G: 0x72, B: 0x80, A: 0xff},
+	MD:    {R: 0x37, G: 0x41, B: 0x51, A: 0xff},
+	HTML:  {R: 0xe4, G: 0x6f, B: 0x2c, A: 0xff},
+	HTM:   {R: 0xe4, G: 0x6f, B: 0x2c, A: 0xff},
+	XHTML: {R: 0xe4, G: 0x6f, B: 0x2c, A: 0xff},
+	PPTX:  {R: 0xc4, G: 0x3e, B: 0x1c, A: 0xff},
+	XLSX:  {R: 0x21, G: 0x73, B: 0x46, A: 0xff},
+	CSV:   {R: 0x21, G: 0x73, B: 0x46, A: 0xff},
+	EPUB:  {R: 0x7c, G: 0x3a, B: 0xed, A: 0xff},
+}
+
+var defaultPlaceholderAccent = color.RGBA{R: 0x47, G: 0x55, B: 0x69, A: 0xff}
+
+// SnapPreviewWidth rounds a requested preview width up to the nearest supported width, using
+// DefaultPreviewWidth when none was requested and the largest width for wider requests.
+func SnapPreviewWidth(width int) int {
+	if width <= 0 {
+		return DefaultPreviewWidth
+	}
+	for _, previewWidth := range previewWidths {
+		if width <= previewWidth {
+			return previewWidth
+		}
+	}
+	return previewWidths[len(previewWidths)-1]
+}
+
+// IsPDF reports whether a file name refers to a PDF document.
+func IsPDF(filename string) bool {
+	return extension(strings.ToLower(filepath.Ext(filename))) == PDF
+}
+
+// RenderPDFPage renders a 1-based page of a PDF file as a PNG image of the given width.
+func RenderPDFPage(data []byte, pageNumber int, width int) ([]byte, error) {
+	doc, err := fitz.NewFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF: %v", err)
+	}
+	defer func(doc *fitz.Document) {
+		if err := doc.Close(); err != nil {
+			log.Error(err)
+		}
+	}(doc)
+
+	if pageNumber < 1 || pageNumber > doc.NumPage() {
+		return nil, ErrPageOutOfRange
+	}
+
+	// Page bounds are expressed in points, i.e. at 72 DPI.
+	bounds, err := doc.Bound(pageNumber - 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bounds of page %d: %v", pageNumber, err)
+	}
+	if bounds.Dx() <= 0 || bounds.Dy() <= 0 {
+		return nil, fmt.Errorf("page %d has no area", pageNumber)
+	}
+	dpi := float64(width) * 72 / float64(bounds.Dx())
+	if float64(width)*float64(bounds.Dy())*dpi/72 > maxPreviewPixels {
+		return nil, ErrPageTooLarge
+	}
+
+	rendered, err := doc.ImagePNG(pageNumber-1, dpi)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render page %d: %v", pageNumber, err)
+	}
+	return rendered, nil
+}
+
+// RenderThumbnail renders the first page of a PDF, or a placeholder built from the extracted
+// text for any other format or a first page too large to render, as a PNG image of
+// ThumbnailWidth pixels.
+func RenderThumbnail(filename string, data []byte, text string) ([]byte, error) {
+	if IsPDF(filename) {
+		thumbnail, err := RenderPDFPage(data, 1, ThumbnailWidth)
+		if !errors.Is(err, ErrPageTooLarge) {
+			return thumbnail, err
+		}
+	}
+	return RenderPlaceholder(filename, text, ThumbnailWidth)
+}
+
+// RenderPlaceholder draws a page-shaped PNG image for documents that cannot be rendered,
+// with a header band colored by file type and a bar for every line of text, so documents
+// remain distinguishable by their shape.
+func RenderPlaceholder(filename string, text string, width int) ([]byte, error) {
+	height := width * 1414 / 1000
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	fill := func(rect image.Rectangle, c color.Color) {
+		draw.Draw(canvas, rect.Intersect(canvas.Bounds()), &image.Uniform{C: c}, image.Point{}, draw.Src)
+	}
+
+	fill(canvas.Bounds(), color.RGBA{R: 0xd1, G: 0xd5, B: 0xdb, A: 0xff})
+	fill(image.Rect(1, 1, width-1, height-1), color.White)
+
+	accent, ok := placeholderAccents[extension(strings.ToLower(filepath.Ext(filename)))]
+	if !ok {
+		accent = defaultPlaceholderAccent
+	}
+	band := max(height/14, 4)
+	fill(image.Rect(1, 1, width-1, band), accent)
+
+	margin := max(width/10, 2)
+	lineHeight := max(height/48, 3)
+	barHeight := max(lineHeight*55/100, 1)
+	usableWidth := width - 2*margin
+	const charactersPerLine = 80
+
+	y := band + margin
+	for _, line := range strings.Split(text, "\n") {
+		if y+lineHeight > height-margin {
+			break
+		}
+		line = strings.TrimSpace(line)
+		length := utf8.RuneCountInString(line)
+		if length == 0 {
+			y += lineHeight / 2
+			continue
+		}
+
+		barColor := color.RGBA{R: 0xc7, G: 0xcc, B: 0xd4, A: 0xff}
+		thickness := barHeight
+		if strings.HasPrefix(line, "#") {
+			barColor = color.RGBA{R: 0x6b, G: 0x72, B: 0x80, A: 0xff}
+			thickness = barHeight * 3 / 2
+		}
+
+		for length > 0 && y+lineHeight <= height-margin {
+			segment := min(length, charactersPerLine)
+			barWidth := max(segment*usableWidth/charactersPerLine, 1)
+			fill(image.Rect(margin, y, margin+barWidth, y+thickness), barColor)
+			length -= segment
+			y += lineHeight
+		}
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode placeholder: %w", err)
+	}
+	return encoded.Bytes(), nil
+}
+
+// ThumbnailObjectName is the bucket object holding the thumbnail of a document.
+func ThumbnailObjectName(documentID int32) string {
+	return fmt.Sprintf("thumbnails/%d.png", documentID)
+}
+
+// PreviewObjectPrefix is the prefix of every cached page preview of a document.
+func PreviewObjectPrefix(documentID int32) string {
+	return fmt.Sprintf("previews/%d/", documentID)
+}
+
+// VersionPreviewObjectPrefix is the prefix of the cached page previews of a version of a document.
+// Keying previews by version keeps the previews of a replaced file from being served for the new
+// one, even when deleting them fails.
+func VersionPreviewObjectPrefix(documentID int32, versionNumber int32) string {
+	return fmt.Sprintf("%sv%d/", PreviewObjectPrefix(documentID), versionNumber)
+}
+
+// PreviewObjectName is the bucket object caching a page preview of a version of a document at a
+// given width.
+func PreviewObjectName(documentID int32, versionNumber int32, pageNumber int, width int) string {
+	return fmt.Sprintf("%s%d-%d.png", VersionPreviewObjectPrefix(documentID, versionNumber), pageNumber, width)
+}
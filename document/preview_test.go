@@ -0,0 +1,97 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestSnapPreviewWidth(t *testing.T) {
+	tests := []struct {
+		width int
+		want  int
+	}{
+		{0, DefaultPreviewWidth},
+		{-5, DefaultPreviewWidth},
+		{1, 200},
+		{200, 200},
+		{201, 400},
+		{1999, 2000},
+		{100000, 2000},
+	}
+	for _, tt := range tests {
+		if got := SnapPreviewWidth(tt.width); got != tt.want {
+			t.Errorf("SnapPreviewWidth(%d) = %d, want %d", tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestRenderPDFPage(t *testing.T) {
+	// pages.pdf has a letter page and a 100 x 14000 points strip.
+	tests := []struct {
+		name       string
+		pageNumber int
+		width      int
+		wantWidth  int
+		wantHeight int
+		wantErr    error
+	}{
+		{name: "letter page", pageNumber: 1, width: 2000, wantWidth: 2000, wantHeight: 2589},
+		{name: "thumbnail of tall page", pageNumber: 2, width: ThumbnailWidth, wantWidth: 256, wantHeight: 35840},
+		{name: "tall page beyond pixel cap", pageNumber: 2, width: 2000, wantErr: ErrPageTooLarge},
+		{name: "page zero", pageNumber: 0, width: 200, wantErr: ErrPageOutOfRange},
+		{name: "missing page", pageNumber: 3, width: 200, wantErr: ErrPageOutOfRange},
+	}
+	data := readFixture(t, "pages.pdf")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := RenderPDFPage(data, tt.pageNumber, tt.width)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("RenderPDFPage() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderPDFPage() error = %v", err)
+			}
+			config, err := png.DecodeConfig(bytes.NewReader(rendered))
+			if err != nil {
+				t.Fatalf("decoding rendered page: %v", err)
+			}
+			if config.Width != tt.wantWidth || config.Height != tt.wantHeight {
+				t.Errorf("RenderPDFPage() = %dx%d, want %dx%d", config.Width, config.Height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestRenderPlaceholder(t *testing.T) {
+	rendered, err := RenderPlaceholder("notes.md", "# Title\n\nSome text", ThumbnailWidth)
+	if err != nil {
+		t.Fatalf("RenderPlaceholder() error = %v", err)
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(rendered))
+	if err != nil {
+		t.Fatalf("decoding placeholder: %v", err)
+	}
+	if config.Width != ThumbnailWidth || config.Height != ThumbnailWidth*1414/1000 {
+		t.Errorf("RenderPlaceholder() = %dx%d", config.Width, config.Height)
+	}
+}
+
+func TestPreviewObjectNames(t *testing.T) {
+	if got := PreviewObjectName(12, 3, 2, 800); got != "previews/12/v3/2-800.png" {
+		t.Errorf("PreviewObjectName() = %q", got)
+	}
+	versionPrefix := VersionPreviewObjectPrefix(12, 3)
+	if !strings.HasPrefix(versionPrefix, PreviewObjectPrefix(12)) {
+		t.Errorf("VersionPreviewObjectPrefix() = %q is not under %q", versionPrefix, PreviewObjectPrefix(12))
+	}
+	// Deleting the previews of version 1 must not delete those of version 10.
+	if strings.HasPrefix(PreviewObjectName(12, 10, 1, 800), VersionPreviewObjectPrefix(12, 1)) {
+		t.Error("previews of version 10 are under the prefix of version 1")
+	}
+}
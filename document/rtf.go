@@ -0,0 +1,205 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// rtfSkippedDestinations are RTF groups that hold formatting tables or embedded
+// objects rather than document text.
+var rtfSkippedDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true,
+	"pict": true, "object": true, "objdata": true, "themedata": true,
+	"colorschememapping": true, "datastore": true, "latentstyles": true,
+	"listtable": true, "listoverridetable": true, "rsidtbl": true,
+	"generator": true, "xmlnstbl": true, "mmathPr": true, "filetbl": true,
+	"revtbl": true, "pgdsctbl": true, "fldinst": true, "header": true,
+	"headerl": true, "headerr": true, "headerf": true, "footer": true,
+	"footerl": true, "footerr": true, "footerf": true, "bkmkstart": true,
+	"bkmkend": true, "private": true,
+}
+
+// cp1252 maps the Windows-1252 bytes that differ from Latin-1.
+var cp1252 = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž', 0x91: '‘',
+	0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—', 0x98: '˜',
+	0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+type rtfGroupState struct {
+	skip           bool
+	unicodeSkip    int
+	ignorableGroup bool
+}
+
+// ExtractTextFromRTF extracts the text of an RTF document, dropping control words,
+// font and color tables, embedded pictures and other non-text destinations.
+func ExtractTextFromRTF(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \r\n\t"), []byte(`{\rtf`)) {
+		return "", errors.New("not an RTF document")
+	}
+
+	var output strings.Builder
+	stack := []rtfGroupState{{unicodeSkip: 1}}
+	state := &stack[0]
+	pendingSkip := 0
+
+	emit := func(r rune) {
+		if pendingSkip > 0 {
+			pendingSkip--
+			return
+		}
+		if !state.skip {
+			output.WriteRune(r)
+		}
+	}
+
+	for i := 0; i < len(data); i++ {
+		char := data[i]
+		switch char {
+		case '{':
+			stack = append(stack, *state)
+			state = &stack[len(stack)-1]
+			state.ignorableGroup = false
+		case '}':
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+				state = &stack[len(stack)-1]
+			}
+			pendingSkip = 0
+		case '\r', '\n':
+		case '\\':
+			if i+1 >= len(data) {
+				break
+			}
+			next := data[i+1]
+			if !isASCIILetter(next) {
+				i++
+				switch next {
+				case '\'':
+					if i+2 < len(data) {
+						value, err := strconv.ParseUint(string(data[i+1:i+3]), 16, 8)
+						i += 2
+						if err == nil {
+							emit(decodeCP1252(byte(value)))
+						}
+					}
+				case '\\', '{', '}':
+					emit(rune(next))
+				case '~':
+					emit(' ')
+				case '_':
+					emit('-')
+				case '*':
+					state.ignorableGroup = true
+				case '\r', '\n':
+					emit('\n')
+				}
+				break
+			}
+
+			start := i + 1
+			end := start
+			for end < len(data) && isASCIILetter(data[end]) {
+				end++
+			}
+			word := string(data[start:end])
+			paramStart := end
+			if end < len(data) && data[end] == '-' {
+				end++
+			}
+			for end < len(data) && data[end] >= '0' && data[end] <= '9' {
+				end++
+			}
+			param, hasParam := 0, false
+			if end > paramStart {
+				if value, err := strconv.Atoi(string(data[paramStart:end])); err == nil {
+					param, hasParam = value, true
+				}
+			}
+			if end < len(data) && data[end] == ' ' {
+				end++
+			}
+			i = end - 1
+
+			if state.ignorableGroup || rtfSkippedDestinations[word] {
+				state.skip = true
+				continue
+			}
+			switch word {
+			case "par", "line", "row", "sect", "page":
+				emit('\n')
+			case "cell":
+				emit('\t')
+			case "tab":
+				emit('\t')
+			case "emdash":
+				emit('—')
+			case "endash":
+				emit('–')
+			case "bullet":
+				emit('•')
+			case "lquote":
+				emit('‘')
+			case "rquote":
+				emit('’')
+			case "ldblquote":
+				emit('“')
+			case "rdblquote":
+				emit('”')
+			case "uc":
+				if hasParam {
+					state.unicodeSkip = param
+				}
+			case "u":
+				if hasParam {
+					if param < 0 {
+						param += 65536
+					}
+					emit(rune(param))
+					pendingSkip = state.unicodeSkip
+				}
+			}
+		default:
+			emit(rune(char))
+		}
+	}
+
+	return normalizeExtractedLines(output.String()), nil
+}
+
+func isASCIILetter(char byte) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+func decodeCP1252(value byte) rune {
+	if r, ok := cp1252[value]; ok {
+		return r
+	}
+	return rune(value)
+}
+
+// normalizeExtractedLines trims every line and keeps at most one blank line in a row.
+func normalizeExtractedLines(text string) string {
+	var result strings.Builder
+	blank := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			blank++
+			continue
+		}
+		if result.Len() > 0 {
+			result.WriteString("\n")
+			if blank > 0 {
+				result.WriteString("\n")
+			}
+		}
+		result.WriteString(line)
+		blank = 0
+	}
+	return result.String()
+}
@@ -0,0 +1,350 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const spreadsheetMLNamespace = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+
+const (
+	// maxSpreadsheetColumns is the number of columns of a worksheet, up to column XFD. Cells beyond
+	// it are skipped.
+	maxSpreadsheetColumns = 16384
+	// maxSpreadsheetCells is the most non-empty cells read from a workbook, and the most cells of
+	// the tables rendered from it.
+	maxSpreadsheetCells = 200000
+)
+
+// ExtractTextFromXLSX extracts every worksheet of an Excel workbook as a Markdown table
+// preceded by a "## Sheet name" heading. Empty sheets and columns are skipped, and workbooks
+// with more than maxSpreadsheetCells cells are cut short.
+func ExtractTextFromXLSX(data []byte) (string, error) {
+	archive, err := openArchive(data)
+	if err != nil {
+		return "", err
+	}
+
+	const workbookPath = "xl/workbook.xml"
+	workbookXML, err := readArchiveFile(archive, workbookPath)
+	if err != nil {
+		return "", err
+	}
+	var workbook struct {
+		Sheets []struct {
+			Name  string     `xml:"name,attr"`
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(workbookXML, &workbook); err != nil {
+		return "", fmt.Errorf("failed to parse workbook: %w", err)
+	}
+	relationships, err := readRelationships(archive, workbookPath)
+	if err != nil {
+		return "", err
+	}
+	sharedStrings, err := readSharedStrings(archive)
+	if err != nil {
+		return "", err
+	}
+
+	budget := spreadsheetBudget{read: maxSpreadsheetCells, rendered: maxSpreadsheetCells}
+	var result strings.Builder
+	for _, sheet := range workbook.Sheets {
+		rel, ok := relationships[relationshipAttr(sheet.Attrs, "id")]
+		if !ok {
+			continue
+		}
+		sheetXML, err := readArchiveFile(archive, resolvePartTarget(workbookPath, rel.Target))
+		if err != nil {
+			return "", err
+		}
+		rows, err := readWorksheetRows(sheetXML, sharedStrings, &budget)
+		if err != nil {
+			return "", fmt.Errorf("failed to read sheet %s: %w", sheet.Name, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		if result.Len() > 0 {
+			result.WriteString("\n\n")
+		}
+		result.WriteString("## Sheet: ")
+		result.WriteString(sheet.Name)
+		result.WriteString("\n\n")
+		result.WriteString(renderMarkdownTable(rows))
+	}
+
+	return result.String(), nil
+}
+
+// readSharedStrings returns the workbook's shared string table, which cells of type "s" index into.
+func readSharedStrings(archive *zip.Reader) ([]string, error) {
+	const sharedStringsPath = "xl/sharedStrings.xml"
+	if findArchiveFile(archive, sharedStringsPath) == nil {
+		return nil, nil
+	}
+	sharedStringsXML, err := readArchiveFile(archive, sharedStringsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var sharedStrings []string
+	var current strings.Builder
+	inText := false
+	phoneticDepth := 0
+	decoder := xml.NewDecoder(bytes.NewReader(sharedStringsXML))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode shared strings: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "rPh":
+				phoneticDepth++
+			case "t":
+				inText = phoneticDepth == 0
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				sharedStrings = append(sharedStrings, current.String())
+			case "rPh":
+				phoneticDepth--
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		}
+	}
+	return sharedStrings, nil
+}
+
+type worksheetCell struct {
+	Reference  string `xml:"r,attr"`
+	Type       string `xml:"t,attr"`
+	Value      string `xml:"v"`
+	InlineText []struct {
+		Text string `xml:",chardata"`
+	} `xml:"is>t"`
+	InlineRuns []struct {
+		Text string `xml:",chardata"`
+	} `xml:"is>r>t"`
+}
+
+// spreadsheetBudget bounds the cells read from a workbook and the cells of the tables rendered from
+// it, so that a small file cannot expand into a huge extraction.
+type spreadsheetBudget struct {
+	read     int
+	rendered int
+}
+
+// worksheetCellValue is a non-empty cell of a worksheet row.
+type worksheetCellValue struct {
+	column int
+	value  string
+}
+
+// readWorksheetRows reads the cells of a worksheet into rows. Cells are kept sparsely while reading
+// and the rows only get the columns that have content anywhere in the sheet, in order. Reading
+// stops once the budget is spent, keeping the rows read so far.
+func readWorksheetRows(sheetXML []byte, sharedStrings []string, budget *spreadsheetBudget) ([][]string, error) {
+	var sparseRows [][]worksheetCellValue
+	decoder := xml.NewDecoder(bytes.NewReader(sheetXML))
+	var row []worksheetCellValue
+	nextColumn := 0
+	inRow := false
+	for budget.read > 0 {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode worksheet: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != spreadsheetMLNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "row":
+				inRow = true
+				row = nil
+				nextColumn = 0
+			case "c":
+				if !inRow {
+					continue
+				}
+				var cell worksheetCell
+				if err := decoder.DecodeElement(&cell, &t); err != nil {
+					return nil, fmt.Errorf("failed to decode cell: %w", err)
+				}
+				column := nextColumn
+				if index, ok := columnIndex(cell.Reference); ok {
+					column = index
+				}
+				if column >= maxSpreadsheetColumns {
+					continue
+				}
+				nextColumn = column + 1
+				value := cellValue(cell, sharedStrings)
+				if strings.TrimSpace(value) == "" {
+					continue
+				}
+				row = append(row, worksheetCellValue{column: column, value: value})
+				budget.read--
+			}
+		case xml.EndElement:
+			if t.Name.Space == spreadsheetMLNamespace && t.Name.Local == "row" {
+				inRow = false
+				if len(row) > 0 {
+					sparseRows = append(sparseRows, row)
+				}
+			}
+		}
+	}
+	if inRow && len(row) > 0 {
+		sparseRows = append(sparseRows, row)
+	}
+
+	usedColumns := map[int]bool{}
+	for _, row := range sparseRows {
+		for _, cell := range row {
+			usedColumns[cell.column] = true
+		}
+	}
+	columns := slices.Sorted(maps.Keys(usedColumns))
+	positions := make(map[int]int, len(columns))
+	for i, column := range columns {
+		positions[column] = i
+	}
+
+	var rows [][]string
+	for _, sparseRow := range sparseRows {
+		if budget.rendered < len(columns) {
+			break
+		}
+		budget.rendered -= len(columns)
+		row := make([]string, len(columns))
+		for _, cell := range sparseRow {
+			row[positions[cell.column]] = cell.value
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func cellValue(cell worksheetCell, sharedStrings []string) string {
+	switch cell.Type {
+	case "s":
+		index, err := strconv.Atoi(strings.TrimSpace(cell.Value))
+		if err != nil || index < 0 || index >= len(sharedStrings) {
+			return ""
+		}
+		return sharedStrings[index]
+	case "inlineStr":
+		var text strings.Builder
+		for _, part := range cell.InlineText {
+			text.WriteString(part.Text)
+		}
+		for _, part := range cell.InlineRuns {
+			text.WriteString(part.Text)
+		}
+		return text.String()
+	case "b":
+		if strings.TrimSpace(cell.Value) == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+		return cell.Value
+	}
+}
+
+// columnIndex converts the column letters of a cell reference such as "AB12" into a zero-based index.
+// References beyond the last column give maxSpreadsheetColumns.
+func columnIndex(reference string) (int, bool) {
+	index := 0
+	letters := 0
+	for _, r := range reference {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A'+1)
+		if index > maxSpreadsheetColumns {
+			return maxSpreadsheetColumns, true
+		}
+		letters++
+	}
+	if letters == 0 {
+		return 0, false
+	}
+	return index - 1, true
+}
+
+func rowHasContent(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractTextFromCSV renders a CSV file as a Markdown table. The delimiter is detected
+// from the first line among comma, semicolon and tab.
+func ExtractTextFromCSV(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse CSV: %w", err)
+		}
+		if rowHasContent(record) {
+			rows = append(rows, record)
+		}
+	}
+
+	return renderMarkdownTable(rows), nil
+}
+
+func detectDelimiter(data []byte) rune {
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	delimiter, best := ',', 0
+	for _, candidate := range []rune{',', ';', '\t'} {
+		if count := bytes.Count(firstLine, []byte(string(candidate))); count > best {
+			delimiter, best = candidate, count
+		}
+	}
+	return delimiter
+}
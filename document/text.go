@@ -0,0 +1,141 @@
+package document
+
+import (
+	"strings"
+	"unicode"
+)
+
+// textBuilder accumulates extracted text, collapsing runs of whitespace and
+// keeping at most one blank line between blocks.
+type textBuilder struct {
+	builder       strings.Builder
+	pendingSpace  bool
+	pendingPrefix string
+	newlines      int
+}
+
+// StartBlock begins a new line whose content is preceded by prefix, such as a
+// Markdown heading marker or list bullet. The prefix is only written once text follows.
+func (t *textBuilder) StartBlock(prefix string) {
+	t.LineBreak()
+	t.pendingPrefix = prefix
+}
+
+func (t *textBuilder) flushPrefix() {
+	if t.pendingPrefix == "" {
+		return
+	}
+	t.builder.WriteString(t.pendingPrefix)
+	t.pendingPrefix = ""
+	t.pendingSpace = false
+	t.newlines = 0
+}
+
+// WriteText appends text, collapsing any whitespace into single spaces.
+func (t *textBuilder) WriteText(text string) {
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			t.pendingSpace = true
+			continue
+		}
+		t.flushPrefix()
+		if t.pendingSpace && t.newlines == 0 && t.builder.Len() > 0 {
+			t.builder.WriteRune(' ')
+		}
+		t.builder.WriteRune(r)
+		t.pendingSpace = false
+		t.newlines = 0
+	}
+}
+
+// WriteRaw appends text as is, used for preformatted content.
+func (t *textBuilder) WriteRaw(text string) {
+	if text == "" {
+		return
+	}
+	t.flushPrefix()
+	if t.pendingSpace && t.newlines == 0 && t.builder.Len() > 0 {
+		t.builder.WriteRune(' ')
+	}
+	t.builder.WriteString(text)
+	t.pendingSpace = false
+	t.newlines = 0
+	if strings.HasSuffix(text, "\n") {
+		t.newlines = 1
+	}
+}
+
+// LineBreak ends the current line.
+func (t *textBuilder) LineBreak() {
+	t.pendingSpace = false
+	if t.pendingPrefix != "" || t.builder.Len() == 0 || t.newlines >= 1 {
+		return
+	}
+	t.builder.WriteRune('\n')
+	t.newlines = 1
+}
+
+// ParagraphBreak ends the current block and leaves a blank line after it.
+func (t *textBuilder) ParagraphBreak() {
+	t.pendingSpace = false
+	if t.pendingPrefix != "" || t.builder.Len() == 0 {
+		return
+	}
+	for t.newlines < 2 {
+		t.builder.WriteRune('\n')
+		t.newlines++
+	}
+}
+
+// Len returns the number of bytes written so far.
+func (t *textBuilder) Len() int {
+	return t.builder.Len()
+}
+
+func (t *textBuilder) String() string {
+	return strings.TrimSpace(t.builder.String())
+}
+
+// renderMarkdownTable renders rows as a Markdown table using the first row as the header.
+func renderMarkdownTable(rows [][]string) string {
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return ""
+	}
+
+	var table strings.Builder
+	writeRow := func(row []string) {
+		table.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(row) {
+				cell = escapeTableCell(row[i])
+			}
+			table.WriteString(" ")
+			table.WriteString(cell)
+			table.WriteString(" |")
+		}
+		table.WriteString("\n")
+	}
+
+	writeRow(rows[0])
+	table.WriteString("|")
+	for i := 0; i < columns; i++ {
+		table.WriteString(" --- |")
+	}
+	table.WriteString("\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+
+	return strings.TrimSuffix(table.String(), "\n")
+}
+
+// escapeTableCell keeps a cell on a single line and escapes the column separator.
+func escapeTableCell(cell string) string {
+	cell = strings.Join(strings.Fields(cell), " ")
+	return strings.ReplaceAll(cell, "|", `\|`)
+}
@@ -0,0 +1,129 @@
+package document
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/gommon/log"
+)
+
+// ZipLimits bounds what is expanded from an uploaded ZIP archive, so a zip bomb cannot exhaust
+// memory.
+type ZipLimits struct {
+	MaxEntries   int
+	MaxTotalSize int64
+}
+
+// DefaultZipLimits are the limits applied to uploaded ZIP archives.
+var DefaultZipLimits = ZipLimits{
+	MaxEntries:   500,
+	MaxTotalSize: 512 << 20,
+}
+
+var (
+	ErrTooManyZipEntries = errors.New("archive has too many files")
+	ErrZipTooLarge       = errors.New("archive is too large once uncompressed")
+	ErrUnsafeZipPath     = errors.New("archive entry has an unsafe path")
+)
+
+// ZipEntry is a file of an uploaded ZIP archive. Entries that could not be read carry the error.
+type ZipEntry struct {
+	Name string
+	Data []byte
+	Err  error
+}
+
+// IsZipFile reports whether an uploaded file is a ZIP archive to expand, as opposed to a document
+// format that happens to be a ZIP container such as DOCX or EPUB.
+func IsZipFile(filename string, mimeType string) bool {
+	if strings.EqualFold(filepath.Ext(filename), ".zip") {
+		return true
+	}
+	if IsSupportedFormat(filename, "") {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/zip" || mediaType == "application/x-zip-compressed"
+}
+
+// safeZipPath normalizes the path of an archive entry, rejecting absolute paths and paths that
+// escape the archive.
+func safeZipPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" {
+		return "", ErrUnsafeZipPath
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrUnsafeZipPath
+	}
+	return cleaned, nil
+}
+
+// ExpandZip reads the files of a ZIP archive, skipping directories. The whole archive is rejected
+// when it has more files or more uncompressed data than limits allow. The sizes declared by the
+// archive are not trusted, the data actually read is counted.
+func ExpandZip(data []byte, limits ZipLimits) ([]ZipEntry, error) {
+	archive, err := openArchive(data)
+	if err != nil {
+		return nil, err
+	}
+
+	entryCount := 0
+	for _, file := range archive.File {
+		if !file.FileInfo().IsDir() {
+			entryCount++
+		}
+	}
+	if entryCount > limits.MaxEntries {
+		return nil, ErrTooManyZipEntries
+	}
+
+	var entries []ZipEntry
+	remaining := limits.MaxTotalSize
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		name, err := safeZipPath(file.Name)
+		if err != nil {
+			entries = append(entries, ZipEntry{Name: file.Name, Err: err})
+			continue
+		}
+
+		if file.UncompressedSize64 > uint64(remaining) {
+			return nil, ErrZipTooLarge
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			entries = append(entries, ZipEntry{Name: name, Err: fmt.Errorf("failed to open archive entry: %w", err)})
+			continue
+		}
+		entryData, err := io.ReadAll(io.LimitReader(reader, remaining+1))
+		if closeErr := reader.Close(); closeErr != nil {
+			log.Error(closeErr)
+		}
+		if err != nil {
+			entries = append(entries, ZipEntry{Name: name, Err: fmt.Errorf("failed to read archive entry: %w", err)})
+			continue
+		}
+		if int64(len(entryData)) > remaining {
+			return nil, ErrZipTooLarge
+		}
+		remaining -= int64(len(entryData))
+
+		entries = append(entries, ZipEntry{Name: name, Data: entryData})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,165 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type zipFile struct {
+	name string
+	data string
+}
+
+func buildZip(t *testing.T, files ...zipFile) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, file := range files {
+		entry, err := writer.Create(file.name)
+		if err != nil {
+			t.Fatalf("creating %s: %v", file.name, err)
+		}
+		if _, err := entry.Write([]byte(file.data)); err != nil {
+			t.Fatalf("writing %s: %v", file.name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("closing archive: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestSafeZipPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "report.pdf", want: "report.pdf"},
+		{name: "docs/./notes.md", want: "docs/notes.md"},
+		{name: "docs/../notes.md", want: "notes.md"},
+		{name: "docs\\notes.md", want: "docs/notes.md"},
+		{name: "../notes.md", wantErr: true},
+		{name: "docs/../../notes.md", wantErr: true},
+		{name: "..\\notes.md", wantErr: true},
+		{name: "/etc/passwd", wantErr: true},
+		{name: "\\etc\\passwd", wantErr: true},
+		{name: "..", wantErr: true},
+		{name: ".", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := safeZipPath(tt.name)
+		if tt.wantErr {
+			if !errors.Is(err, ErrUnsafeZipPath) {
+				t.Errorf("safeZipPath(%q) = %q, %v, want ErrUnsafeZipPath", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("safeZipPath(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestIsZipFile(t *testing.T) {
+	tests := []struct {
+		filename string
+		mimeType string
+		want     bool
+	}{
+		{"upload.zip", "", true},
+		{"UPLOAD.ZIP", "application/octet-stream", true},
+		{"report.docx", "application/zip", false},
+		{"book.epub", "application/zip", false},
+		{"upload", "application/zip", true},
+		{"upload", "application/x-zip-compressed; charset=binary", true},
+		{"upload", "text/plain", false},
+	}
+	for _, tt := range tests {
+		if got := IsZipFile(tt.filename, tt.mimeType); got != tt.want {
+			t.Errorf("IsZipFile(%q, %q) = %v, want %v", tt.filename, tt.mimeType, got, tt.want)
+		}
+	}
+}
+
+func TestExpandZip(t *testing.T) {
+	archive := buildZip(t,
+		zipFile{name: "docs/"},
+		zipFile{name: "docs/a.txt", data: "alpha"},
+		zipFile{name: "../evil.txt", data: "evil"},
+		zipFile{name: "b.md", data: "beta"},
+	)
+
+	entries, err := ExpandZip(archive, ZipLimits{MaxEntries: 3, MaxTotalSize: 9})
+	if err != nil {
+		t.Fatalf("ExpandZip() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("ExpandZip() returned %d entries, want 3", len(entries))
+	}
+	if entries[0].Name != "docs/a.txt" || string(entries[0].Data) != "alpha" || entries[0].Err != nil {
+		t.Errorf("entries[0] = %+v", entries[0])
+	}
+	if entries[1].Name != "../evil.txt" || entries[1].Data != nil || !errors.Is(entries[1].Err, ErrUnsafeZipPath) {
+		t.Errorf("entries[1] = %+v, want an unsafe path error", entries[1])
+	}
+	if entries[2].Name != "b.md" || string(entries[2].Data) != "beta" || entries[2].Err != nil {
+		t.Errorf("entries[2] = %+v", entries[2])
+	}
+
+	if _, err := ExpandZip(archive, ZipLimits{MaxEntries: 2, MaxTotalSize: 1 << 20}); !errors.Is(err, ErrTooManyZipEntries) {
+		t.Errorf("ExpandZip() with 2 entries allowed error = %v, want ErrTooManyZipEntries", err)
+	}
+	if _, err := ExpandZip(archive, ZipLimits{MaxEntries: 3, MaxTotalSize: 8}); !errors.Is(err, ErrZipTooLarge) {
+		t.Errorf("ExpandZip() with 8 bytes allowed error = %v, want ErrZipTooLarge", err)
+	}
+	if _, err := ExpandZip([]byte("not a zip"), DefaultZipLimits); err == nil {
+		t.Error("ExpandZip() of invalid data succeeded")
+	}
+}
+
+// TestExpandZipUnderstatedSize checks that an entry inflating to more than its declared size is
+// rejected instead of being read past the limit.
+func TestExpandZipUnderstatedSize(t *testing.T) {
+	var compressed bytes.Buffer
+	compressor, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compressor.Write([]byte(strings.Repeat("a", 1<<20))); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressor.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	entry, err := writer.CreateRaw(&zip.FileHeader{
+		Name:               "bomb.txt",
+		Method:             zip.Deflate,
+		CompressedSize64:   uint64(compressed.Len()),
+		UncompressedSize64: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := entry.Write(compressed.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ExpandZip(buffer.Bytes(), ZipLimits{MaxEntries: 1, MaxTotalSize: 1000})
+	if err != nil {
+		t.Fatalf("ExpandZip() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Err == nil || entries[0].Data != nil {
+		t.Errorf("ExpandZip() = %+v, want the entry rejected", entries)
+	}
+}
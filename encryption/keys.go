@@ -0,0 +1,105 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrUnknownKey = errors.New("unknown master key")
+
+// KeyManager wraps and unwraps data keys with master keys that never leave it, such as the keys of
+// a cloud KMS. Every master key has an ID, stored next to the data keys it wrapped, so that data
+// keys wrapped by an older master key can still be unwrapped after a rotation.
+type KeyManager interface {
+	// CurrentKeyID returns the ID of the master key new data keys are wrapped with.
+	CurrentKeyID() string
+	// WrapKey wraps a data key with the current master key, returning the wrapped key and the ID of
+	// the master key.
+	WrapKey(dataKey []byte) ([]byte, string, error)
+	// UnwrapKey unwraps a data key wrapped by the master key with the given ID.
+	UnwrapKey(wrappedKey []byte, keyID string) ([]byte, error)
+}
+
+// LocalKeyManager keeps master keys in a local key file. It is meant for development, production
+// deployments should use a KMS.
+type LocalKeyManager struct {
+	currentKeyID string
+	keys         map[string][]byte
+}
+
+// localKeyFile is the format of the key file: base64 encoded 32-byte keys by ID, and the ID of the
+// key to wrap new data keys with. Rotating the master key is adding a key and making it current,
+// the older keys must stay until every data key has been wrapped again.
+type localKeyFile struct {
+	CurrentKeyID string            `json:"currentKeyId"`
+	Keys         map[string]string `json:"keys"`
+}
+
+// LoadLocalKeyManager reads the master keys from a key file.
+func LoadLocalKeyManager(path string) (*LocalKeyManager, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyFile localKeyFile
+	if err := json.Unmarshal(content, &keyFile); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+
+	manager := &LocalKeyManager{currentKeyID: keyFile.CurrentKeyID, keys: map[string][]byte{}}
+	for keyID, encodedKey := range keyFile.Keys {
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil || len(key) != DataKeySize {
+			return nil, fmt.Errorf("invalid key file: key %s must be %d base64 encoded bytes", keyID, DataKeySize)
+		}
+		manager.keys[keyID] = key
+	}
+	if _, ok := manager.keys[manager.currentKeyID]; !ok {
+		return nil, fmt.Errorf("invalid key file: current key %q is missing", manager.currentKeyID)
+	}
+
+	return manager, nil
+}
+
+func (m *LocalKeyManager) CurrentKeyID() string {
+	return m.currentKeyID
+}
+
+// WrapKey seals the data key with AES-GCM under the current master key, the nonce prefixing the
+// result.
+func (m *LocalKeyManager) WrapKey(dataKey []byte) ([]byte, string, error) {
+	aead, err := newAEAD(m.keys[m.currentKeyID])
+	if err != nil {
+		return nil, "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(m.currentKeyID)), m.currentKeyID, nil
+}
+
+func (m *LocalKeyManager) UnwrapKey(wrappedKey []byte, keyID string) ([]byte, error) {
+	masterKey, ok := m.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrappedKey) < aead.NonceSize() {
+		return nil, ErrDecryption
+	}
+	nonce, sealed := wrappedKey[:aead.NonceSize()], wrappedKey[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return dataKey, nil
+}
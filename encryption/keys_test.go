@@ -0,0 +1,85 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeKeyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing key file: %v", err)
+	}
+	return path
+}
+
+func TestLocalKeyManager(t *testing.T) {
+	oldKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, DataKeySize))
+	newKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, DataKeySize))
+
+	oldManager, err := LoadLocalKeyManager(writeKeyFile(t, `{"currentKeyId": "old", "keys": {"old": "`+oldKey+`"}}`))
+	if err != nil {
+		t.Fatalf("LoadLocalKeyManager() error = %v", err)
+	}
+	rotatedManager, err := LoadLocalKeyManager(writeKeyFile(t,
+		`{"currentKeyId": "new", "keys": {"old": "`+oldKey+`", "new": "`+newKey+`"}}`))
+	if err != nil {
+		t.Fatalf("LoadLocalKeyManager() error = %v", err)
+	}
+
+	dataKey := testKey(t)
+	wrapped, keyID, err := oldManager.WrapKey(dataKey)
+	if err != nil || keyID != "old" {
+		t.Fatalf("WrapKey() = %q, %v, want key old", keyID, err)
+	}
+
+	// Data keys wrapped before a rotation are still unwrapped with the older key.
+	unwrapped, err := rotatedManager.UnwrapKey(wrapped, keyID)
+	if err != nil || !bytes.Equal(unwrapped, dataKey) {
+		t.Fatalf("UnwrapKey() after rotation = %v, want the data key", err)
+	}
+
+	rewrapped, keyID, err := rotatedManager.WrapKey(dataKey)
+	if err != nil || keyID != "new" {
+		t.Fatalf("WrapKey() after rotation = %q, %v, want key new", keyID, err)
+	}
+	if _, err := oldManager.UnwrapKey(rewrapped, keyID); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("UnwrapKey() with unknown key error = %v, want ErrUnknownKey", err)
+	}
+	// The key ID is authenticated, a wrapped key cannot be passed off as wrapped by another key.
+	if _, err := rotatedManager.UnwrapKey(wrapped, "new"); !errors.Is(err, ErrDecryption) {
+		t.Errorf("UnwrapKey() with the wrong key ID error = %v, want ErrDecryption", err)
+	}
+	if _, err := rotatedManager.UnwrapKey([]byte{1, 2}, "old"); !errors.Is(err, ErrDecryption) {
+		t.Errorf("UnwrapKey() of a short key error = %v, want ErrDecryption", err)
+	}
+}
+
+func TestLoadLocalKeyManagerInvalid(t *testing.T) {
+	validKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, DataKeySize))
+	shortKey := base64.StdEncoding.EncodeToString([]byte("short"))
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "invalid JSON", content: `{`},
+		{name: "missing current key", content: `{"currentKeyId": "other", "keys": {"key": "` + validKey + `"}}`},
+		{name: "short key", content: `{"currentKeyId": "key", "keys": {"key": "` + shortKey + `"}}`},
+		{name: "invalid base64", content: `{"currentKeyId": "key", "keys": {"key": "not base64!"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadLocalKeyManager(writeKeyFile(t, tt.content)); err == nil {
+				t.Error("LoadLocalKeyManager() succeeded")
+			}
+		})
+	}
+	if _, err := LoadLocalKeyManager(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadLocalKeyManager() of a missing file succeeded")
+	}
+}
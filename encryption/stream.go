@@ -0,0 +1,251 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted files start with a header holding the magic, the chunk size and the nonce prefix, followed
+// by chunks each sealed with AES-GCM. The nonce of a chunk is the nonce prefix, the chunk counter and
+// a flag marking the last chunk, so chunks can be neither reordered nor dropped, and a truncated file
+// fails to decrypt. The header is authenticated with every chunk.
+const (
+	magic           = "CSENC\x01"
+	chunkSize       = 64 << 10
+	noncePrefixSize = 7
+	headerSize      = len(magic) + 4 + noncePrefixSize
+
+	// MagicSize is how many bytes IsEncrypted needs to recognize encrypted data.
+	MagicSize = len(magic)
+	// DataKeySize is the size of data keys and master keys, for AES-256.
+	DataKeySize = 32
+)
+
+var (
+	ErrNotEncrypted  = errors.New("data is not encrypted")
+	ErrInvalidHeader = errors.New("invalid encryption header")
+	ErrDecryption    = errors.New("failed to decrypt data")
+)
+
+// GenerateDataKey creates a random key to encrypt the files of a document with.
+func GenerateDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// IsEncrypted reports whether data starts with the header of an encrypted file.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(noncePrefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, noncePrefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// Writer encrypts what is written to it chunk by chunk. Close must be called to write the last chunk.
+type Writer struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buffer  []byte
+	counter uint32
+	closed  bool
+}
+
+// NewWriter returns a Writer encrypting to dst with key.
+func NewWriter(dst io.Writer, key []byte) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = binary.BigEndian.AppendUint32(header, chunkSize)
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return nil, err
+	}
+	header = append(header, noncePrefix...)
+
+	if _, err := dst.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &Writer{dst: dst, aead: aead, header: header, buffer: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *Writer) sealChunk(last bool) error {
+	if w.counter == ^uint32(0) {
+		return errors.New("too much data to encrypt")
+	}
+	nonce := chunkNonce(w.header[len(magic)+4:], w.counter, last)
+	sealed := w.aead.Seal(nil, nonce, w.buffer, w.header)
+	w.counter++
+	w.buffer = w.buffer[:0]
+	_, err := w.dst.Write(sealed)
+	return err
+}
+
+// Write encrypts p. A full chunk is only sealed once more data follows, as the last chunk is
+// sealed differently.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encryption writer")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(w.buffer) == chunkSize {
+			if err := w.sealChunk(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buffer[len(w.buffer):chunkSize], p)
+		w.buffer = w.buffer[:len(w.buffer)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the last chunk. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.sealChunk(true)
+}
+
+// Reader decrypts data written by a Writer chunk by chunk.
+type Reader struct {
+	src         *bufio.Reader
+	aead        cipher.AEAD
+	header      []byte
+	noncePrefix []byte
+	chunk       []byte
+	plaintext   []byte
+	counter     uint32
+	done        bool
+}
+
+// NewReader returns a Reader decrypting src with key.
+func NewReader(src io.Reader, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, ErrInvalidHeader
+	}
+	if !IsEncrypted(header) {
+		return nil, ErrNotEncrypted
+	}
+	if binary.BigEndian.Uint32(header[len(magic):]) != chunkSize {
+		return nil, ErrInvalidHeader
+	}
+
+	return &Reader{
+		src:         bufio.NewReader(src),
+		aead:        aead,
+		header:      header,
+		noncePrefix: header[len(magic)+4:],
+		chunk:       make([]byte, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (r *Reader) openChunk() error {
+	n, err := io.ReadFull(r.src, r.chunk)
+	last := false
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, err := r.src.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	plaintext, err := r.aead.Open(r.plaintext[:0], chunkNonce(r.noncePrefix, r.counter, last), r.chunk[:n], r.header)
+	if err != nil {
+		return ErrDecryption
+	}
+	r.plaintext = plaintext
+	r.counter++
+	r.done = last
+	return nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plaintext) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.openChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plaintext)
+	r.plaintext = r.plaintext[n:]
+	return n, nil
+}
+
+// Encrypt encrypts data with key.
+func Encrypt(data []byte, key []byte) ([]byte, error) {
+	var encrypted bytes.Buffer
+	writer, err := NewWriter(&encrypted, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return encrypted.Bytes(), nil
+}
+
+// Decrypt decrypts data encrypted with key.
+func Decrypt(data []byte, key []byte) ([]byte, error) {
+	reader, err := NewReader(bytes.NewReader(data), key)
+	if err != nil {
+		return nil, err
+	}
+	decrypted, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	return decrypted, nil
+}
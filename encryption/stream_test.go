@@ -0,0 +1,168 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := GenerateDataKey()
+	if err != nil {
+		t.Fatalf("GenerateDataKey() error = %v", err)
+	}
+	return key
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+// sealedChunkSize is the size of a full chunk once sealed.
+const sealedChunkSize = chunkSize + 16
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := testKey(t)
+	tests := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{name: "empty", size: 0, chunks: 1},
+		{name: "one byte", size: 1, chunks: 1},
+		{name: "just under a chunk", size: chunkSize - 1, chunks: 1},
+		{name: "exactly a chunk", size: chunkSize, chunks: 1},
+		{name: "just over a chunk", size: chunkSize + 1, chunks: 2},
+		{name: "several chunks", size: 3*chunkSize + 5, chunks: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testData(tt.size)
+			encrypted, err := Encrypt(data, key)
+			if err != nil {
+				t.Fatalf("Encrypt() error = %v", err)
+			}
+			if !IsEncrypted(encrypted) {
+				t.Error("IsEncrypted() = false for encrypted data")
+			}
+			if want := headerSize + tt.size + tt.chunks*16; len(encrypted) != want {
+				t.Errorf("len(Encrypt()) = %d, want %d", len(encrypted), want)
+			}
+
+			decrypted, err := Decrypt(encrypted, key)
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Error("Decrypt() did not return the encrypted data")
+			}
+		})
+	}
+}
+
+func TestWriterSmallWrites(t *testing.T) {
+	key := testKey(t)
+	data := testData(2*chunkSize + 100)
+
+	var encrypted bytes.Buffer
+	writer, err := NewWriter(&encrypted, key)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for start := 0; start < len(data); start += 1000 {
+		if _, err := writer.Write(data[start:min(start+1000, len(data))]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+	if _, err := writer.Write([]byte("late")); err == nil {
+		t.Error("Write() after Close() succeeded")
+	}
+
+	reader, err := NewReader(&encrypted, key)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	decrypted, err := io.ReadAll(iotest.OneByteReader(reader))
+	if err != nil {
+		t.Fatalf("reading decrypted data: %v", err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Error("Reader did not return the written data")
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	key := testKey(t)
+	encrypted, err := Encrypt(testData(3*chunkSize+5), key)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	chunk := func(i int) []byte {
+		start := headerSize + i*sealedChunkSize
+		return encrypted[start:min(start+sealedChunkSize, len(encrypted))]
+	}
+	header := encrypted[:headerSize]
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	flip := func(data []byte, i int) []byte {
+		data = bytes.Clone(data)
+		data[i] ^= 1
+		return data
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		key     []byte
+		wantErr error
+	}{
+		{name: "wrong key", data: encrypted, key: testKey(t), wantErr: ErrDecryption},
+		{name: "last chunk dropped", data: join(header, chunk(0), chunk(1), chunk(2)), key: key, wantErr: ErrDecryption},
+		{name: "truncated inside a chunk", data: encrypted[:headerSize+sealedChunkSize+100], key: key, wantErr: ErrDecryption},
+		{name: "chunks swapped", data: join(header, chunk(1), chunk(0), chunk(2), chunk(3)), key: key, wantErr: ErrDecryption},
+		{name: "chunk repeated", data: join(header, chunk(0), chunk(0), chunk(1), chunk(2), chunk(3)), key: key, wantErr: ErrDecryption},
+		{name: "ciphertext modified", data: flip(encrypted, headerSize+10), key: key, wantErr: ErrDecryption},
+		{name: "nonce prefix modified", data: flip(encrypted, headerSize-1), key: key, wantErr: ErrDecryption},
+		{name: "chunk size modified", data: flip(encrypted, len(magic)+3), key: key, wantErr: ErrInvalidHeader},
+		{name: "short header", data: encrypted[:headerSize-1], key: key, wantErr: ErrInvalidHeader},
+		{name: "not encrypted", data: testData(100), key: key, wantErr: ErrNotEncrypted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decrypt(tt.data, tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptUsesFreshNonces(t *testing.T) {
+	key := testKey(t)
+	data := testData(100)
+	first, err := Encrypt(data, key)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	second, err := Encrypt(data, key)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("encrypting the same data twice gave the same result")
+	}
+}
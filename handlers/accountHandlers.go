@@ -4,11 +4,17 @@ import (
 	"cloud-solutions-api/authentication"
 	"cloud-solutions-api/models"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
 func (hc *HandlerContext) login(c echo.Context) error {
@@ -85,18 +91,117 @@ func (hc *HandlerContext) GetAccountByID(c echo.Context) error {
 	return c.JSON(http.StatusOK, account)
 }
 
+// documentSortKeys maps the sort query parameter of document listings to the sort keys of the
+// ListAccountDocuments query.
+var documentSortKeys = map[string]string{
+	"name":      "name",
+	"createdAt": "created_at",
+	"size":      "size",
+}
+
+// parseDateParam parses a date query parameter given either as a date or as an RFC 3339 timestamp.
+func parseDateParam(c echo.Context, name string) (sql.NullTime, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return sql.NullTime{Time: parsed, Valid: true}, nil
+		}
+	}
+	return sql.NullTime{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid %s date", name))
+}
+
+// GetAccountDocuments lists the documents of the current user. Results can be filtered by tags
+// (comma separated, documents must have all of them), name, extension, creation date range
+// (createdAfter inclusive, createdBefore exclusive), indexing status (indexed or pending) and
+// metadata (a JSON object the document metadata must contain), and sorted by name, createdAt or
+// size in asc or desc order. Ties are broken by ID, so pagination is stable.
 func (hc *HandlerContext) GetAccountDocuments(c echo.Context) error {
 	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
 	offset, limit := getOffsetLimit(c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
 	}
-	documents, err := hc.Queryer.GetDocumentsByAccountID(
+
+	tags := []string{}
+	if tagsString := c.QueryParam("tags"); tagsString != "" {
+		for _, tag := range strings.Split(tagsString, ",") {
+			normalized, err := normalizeTag(tag)
+			if err != nil {
+				return err
+			}
+			tags = append(tags, normalized)
+		}
+	}
+
+	extension := strings.ToLower(strings.TrimPrefix(c.QueryParam("extension"), "."))
+	if extension != "" {
+		if strings.ContainsFunc(extension, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid extension")
+		}
+		extension = "." + extension
+	}
+
+	createdAfter, err := parseDateParam(c, "createdAfter")
+	if err != nil {
+		return err
+	}
+	createdBefore, err := parseDateParam(c, "createdBefore")
+	if err != nil {
+		return err
+	}
+
+	status := c.QueryParam("status")
+	if status != "" && status != "indexed" && status != "pending" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid status")
+	}
+
+	metadataFilter := json.RawMessage("{}")
+	if metadataString := c.QueryParam("metadata"); metadataString != "" {
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(metadataString), &fields); err != nil || fields == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Metadata filter must be a JSON object")
+		}
+		metadataFilter = json.RawMessage(metadataString)
+	}
+
+	sortBy := "created_at"
+	if sortString := c.QueryParam("sort"); sortString != "" {
+		sortKey, ok := documentSortKeys[sortString]
+		if !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid sort")
+		}
+		sortBy = sortKey
+	}
+
+	descending := sortBy == "created_at"
+	switch c.QueryParam("order") {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid order")
+	}
+
+	documents, err := hc.Queryer.ListAccountDocuments(
 		context.Background(),
-		models.GetDocumentsByAccountIDParams{
-			AccountID: account.ID,
-			Offset:    int32(offset),
-			Limit:     int32(limit),
+		models.ListAccountDocumentsParams{
+			AccountID:      account.ID,
+			Tags:           tags,
+			NameContains:   c.QueryParam("name"),
+			Extension:      extension,
+			CreatedAfter:   createdAfter,
+			CreatedBefore:  createdBefore,
+			Status:         status,
+			MetadataFilter: metadataFilter,
+			SortBy:         sortBy,
+			Descending:     descending,
+			PageOffset:     int32(offset),
+			PageLimit:      int32(limit),
 		},
 	)
 	if err != nil {
@@ -107,6 +212,68 @@ func (hc *HandlerContext) GetAccountDocuments(c echo.Context) error {
 	)
 }
 
+// SearchAccountDocuments runs a full-text search over the documents of the current user, including
+// the ones shared with them. The search
+// can be scoped to a collection with collectionID, including its sub-collections when recursive=true.
+func (hc *HandlerContext) SearchAccountDocuments(c echo.Context) error {
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	query := strings.TrimSpace(c.QueryParam("q"))
+	if query == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing search query")
+	}
+
+	collectionID := sql.NullInt32{}
+	if collectionIDString := c.QueryParam("collectionID"); collectionIDString != "" {
+		id, err := strconv.Atoi(collectionIDString)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+		}
+		owned, err := hc.accountOwnsCollection(c, int32(id))
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return echo.NewHTTPError(http.StatusForbidden, "Forbidden: You do not own this collection")
+		}
+		collectionID = sql.NullInt32{Int32: int32(id), Valid: true}
+	}
+
+	recursive := false
+	if recursiveString := c.QueryParam("recursive"); recursiveString != "" {
+		recursive, err = strconv.ParseBool(recursiveString)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid recursive flag")
+		}
+	}
+
+	offset, limit := getOffsetLimit(c)
+
+	documents, err := hc.Queryer.SearchDocuments(
+		context.Background(),
+		models.SearchDocumentsParams{
+			CollectionID: collectionID,
+			Recursive:    recursive,
+			AccountID:    account.ID,
+			Query:        query,
+			PageOffset:   int32(offset),
+			PageLimit:    int32(limit),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, documents)
+}
+
+// GetAccountChats lists summaries of the chats of the current user, the pinned chats first and then
+// the most recently active. Archived chats are listed instead when archived is true, and pinned
+// restricts the list to the chats that are pinned or not. The messages of a chat are loaded page by
+// page from its messages endpoint.
 func (hc *HandlerContext) GetAccountChats(c echo.Context) error {
 	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
 	if err != nil {
@@ -115,12 +282,30 @@ func (hc *HandlerContext) GetAccountChats(c echo.Context) error {
 
 	offset, limit := getOffsetLimit(c)
 
-	chats, err := hc.Queryer.GetChatsByAccountID(
+	archived := false
+	if archivedString := c.QueryParam("archived"); archivedString != "" {
+		archived, err = strconv.ParseBool(archivedString)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid archived filter")
+		}
+	}
+	var pinned sql.NullBool
+	if pinnedString := c.QueryParam("pinned"); pinnedString != "" {
+		pinned.Bool, err = strconv.ParseBool(pinnedString)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid pinned filter")
+		}
+		pinned.Valid = true
+	}
+
+	chats, err := hc.Queryer.ListChatSummaries(
 		context.Background(),
-		models.GetChatsByAccountIDParams{
-			AccountID: account.ID,
-			Offset:    int32(offset),
-			Limit:     int32(limit),
+		models.ListChatSummariesParams{
+			AccountID:  account.ID,
+			Archived:   archived,
+			Pinned:     pinned,
+			PageOffset: int32(offset),
+			PageLimit:  int32(limit),
 		},
 	)
 	if err != nil {
@@ -138,5 +323,9 @@ func RegisterAccountRoutes(e *echo.Echo, hc *HandlerContext) {
 	accountGroup.POST("", hc.CreateUser)
 	accountGroup.GET("", hc.GetAccountByID, restricted)
 	accountGroup.GET("/documents", hc.GetAccountDocuments, restricted)
+	accountGroup.GET("/documents/search", hc.SearchAccountDocuments, restricted)
+	accountGroup.GET("/documents/shared", hc.GetSharedDocuments, restricted)
+	accountGroup.GET("/tags", hc.GetAccountTags, restricted)
+	accountGroup.GET("/usage", hc.GetAccountUsage, restricted)
 	accountGroup.GET("/chats", hc.GetAccountChats, restricted)
 }
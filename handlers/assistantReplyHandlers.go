@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"cloud-solutions-api/chat"
+	"cloud-solutions-api/models"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxTokenDeltaSize keeps token deltas small enough to be sent along with their event.
+	maxTokenDeltaSize = 4096
+	// maxCitations is the most passages a reply can cite.
+	maxCitations = 50
+	// maxCitationQuoteLength is the longest passage a citation can quote.
+	maxCitationQuoteLength = 2000
+	// maxModelNameLength is the longest model name a reply can be described with.
+	maxModelNameLength = 200
+)
+
+// InternalTokenMiddleware lets through the requests carrying the internal token as a bearer token.
+// Every request is rejected when no internal token is configured.
+func (hc *HandlerContext) InternalTokenMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token, found := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if len(hc.InternalToken) == 0 || !found || subtle.ConstantTimeCompare([]byte(token), hc.InternalToken) != 1 {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+		}
+		return next(c)
+	}
+}
+
+// CreateAssistantReply adds the answer of the assistant to a chat and marks the chat as unread.
+// The assistant service identifies each reply with a replyId of its choosing, unique within the
+// chat: a reply that was already added is not added again, so redelivered replies can be posted
+// safely. The chat is returned with 201 when the reply is added and 200 when it was already there.
+// The reply follows the parentMessageId it was sent, or the active message of the chat without one.
+// Its citations may only refer to documents the owner of the chat can access.
+func (hc *HandlerContext) CreateAssistantReply(c echo.Context) error {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	var replyParams = struct {
+		ReplyID         string                  `json:"replyId"`
+		Text            string                  `json:"text"`
+		ParentMessageID string                  `json:"parentMessageId"`
+		Citations       []models.Citation       `json:"citations"`
+		Metadata        *models.MessageMetadata `json:"metadata"`
+	}{}
+	if err := c.Bind(&replyParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	replyParams.ReplyID = strings.TrimSpace(replyParams.ReplyID)
+	if replyParams.ReplyID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Reply ID cannot be empty")
+	}
+	if strings.TrimSpace(replyParams.Text) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Text cannot be empty")
+	}
+	if err := checkCitations(replyParams.Citations); err != nil {
+		return err
+	}
+	if err := checkMessageMetadata(replyParams.Metadata); err != nil {
+		return err
+	}
+	var parentMessageID uuid.NullUUID
+	if replyParams.ParentMessageID != "" {
+		parentMessageID.UUID, err = uuid.Parse(replyParams.ParentMessageID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid message ID")
+		}
+		parentMessageID.Valid = true
+	}
+
+	status := http.StatusCreated
+	var updatedChat models.Chat
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		retrievedChat, err := queries.GetChatForUpdate(context.Background(), int32(chatID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Chat not found")
+		}
+		if err != nil {
+			return err
+		}
+		updatedChat = retrievedChat
+
+		recorded, err := queries.RecordChatReply(context.Background(), models.RecordChatReplyParams{
+			ReplyID: replyParams.ReplyID,
+			ChatID:  retrievedChat.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if recorded == 0 {
+			status = http.StatusOK
+			return nil
+		}
+		// Citations are only checked for replies being added, a redelivered reply is answered the
+		// same way even when the documents it cites were deleted meanwhile.
+		if err := checkCitedDocuments(queries, retrievedChat.AccountID, replyParams.Citations); err != nil {
+			return err
+		}
+
+		// The answer follows the message it was asked for, unless that message was deleted in the
+		// meantime. It only becomes the active message when that message still is.
+		parentID := retrievedChat.ActiveMessageID
+		if parentMessageID.Valid {
+			_, err := queries.GetChatMessage(context.Background(), models.GetChatMessageParams{
+				ID:     parentMessageID.UUID,
+				ChatID: retrievedChat.ID,
+			})
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if err == nil {
+				parentID = parentMessageID
+			}
+		}
+		var chatMessage models.ChatMessage
+		if parentID == retrievedChat.ActiveMessageID {
+			chatMessage, err = addChatMessage(queries, &updatedChat, parentID, chat.NewMessageParameters{
+				Sender: models.Assistant,
+				Text:   replyParams.Text,
+			})
+		} else {
+			chatMessage, err = queries.AppendChatMessage(context.Background(), models.AppendChatMessageParams{
+				ChatID:   retrievedChat.ID,
+				ParentID: parentID,
+				Sender:   string(models.Assistant),
+				Text:     replyParams.Text,
+			})
+			updatedChat.LastMessageSequence = chatMessage.Sequence
+		}
+		if err != nil {
+			return err
+		}
+		chatMessage, err = addReplyDetails(queries, chatMessage, replyParams.Citations, replyParams.Metadata)
+		if err != nil {
+			return err
+		}
+
+		if err := queries.MarkAsUnreadByID(context.Background(), retrievedChat.ID); err != nil {
+			return err
+		}
+		updatedChat.UnreadMessages = sql.NullBool{Bool: true, Valid: true}
+
+		messages, err := completeMessages(queries, []models.ChatMessage{chatMessage})
+		if err != nil {
+			return err
+		}
+		event, err := chat.NewMessageAppendedEvent(retrievedChat.ID, messages[0])
+		if err != nil {
+			return err
+		}
+		if err := publishChatEvent(queries, event); err != nil {
+			return err
+		}
+		event, err = chat.NewReadStateEvent(retrievedChat.ID, true)
+		if err != nil {
+			return err
+		}
+		if err := publishChatEvent(queries, event); err != nil {
+			return err
+		}
+		event, err = chat.NewProcessingEvent(retrievedChat.ID, false)
+		if err != nil {
+			return err
+		}
+		return publishChatEvent(queries, event)
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := chatWithMessages(hc.Queryer, updatedChat)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(status, response)
+}
+
+// checkCitations validates the citations of a reply.
+func checkCitations(citations []models.Citation) error {
+	if len(citations) > maxCitations {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("A reply cannot cite more than %d passages", maxCitations))
+	}
+	for _, citation := range citations {
+		if citation.PageNumber != nil && *citation.PageNumber < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid citation page number")
+		}
+		if citation.ChunkIndex != nil && *citation.ChunkIndex < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid citation chunk index")
+		}
+		if utf8.RuneCountInString(citation.Quote) > maxCitationQuoteLength {
+			return echo.NewHTTPError(http.StatusBadRequest, "Citation quote is too long")
+		}
+	}
+	return nil
+}
+
+// checkMessageMetadata validates the description of how the assistant wrote a reply.
+func checkMessageMetadata(metadata *models.MessageMetadata) error {
+	if metadata == nil {
+		return nil
+	}
+	if utf8.RuneCountInString(metadata.Model) > maxModelNameLength {
+		return echo.NewHTTPError(http.StatusBadRequest, "Model name is too long")
+	}
+	for _, value := range []*int32{metadata.PromptTokens, metadata.CompletionTokens, metadata.LatencyMs} {
+		if value != nil && *value < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid reply metadata")
+		}
+	}
+	return nil
+}
+
+// checkCitedDocuments makes sure a reply only cites documents the owner of the chat can access.
+func checkCitedDocuments(queries *models.Queries, accountID int32, citations []models.Citation) error {
+	if len(citations) == 0 {
+		return nil
+	}
+	documentIDs := make([]int32, len(citations))
+	for i, citation := range citations {
+		documentIDs[i] = citation.DocumentID
+	}
+
+	inaccessible, err := queries.ListInaccessibleDocumentIDs(context.Background(), models.ListInaccessibleDocumentIDsParams{
+		DocumentIds: documentIDs,
+		AccountID:   accountID,
+	})
+	if err != nil {
+		return err
+	}
+	if len(inaccessible) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid cited document ID %d", inaccessible[0]))
+	}
+	return nil
+}
+
+// addReplyDetails stores the citations of a reply and how the assistant wrote it.
+func addReplyDetails(
+	queries *models.Queries,
+	chatMessage models.ChatMessage,
+	citations []models.Citation,
+	metadata *models.MessageMetadata,
+) (models.ChatMessage, error) {
+	for i, citation := range citations {
+		params := models.CreateChatMessageCitationParams{
+			MessageID:  chatMessage.ID,
+			Position:   int32(i),
+			DocumentID: citation.DocumentID,
+			PageNumber: nullInt32(citation.PageNumber),
+			ChunkIndex: nullInt32(citation.ChunkIndex),
+			Quote:      citation.Quote,
+		}
+		if citation.Score != nil {
+			params.Score = sql.NullFloat64{Float64: *citation.Score, Valid: true}
+		}
+		if err := queries.CreateChatMessageCitation(context.Background(), params); err != nil {
+			return chatMessage, err
+		}
+	}
+
+	if metadata == nil {
+		return chatMessage, nil
+	}
+	return queries.SetChatMessageMetadata(context.Background(), models.SetChatMessageMetadataParams{
+		Model:            sql.NullString{String: metadata.Model, Valid: metadata.Model != ""},
+		PromptTokens:     nullInt32(metadata.PromptTokens),
+		CompletionTokens: nullInt32(metadata.CompletionTokens),
+		LatencyMs:        nullInt32(metadata.LatencyMs),
+		ID:               chatMessage.ID,
+	})
+}
+
+// nullInt32 turns an optional number of a request into a query parameter.
+func nullInt32(value *int32) sql.NullInt32 {
+	if value == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *value, Valid: true}
+}
+
+// CreateAssistantTokenDelta forwards a piece of an answer the assistant is still writing to the
+// clients following the chat. Deltas are not stored, the complete answer is posted as a reply
+// with the same replyId once it is written.
+func (hc *HandlerContext) CreateAssistantTokenDelta(c echo.Context) error {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	var deltaParams = struct {
+		ReplyID string `json:"replyId"`
+		Delta   string `json:"delta"`
+	}{}
+	if err := c.Bind(&deltaParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	if strings.TrimSpace(deltaParams.ReplyID) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Reply ID cannot be empty")
+	}
+	if len(deltaParams.Delta) > maxTokenDeltaSize {
+		return echo.NewHTTPError(http.StatusBadRequest, "Delta is too large")
+	}
+
+	retrievedChat, err := hc.Queryer.GetChatByID(context.Background(), int32(chatID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Chat not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	event, err := chat.NewTokenDeltaEvent(retrievedChat.ID, strings.TrimSpace(deltaParams.ReplyID), deltaParams.Delta)
+	if err != nil {
+		return err
+	}
+	if err := publishChatEvent(hc.Queryer, event); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// SetAssistantChatTitle replaces the title of a chat with one written by the assistant. A title
+// the user gave the chat is kept, the chat is then returned unchanged.
+func (hc *HandlerContext) SetAssistantChatTitle(c echo.Context) error {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	var titleParams = struct {
+		Title string `json:"title"`
+	}{}
+	if err := c.Bind(&titleParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	title, err := chatTitle(titleParams.Title)
+	if err != nil {
+		return err
+	}
+
+	retrievedChat, err := hc.Queryer.GetChatByID(context.Background(), int32(chatID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Chat not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		updatedChat, err := queries.SetChatTitleUnlessEdited(context.Background(), models.SetChatTitleUnlessEditedParams{
+			Title: title,
+			ID:    retrievedChat.ID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		retrievedChat = updatedChat
+
+		event, err := chat.NewChatUpdatedEvent(retrievedChat)
+		if err != nil {
+			return err
+		}
+		return publishChatEvent(queries, event)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, retrievedChat)
+}
+
+// RegisterInternalRoutes sets up the routes called by the other services rather than by users.
+func RegisterInternalRoutes(e *echo.Echo, hc *HandlerContext) {
+	internalGroup := e.Group("/internal", hc.InternalTokenMiddleware)
+	internalGroup.POST("/chats/:chatID/replies", hc.CreateAssistantReply)
+	internalGroup.POST("/chats/:chatID/deltas", hc.CreateAssistantTokenDelta)
+	internalGroup.PUT("/chats/:chatID/title", hc.SetAssistantChatTitle)
+}
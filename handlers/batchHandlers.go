@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"cloud-solutions-api/authentication"
+	"cloud-solutions-api/chat"
+	"cloud-solutions-api/models"
+	"context"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const (
+	batchActionDelete           = "delete"
+	batchActionTag              = "tag"
+	batchActionMoveToCollection = "move-to-collection"
+	batchActionMarkAsRead       = "mark-as-read"
+
+	maxBatchSize = 100
+)
+
+// batchItemResult reports what happened to one item of a batch operation.
+type batchItemResult struct {
+	ID    int32  `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+// batchRequest is the body of a batch operation. Tags are used by the tag action and collectionId
+// by the move-to-collection action.
+type batchRequest struct {
+	Action       string     `json:"action"`
+	IDs          []int32    `json:"ids"`
+	Tags         []string   `json:"tags"`
+	CollectionID optionalID `json:"collectionId"`
+}
+
+// bindBatchRequest reads a batch operation, dropping repeated IDs.
+func bindBatchRequest(c echo.Context) (batchRequest, error) {
+	var request batchRequest
+	if err := c.Bind(&request); err != nil {
+		return request, echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	if len(request.IDs) == 0 || len(request.IDs) > maxBatchSize {
+		return request, echo.NewHTTPError(http.StatusBadRequest, "A batch must have between 1 and 100 IDs")
+	}
+
+	seen := map[int32]bool{}
+	ids := make([]int32, 0, len(request.IDs))
+	for _, id := range request.IDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	request.IDs = ids
+
+	return request, nil
+}
+
+// filterDocumentBatch reports the documents of a batch the account has no access to, or not the
+// required one, and returns the IDs of the others.
+func filterDocumentBatch(
+	ids []int32,
+	permissions []models.GetDocumentPermissionsRow,
+	required DocumentPermission,
+) ([]batchItemResult, []int32) {
+	permissionByID := map[int32]DocumentPermission{}
+	for _, permission := range permissions {
+		permissionByID[permission.DocumentID] = documentPermissions[permission.Permission]
+	}
+
+	results := make([]batchItemResult, len(ids))
+	var allowed []int32
+	for i, id := range ids {
+		results[i].ID = id
+		permission, ok := permissionByID[id]
+		switch {
+		case !ok:
+			results[i].Error = "Document not found"
+		case permission < required:
+			results[i].Error = "Forbidden: You do not have access to this document"
+		default:
+			allowed = append(allowed, id)
+		}
+	}
+	return results, allowed
+}
+
+// filterChatBatch reports the chats of a batch that are not among the chats owned by the account.
+func filterChatBatch(ids []int32, ownedIDs []int32) []batchItemResult {
+	owned := map[int32]bool{}
+	for _, id := range ownedIDs {
+		owned[id] = true
+	}
+
+	results := make([]batchItemResult, len(ids))
+	for i, id := range ids {
+		results[i].ID = id
+		if !owned[id] {
+			results[i].Error = "Chat not found"
+		}
+	}
+	return results
+}
+
+// BatchDocuments applies an action to several documents at once: delete moves them to the trash,
+// tag adds tags to them and move-to-collection takes them out of the other collections of the
+// current user and puts them in the given one. Access to every document is checked up front and
+// the documents that pass are updated in a single transaction.
+func (hc *HandlerContext) BatchDocuments(c echo.Context) error {
+	request, err := bindBatchRequest(c)
+	if err != nil {
+		return err
+	}
+
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	var required DocumentPermission
+	var tags []string
+	switch request.Action {
+	case batchActionDelete:
+		required = DocumentOwner
+	case batchActionTag:
+		required = DocumentEditor
+		for _, tag := range request.Tags {
+			normalized, err := normalizeTag(tag)
+			if err != nil {
+				return err
+			}
+			tags = append(tags, normalized)
+		}
+		if len(tags) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Tags cannot be empty")
+		}
+	case batchActionMoveToCollection:
+		required = DocumentViewer
+		if !request.CollectionID.Value.Valid {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+		}
+		owned, err := hc.accountOwnsCollection(c, request.CollectionID.Value.Int32)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Action must be delete, tag or move-to-collection")
+	}
+
+	permissions, err := hc.Queryer.GetDocumentPermissions(
+		context.Background(),
+		models.GetDocumentPermissionsParams{AccountID: account.ID, DocumentIds: request.IDs},
+	)
+	if err != nil {
+		return err
+	}
+	results, allowed := filterDocumentBatch(request.IDs, permissions, required)
+
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		for _, id := range allowed {
+			var err error
+			switch request.Action {
+			case batchActionDelete:
+				err = queries.TrashDocument(context.Background(), id)
+			case batchActionTag:
+				for _, tag := range tags {
+					err = queries.AddDocumentTag(context.Background(), models.AddDocumentTagParams{
+						DocumentID: id,
+						Tag:        tag,
+					})
+					if err != nil {
+						break
+					}
+				}
+			case batchActionMoveToCollection:
+				err = queries.RemoveDocumentFromAccountCollections(
+					context.Background(),
+					models.RemoveDocumentFromAccountCollectionsParams{DocumentID: id, AccountID: account.ID},
+				)
+				if err == nil {
+					err = queries.AddDocumentToCollection(context.Background(), models.AddDocumentToCollectionParams{
+						CollectionID: request.CollectionID.Value.Int32,
+						DocumentID:   id,
+					})
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"results": results})
+}
+
+// BatchChats applies an action to several chats at once: delete moves them to the trash,
+// mark-as-read marks them as read and move-to-collection scopes them to the given collection, or
+// to every document again when collectionId is null. Ownership of every chat is checked up front
+// and the chats that pass are updated in a single transaction.
+func (hc *HandlerContext) BatchChats(c echo.Context) error {
+	request, err := bindBatchRequest(c)
+	if err != nil {
+		return err
+	}
+
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	switch request.Action {
+	case batchActionDelete, batchActionMarkAsRead:
+	case batchActionMoveToCollection:
+		if !request.CollectionID.Set {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+		}
+		if err := hc.checkChatCollection(c, request.CollectionID.Value); err != nil {
+			return err
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Action must be delete, mark-as-read or move-to-collection")
+	}
+
+	ownedIDs, err := hc.Queryer.GetOwnedChatIDs(
+		context.Background(),
+		models.GetOwnedChatIDsParams{AccountID: account.ID, ChatIds: request.IDs},
+	)
+	if err != nil {
+		return err
+	}
+	results := filterChatBatch(request.IDs, ownedIDs)
+
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		for _, id := range ownedIDs {
+			var err error
+			switch request.Action {
+			case batchActionDelete:
+				err = queries.TrashChat(context.Background(), id)
+			case batchActionMarkAsRead:
+				err = queries.MarkAsReadByID(context.Background(), id)
+				if err == nil {
+					var event chat.Event
+					event, err = chat.NewReadStateEvent(id, false)
+					if err == nil {
+						err = publishChatEvent(queries, event)
+					}
+				}
+			case batchActionMoveToCollection:
+				_, err = queries.UpdateChatCollection(context.Background(), models.UpdateChatCollectionParams{
+					CollectionID: request.CollectionID.Value,
+					ID:           id,
+				})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"results": results})
+}
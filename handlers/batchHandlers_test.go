@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"cloud-solutions-api/models"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFilterDocumentBatch(t *testing.T) {
+	permissions := []models.GetDocumentPermissionsRow{
+		{DocumentID: 1, Permission: "owner"},
+		{DocumentID: 2, Permission: "editor"},
+		{DocumentID: 3, Permission: "viewer"},
+		{DocumentID: 4, Permission: "unknown"},
+	}
+	ids := []int32{3, 1, 9, 2, 4}
+
+	tests := []struct {
+		name        string
+		required    DocumentPermission
+		wantAllowed []int32
+		wantResults []batchItemResult
+	}{
+		{
+			name:        "owner required",
+			required:    DocumentOwner,
+			wantAllowed: []int32{1},
+			wantResults: []batchItemResult{
+				{ID: 3, Error: "Forbidden: You do not have access to this document"},
+				{ID: 1},
+				{ID: 9, Error: "Document not found"},
+				{ID: 2, Error: "Forbidden: You do not have access to this document"},
+				{ID: 4, Error: "Forbidden: You do not have access to this document"},
+			},
+		},
+		{
+			name:        "editor required",
+			required:    DocumentEditor,
+			wantAllowed: []int32{1, 2},
+			wantResults: []batchItemResult{
+				{ID: 3, Error: "Forbidden: You do not have access to this document"},
+				{ID: 1},
+				{ID: 9, Error: "Document not found"},
+				{ID: 2},
+				{ID: 4, Error: "Forbidden: You do not have access to this document"},
+			},
+		},
+		{
+			name:        "viewer required",
+			required:    DocumentViewer,
+			wantAllowed: []int32{3, 1, 2},
+			wantResults: []batchItemResult{
+				{ID: 3},
+				{ID: 1},
+				{ID: 9, Error: "Document not found"},
+				{ID: 2},
+				{ID: 4, Error: "Forbidden: You do not have access to this document"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, allowed := filterDocumentBatch(ids, permissions, tt.required)
+			if !slices.Equal(allowed, tt.wantAllowed) {
+				t.Errorf("allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+			if !slices.Equal(results, tt.wantResults) {
+				t.Errorf("results = %+v, want %+v", results, tt.wantResults)
+			}
+		})
+	}
+}
+
+func TestFilterChatBatch(t *testing.T) {
+	results := filterChatBatch([]int32{5, 6, 7}, []int32{7, 5})
+	want := []batchItemResult{{ID: 5}, {ID: 6, Error: "Chat not found"}, {ID: 7}}
+	if !slices.Equal(results, want) {
+		t.Errorf("filterChatBatch() = %+v, want %+v", results, want)
+	}
+}
+
+func TestBindBatchRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantIDs []int32
+		wantErr bool
+	}{
+		{name: "repeated IDs", body: `{"action":"delete","ids":[3,1,3,2,1]}`, wantIDs: []int32{3, 1, 2}},
+		{name: "no IDs", body: `{"action":"delete","ids":[]}`, wantErr: true},
+		{name: "too many IDs", body: `{"action":"delete","ids":[` + strings.Repeat("1,", maxBatchSize) + `1]}`, wantErr: true},
+		{name: "invalid JSON", body: `{"ids":`, wantErr: true},
+	}
+	e := echo.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			got, err := bindBatchRequest(e.NewContext(request, httptest.NewRecorder()))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("bindBatchRequest() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bindBatchRequest() error = %v", err)
+			}
+			if !slices.Equal(got.IDs, tt.wantIDs) {
+				t.Errorf("IDs = %v, want %v", got.IDs, tt.wantIDs)
+			}
+		})
+	}
+}
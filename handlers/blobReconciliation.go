@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"cloud-solutions-api/document"
+	"cloud-solutions-api/models"
+	"context"
+	"database/sql"
+	"github.com/labstack/gommon/log"
+	"time"
+)
+
+const (
+	// blobReconciliationInterval is how often the bucket is reconciled with the database.
+	blobReconciliationInterval = 6 * time.Hour
+	// pendingBlobDeletionBatchSize is how many failed deletions are retried per reconciliation.
+	pendingBlobDeletionBatchSize = 500
+)
+
+// BlobReconciliationOptions configures how uploaded files no document points to are handled.
+type BlobReconciliationOptions struct {
+	// GracePeriod keeps recent uploads, whose document may not be committed yet, out of the orphans.
+	GracePeriod time.Duration
+	// DeleteOrphans deletes orphaned uploads instead of only reporting them.
+	DeleteOrphans bool
+	// RetryPendingDeletions retries the deletions that failed earlier.
+	RetryPendingDeletions bool
+}
+
+// BlobReconciliationReport is the outcome of a reconciliation of the bucket with the database.
+type BlobReconciliationReport struct {
+	Orphans          []string
+	DeletedOrphans   int
+	RetriedDeletions int
+	FailedDeletions  int
+}
+
+// deleteBlob deletes an object, or every object under a prefix when isPrefix is set. A deletion
+// that fails is recorded so that the reconciliation job retries it, so the objects must not be
+// written again under the same name later: prefixes are those of a purged document or of a
+// replaced version.
+func (hc *HandlerContext) deleteBlob(name string, isPrefix bool) {
+	var err error
+	if isPrefix {
+		err = document.DeleteObjectsWithPrefix(name, hc.Bucket)
+	} else {
+		err = document.DeleteObjectFromBucket(name, hc.Bucket)
+	}
+	if err == nil {
+		return
+	}
+
+	log.Errorf("error deleting %s from bucket, it will be retried: %s", name, err)
+	err = hc.Queryer.RecordPendingBlobDeletion(context.Background(), models.RecordPendingBlobDeletionParams{
+		ObjectName: name,
+		IsPrefix:   isPrefix,
+		LastError:  sql.NullString{String: err.Error(), Valid: true},
+	})
+	if err != nil {
+		log.Errorf("error recording pending blob deletion: %s", err)
+	}
+}
+
+// deleteDocumentFile deletes a document file given its URL, see deleteBlob.
+func (hc *HandlerContext) deleteDocumentFile(fileURL string) {
+	name, err := document.ObjectNameFromURL(fileURL, hc.Bucket)
+	if err != nil {
+		log.Errorf("error deleting document file from bucket: %s", err)
+		return
+	}
+	hc.deleteBlob(name, false)
+}
+
+// retryPendingBlobDeletions retries the deletions that failed earlier, the least retried first,
+// forgetting the ones that succeed.
+func (hc *HandlerContext) retryPendingBlobDeletions(report *BlobReconciliationReport) error {
+	pendingDeletions, err := hc.Queryer.ListPendingBlobDeletions(context.Background(), pendingBlobDeletionBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, pendingDeletion := range pendingDeletions {
+		report.RetriedDeletions++
+		if pendingDeletion.IsPrefix {
+			err = document.DeleteObjectsWithPrefix(pendingDeletion.ObjectName, hc.Bucket)
+		} else {
+			err = document.DeleteObjectFromBucket(pendingDeletion.ObjectName, hc.Bucket)
+		}
+
+		if err != nil {
+			report.FailedDeletions++
+			err = hc.Queryer.RecordBlobDeletionFailure(context.Background(), models.RecordBlobDeletionFailureParams{
+				LastError: sql.NullString{String: err.Error(), Valid: true},
+				ID:        pendingDeletion.ID,
+			})
+		} else {
+			err = hc.Queryer.DeletePendingBlobDeletion(context.Background(), pendingDeletion.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReconcileBlobs looks for uploaded files older than the grace period that no document or
+// document version points to, deleting them and retrying failed deletions when the options say
+// so. Files of trashed documents are still pointed to, as the documents can be restored.
+func (hc *HandlerContext) ReconcileBlobs(options BlobReconciliationOptions) (BlobReconciliationReport, error) {
+	var report BlobReconciliationReport
+
+	if options.RetryPendingDeletions {
+		if err := hc.retryPendingBlobDeletions(&report); err != nil {
+			return report, err
+		}
+	}
+
+	// Objects are listed before the paths, so an upload committed in between is never an orphan.
+	objects, err := document.ListObjectsWithPrefix(document.UploadsPrefix, hc.Bucket)
+	if err != nil {
+		return report, err
+	}
+
+	filePaths, err := hc.Queryer.ListReferencedFilePaths(context.Background())
+	if err != nil {
+		return report, err
+	}
+	referenced := map[string]bool{}
+	for _, filePath := range filePaths {
+		if name, err := document.ObjectNameFromURL(filePath.String, hc.Bucket); err == nil {
+			referenced[name] = true
+		}
+	}
+
+	createdBefore := time.Now().Add(-options.GracePeriod)
+	for _, object := range objects {
+		if referenced[object.Name] || object.Created.After(createdBefore) {
+			continue
+		}
+
+		report.Orphans = append(report.Orphans, object.Name)
+		if options.DeleteOrphans {
+			if err := document.DeleteObjectFromBucket(object.Name, hc.Bucket); err != nil {
+				log.Errorf("error deleting orphaned upload: %s", err)
+				continue
+			}
+			report.DeletedOrphans++
+		}
+	}
+
+	return report, nil
+}
+
+// RunBlobReconciliationJob reconciles the bucket with the database right away and then
+// periodically, until ctx is cancelled.
+func (hc *HandlerContext) RunBlobReconciliationJob(ctx context.Context, options BlobReconciliationOptions) {
+	ticker := time.NewTicker(blobReconciliationInterval)
+	defer ticker.Stop()
+
+	for {
+		report, err := hc.ReconcileBlobs(options)
+		if err != nil {
+			log.Errorf("error reconciling blobs: %s", err)
+		}
+		if !options.DeleteOrphans {
+			for _, orphan := range report.Orphans {
+				log.Warnf("orphaned upload: %s", orphan)
+			}
+		}
+		log.Infof(
+			"blob reconciliation: %d orphaned uploads, %d deleted, %d pending deletions retried, %d failed again",
+			len(report.Orphans),
+			report.DeletedOrphans,
+			report.RetriedDeletions,
+			report.FailedDeletions,
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"cloud-solutions-api/models"
+	"context"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+// chatDocumentParams parses the chat and the document a request is about.
+func chatDocumentParams(c echo.Context) (int32, int32, error) {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return 0, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return 0, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+	return int32(chatID), int32(documentID), nil
+}
+
+// GetChatDocuments lists the documents attached to a chat.
+func (hc *HandlerContext) GetChatDocuments(c echo.Context) error {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	offset, limit := getOffsetLimit(c)
+
+	documents, err := hc.Queryer.GetChatDocuments(context.Background(), models.GetChatDocumentsParams{
+		ChatID:     int32(chatID),
+		PageLimit:  int32(limit),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, documents)
+}
+
+// AttachDocumentToChat attaches a document of the user to a chat. The assistant looks into the
+// attached documents first to answer the chat.
+func (hc *HandlerContext) AttachDocumentToChat(c echo.Context) error {
+	chatID, documentID, err := chatDocumentParams(c)
+	if err != nil {
+		return err
+	}
+
+	err = hc.Queryer.AttachDocumentToChat(context.Background(), models.AttachDocumentToChatParams{
+		ChatID:     chatID,
+		DocumentID: documentID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{})
+}
+
+func (hc *HandlerContext) DetachDocumentFromChat(c echo.Context) error {
+	chatID, documentID, err := chatDocumentParams(c)
+	if err != nil {
+		return err
+	}
+
+	err = hc.Queryer.DetachDocumentFromChat(context.Background(), models.DetachDocumentFromChatParams{
+		ChatID:     chatID,
+		DocumentID: documentID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{})
+}
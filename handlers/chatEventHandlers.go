@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"cloud-solutions-api/chat"
+	"cloud-solutions-api/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chatEventHeartbeatInterval is how often an idle event stream sends a comment, so proxies do not
+// close it.
+const chatEventHeartbeatInterval = 15 * time.Second
+
+// publishChatEvent sends an event to the clients following a chat. Within a transaction the event
+// is only sent once the transaction commits.
+func publishChatEvent(queries *models.Queries, event chat.Event) error {
+	payload, err := chat.EventPayload(event)
+	if err != nil {
+		return err
+	}
+	return queries.NotifyChatEvent(context.Background(), payload)
+}
+
+// streamRestricted authenticates like echojwt.JWT, but also accepts the token in the token query
+// parameter, as browsers cannot set headers on event streams and WebSockets.
+func streamRestricted(hc *HandlerContext) echo.MiddlewareFunc {
+	return echojwt.WithConfig(echojwt.Config{
+		SigningKey:  hc.Secret,
+		TokenLookup: "header:Authorization:Bearer ,query:token",
+	})
+}
+
+// RequestLogger logs requests like middleware.Logger, but leaves the query out of the logged URI
+// of requests authenticated with the token query parameter, so tokens do not end up in the logs.
+func RequestLogger() echo.MiddlewareFunc {
+	logURI := middleware.Logger()
+	logPath := middleware.LoggerWithConfig(middleware.LoggerConfig{
+		Format: strings.Replace(middleware.DefaultLoggerConfig.Format, `"uri":"${uri}"`, `"path":"${path}"`, 1),
+	})
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withURI, withPath := logURI(next), logPath(next)
+		return func(c echo.Context) error {
+			if c.QueryParams().Has("token") {
+				return withPath(c)
+			}
+			return withURI(c)
+		}
+	}
+}
+
+// completeChatEvent fills in the message of a message-appended event that was too large to be sent
+// along with it. Subscribers are told to resync when the data of any other event was too large.
+func (hc *HandlerContext) completeChatEvent(event chat.Event) (chat.Event, error) {
+	if event.Data != nil {
+		return event, nil
+	}
+	if event.Type != chat.MessageAppended {
+		return chat.Event{Type: chat.Resync, ChatID: event.ChatID}, nil
+	}
+
+	messageID, err := uuid.Parse(event.MessageID)
+	if err != nil {
+		return event, err
+	}
+	chatMessage, err := hc.Queryer.GetChatMessage(context.Background(), models.GetChatMessageParams{
+		ID:     messageID,
+		ChatID: event.ChatID,
+	})
+	if err != nil {
+		return event, fmt.Errorf("loading message %s of chat %d: %w", event.MessageID, event.ChatID, err)
+	}
+	messages, err := completeMessages(hc.Queryer, []models.ChatMessage{chatMessage})
+	if err != nil {
+		return event, err
+	}
+	event.Data, err = json.Marshal(messages[0])
+	return event, err
+}
+
+func writeServerSentEvent(c echo.Context, event chat.Event) error {
+	data := event.Data
+	if data == nil {
+		data = json.RawMessage("{}")
+	}
+	if _, err := fmt.Fprintf(c.Response(), "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	c.Response().Flush()
+	return nil
+}
+
+// StreamChatEvents follows a chat with Server-Sent Events: messages appended to it, pieces of the
+// answer of the assistant as it is written and changes of its read state. The current read state
+// is sent first. The stream ends when the client goes away or falls too far behind, in which
+// case it should reconnect and load the chat again.
+func (hc *HandlerContext) StreamChatEvents(c echo.Context) error {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	// Subscribing before reading the read state makes sure no change in between is missed.
+	events, unsubscribe := hc.ChatEvents.Subscribe(int32(chatID))
+	defer unsubscribe()
+
+	unread, err := hc.Queryer.IsUnread(context.Background(), int32(chatID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+	readState, err := chat.NewReadStateEvent(int32(chatID), unread.Bool)
+	if err != nil {
+		return err
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, "text/event-stream")
+	header.Set(echo.HeaderCacheControl, "no-cache")
+	header.Set(echo.HeaderConnection, "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Response().WriteHeader(http.StatusOK)
+
+	if err := writeServerSentEvent(c, readState); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(chatEventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Response(), ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			c.Response().Flush()
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			event, err := hc.completeChatEvent(event)
+			if err != nil {
+				c.Logger().Errorf("error loading chat event: %s", err)
+				event = chat.Event{Type: chat.Resync, ChatID: event.ChatID}
+			}
+			if err := writeServerSentEvent(c, event); err != nil {
+				return nil
+			}
+		}
+	}
+}
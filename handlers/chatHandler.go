@@ -6,14 +6,111 @@ import (
 	"cloud-solutions-api/models"
 	"cloud-solutions-api/pubSubPublisher"
 	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
-	"github.com/sqlc-dev/pqtype"
+	"math"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
+const (
+	// defaultMessagePageSize is how many messages are returned when no limit is given.
+	defaultMessagePageSize = 50
+	// maxMessagePageSize is the most messages returned at once.
+	maxMessagePageSize = 200
+	// maxChatTitleLength is the longest title a user can give a chat.
+	maxChatTitleLength = 200
+	// maxPassages is how many pages of the attached documents are sent along with a question.
+	maxPassages = 5
+	// maxPassageLength is the number of characters of a page sent along with a question.
+	maxPassageLength = 2000
+)
+
+// checkChatCollection validates the collection a chat is scoped to.
+func (hc *HandlerContext) checkChatCollection(c echo.Context, collectionID sql.NullInt32) error {
+	if !collectionID.Valid {
+		return nil
+	}
+	owned, err := hc.accountOwnsCollection(c, collectionID.Int32)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+	return nil
+}
+
+// completeMessages returns stored messages along with the IDs of the other branches at each of
+// them and the passages of documents they cite.
+func completeMessages(queries *models.Queries, chatMessages []models.ChatMessage) ([]models.Message, error) {
+	messageIDs := make([]uuid.UUID, len(chatMessages))
+	for i, chatMessage := range chatMessages {
+		messageIDs[i] = chatMessage.ID
+	}
+
+	siblings, err := queries.ListChatMessageSiblings(context.Background(), messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	citations, err := queries.ListChatMessageCitations(context.Background(), messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	return models.WithCitations(models.WithSiblings(chatMessages, siblings), citations), nil
+}
+
+// chatWithMessages loads the active branch of a chat, to return it the way clients expect it.
+func chatWithMessages(queries *models.Queries, retrievedChat models.Chat) (models.ChatWithMessages, error) {
+	chatMessages, err := queries.ListActiveChatMessages(context.Background(), retrievedChat.ID)
+	if err != nil {
+		return models.ChatWithMessages{}, err
+	}
+	messages, err := completeMessages(queries, chatMessages)
+	if err != nil {
+		return models.ChatWithMessages{}, err
+	}
+	return models.ChatWithMessages{Chat: retrievedChat, Messages: messages}, nil
+}
+
+// chatsWithMessages loads the active branches of several chats with a single query.
+func chatsWithMessages(queries *models.Queries, chats []models.Chat) ([]models.ChatWithMessages, error) {
+	chatIDs := make([]int32, len(chats))
+	for i, retrievedChat := range chats {
+		chatIDs[i] = retrievedChat.ID
+	}
+
+	chatMessages, err := queries.ListActiveMessagesOfChats(context.Background(), chatIDs)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := completeMessages(queries, chatMessages)
+	if err != nil {
+		return nil, err
+	}
+	messagesByChat := map[int32][]models.Message{}
+	for i, chatMessage := range chatMessages {
+		messagesByChat[chatMessage.ChatID] = append(messagesByChat[chatMessage.ChatID], messages[i])
+	}
+
+	result := make([]models.ChatWithMessages, len(chats))
+	for i, retrievedChat := range chats {
+		chatMessages := messagesByChat[retrievedChat.ID]
+		if chatMessages == nil {
+			chatMessages = []models.Message{}
+		}
+		result[i] = models.ChatWithMessages{Chat: retrievedChat, Messages: chatMessages}
+	}
+	return result, nil
+}
+
 func CheckChatOwnership(hc *HandlerContext, c echo.Context, chatID int) bool {
 	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
 
@@ -51,23 +148,127 @@ func (hc *HandlerContext) ChatOwnershipMiddleware(next echo.HandlerFunc) echo.Ha
 	}
 }
 
+// CreateEmptyChat creates a chat, optionally scoped to the documents of a collection.
 func (hc *HandlerContext) CreateEmptyChat(c echo.Context) error {
 	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
 	}
 
-	newChat, err := hc.Queryer.CreateChat(context.Background(),
-		models.CreateChatParams{
-			AccountID: account.ID,
-			Messages: pqtype.NullRawMessage{
-				RawMessage: []byte("[]"),
-				Valid:      true,
+	var chatParams = struct {
+		CollectionID optionalID `json:"collectionId"`
+	}{}
+	if err := c.Bind(&chatParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	if err := hc.checkChatCollection(c, chatParams.CollectionID.Value); err != nil {
+		return err
+	}
+
+	var newChat models.Chat
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		err := hc.addAccountUsage(queries, models.AddAccountUsageParams{AccountID: account.ID, ChatCount: 1})
+		if err != nil {
+			return err
+		}
+
+		newChat, err = queries.CreateChat(context.Background(),
+			models.CreateChatParams{
+				AccountID:    account.ID,
+				CollectionID: chatParams.CollectionID.Value,
 			},
-		},
-	)
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, models.ChatWithMessages{Chat: newChat, Messages: []models.Message{}})
+}
+
+// ForkChat copies the conversation of a chat into a new chat, from its first message to the given
+// messageId, or to the active message without one. The new chat uses the same documents.
+func (hc *HandlerContext) ForkChat(c echo.Context) error {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	var forkParams = struct {
+		MessageID string `json:"messageId"`
+	}{}
+	if err := c.Bind(&forkParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	retrievedChat, err := hc.Queryer.GetChatByID(context.Background(), int32(chatID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
 
-	return c.JSON(http.StatusCreated, newChat)
+	messageID := retrievedChat.ActiveMessageID
+	if forkParams.MessageID != "" {
+		id, err := uuid.Parse(forkParams.MessageID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid message ID")
+		}
+		_, err = hc.Queryer.GetChatMessage(context.Background(), models.GetChatMessageParams{ID: id, ChatID: retrievedChat.ID})
+		if errors.Is(err, sql.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Message not found")
+		}
+		if err != nil {
+			return err
+		}
+		messageID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	var forkedChat models.Chat
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		err := hc.addAccountUsage(queries, models.AddAccountUsageParams{AccountID: account.ID, ChatCount: 1})
+		if err != nil {
+			return err
+		}
+
+		forkedChat, err = queries.CreateChat(context.Background(), models.CreateChatParams{
+			AccountID:    account.ID,
+			CollectionID: retrievedChat.CollectionID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = queries.CopyChatDocuments(context.Background(), models.CopyChatDocumentsParams{
+			TargetChatID: forkedChat.ID,
+			ChatID:       retrievedChat.ID,
+		})
+		if err != nil || !messageID.Valid {
+			return err
+		}
+
+		forkedChat, err = queries.ForkChatMessages(context.Background(), models.ForkChatMessagesParams{
+			MessageID:  messageID.UUID,
+			ChatID:     retrievedChat.ID,
+			ForkChatID: forkedChat.ID,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := chatWithMessages(hc.Queryer, forkedChat)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, response)
 }
 
 func (hc *HandlerContext) GetChatByID(c echo.Context) error {
@@ -82,9 +283,225 @@ func (hc *HandlerContext) GetChatByID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
 	}
 
-	return c.JSON(http.StatusOK, retrievedChat)
+	response, err := chatWithMessages(hc.Queryer, retrievedChat)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetChatMessages returns a page of the messages of a chat, in order. Without a cursor the most
+// recent messages are returned; before and after take the ID of a message to return the messages
+// that came before or after it. hasMore tells whether there are more messages in that direction.
+func (hc *HandlerContext) GetChatMessages(c echo.Context) error {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	limit, err := messagePageSize(c.QueryParam("limit"))
+	if err != nil {
+		return err
+	}
+
+	before, after := c.QueryParam("before"), c.QueryParam("after")
+	if before != "" && after != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Only one of before and after can be given")
+	}
+
+	var chatMessages []models.ChatMessage
+	if after != "" {
+		cursor, err := hc.messageCursor(int32(chatID), after)
+		if err != nil {
+			return err
+		}
+		chatMessages, err = hc.Queryer.ListChatMessagesAfter(context.Background(), models.ListChatMessagesAfterParams{
+			ChatID:        int32(chatID),
+			AfterSequence: cursor.Sequence,
+			PageLimit:     sql.NullInt32{Int32: int32(limit + 1), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		beforeSequence := int32(math.MaxInt32)
+		if before != "" {
+			cursor, err := hc.messageCursor(int32(chatID), before)
+			if err != nil {
+				return err
+			}
+			beforeSequence = cursor.Sequence
+		}
+		chatMessages, err = hc.Queryer.ListChatMessagesBefore(context.Background(), models.ListChatMessagesBeforeParams{
+			ChatID:         int32(chatID),
+			BeforeSequence: beforeSequence,
+			PageLimit:      int32(limit + 1),
+		})
+		if err != nil {
+			return err
+		}
+		slices.Reverse(chatMessages)
+	}
+
+	chatMessages, hasMore := trimMessagePage(chatMessages, limit, after != "")
+
+	messages, err := completeMessages(hc.Queryer, chatMessages)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"messages": messages,
+		"hasMore":  hasMore,
+	})
+}
+
+// messagePageSize parses the number of messages asked for in a page, defaultMessagePageSize when
+// none is given and at most maxMessagePageSize.
+func messagePageSize(limitString string) (int, error) {
+	if limitString == "" {
+		return defaultMessagePageSize, nil
+	}
+	limit, err := strconv.Atoi(limitString)
+	if err != nil || limit < 1 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+	}
+	return min(limit, maxMessagePageSize), nil
+}
+
+// trimMessagePage cuts a page of messages, in order, down to limit messages and reports whether
+// there are more. One more message than asked for is loaded to know it: it is the last one when
+// paging forward and the first one otherwise.
+func trimMessagePage(chatMessages []models.ChatMessage, limit int, forward bool) ([]models.ChatMessage, bool) {
+	if len(chatMessages) <= limit {
+		return chatMessages, false
+	}
+	if forward {
+		return chatMessages[:limit], true
+	}
+	return chatMessages[len(chatMessages)-limit:], true
+}
+
+// messageCursor loads the message of a chat a page of messages starts from.
+func (hc *HandlerContext) messageCursor(chatID int32, messageID string) (models.ChatMessage, error) {
+	id, err := uuid.Parse(messageID)
+	if err != nil {
+		return models.ChatMessage{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid message ID")
+	}
+	chatMessage, err := hc.Queryer.GetChatMessage(context.Background(), models.GetChatMessageParams{ID: id, ChatID: chatID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return chatMessage, echo.NewHTTPError(http.StatusBadRequest, "Invalid message ID")
+	}
+	return chatMessage, err
+}
+
+// UpdateChat changes the details of a chat: its title, whether it is pinned or archived, and the
+// collection it is scoped to. Only the fields present are changed; a null collectionId makes the
+// chat use every document of the account again.
+func (hc *HandlerContext) UpdateChat(c echo.Context) error {
+	chatIDString := c.Param("chatID")
+	chatID, err := strconv.Atoi(chatIDString)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	var updateParams = struct {
+		CollectionID optionalID `json:"collectionId"`
+		Title        *string    `json:"title"`
+		Pinned       *bool      `json:"pinned"`
+		Archived     *bool      `json:"archived"`
+	}{}
+	if err := c.Bind(&updateParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	detailsParams := models.UpdateChatDetailsParams{
+		Pinned:   nullBool(updateParams.Pinned),
+		Archived: nullBool(updateParams.Archived),
+	}
+	if updateParams.Title != nil {
+		title, err := chatTitle(*updateParams.Title)
+		if err != nil {
+			return err
+		}
+		detailsParams.Title = sql.NullString{String: title, Valid: true}
+	}
+	if updateParams.CollectionID.Set {
+		if err := hc.checkChatCollection(c, updateParams.CollectionID.Value); err != nil {
+			return err
+		}
+	}
+
+	var retrievedChat models.Chat
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		var err error
+		retrievedChat, err = queries.GetChatForUpdate(context.Background(), int32(chatID))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+		}
+
+		if updateParams.CollectionID.Set {
+			retrievedChat, err = queries.UpdateChatCollection(context.Background(), models.UpdateChatCollectionParams{
+				CollectionID: updateParams.CollectionID.Value,
+				ID:           retrievedChat.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if !detailsParams.Title.Valid && !detailsParams.Pinned.Valid && !detailsParams.Archived.Valid {
+			return nil
+		}
+		detailsParams.ID = retrievedChat.ID
+		retrievedChat, err = queries.UpdateChatDetails(context.Background(), detailsParams)
+		if errors.Is(err, sql.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+		}
+		if err != nil {
+			return err
+		}
+
+		event, err := chat.NewChatUpdatedEvent(retrievedChat)
+		if err != nil {
+			return err
+		}
+		return publishChatEvent(queries, event)
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := chatWithMessages(hc.Queryer, retrievedChat)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
 }
 
+// chatTitle validates a title given to a chat.
+func chatTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Title cannot be empty")
+	}
+	if utf8.RuneCountInString(title) > maxChatTitleLength {
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Title cannot be longer than %d characters", maxChatTitleLength))
+	}
+	return title, nil
+}
+
+// nullBool turns an optional boolean of a request into a query parameter.
+func nullBool(value *bool) sql.NullBool {
+	if value == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: *value, Valid: true}
+}
+
+// DeleteChatByID moves a chat to the trash.
 func (hc *HandlerContext) DeleteChatByID(c echo.Context) error {
 	chatIDString := c.Param("chatID")
 	chatID, err := strconv.Atoi(chatIDString)
@@ -92,7 +509,7 @@ func (hc *HandlerContext) DeleteChatByID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
 	}
 
-	err = hc.Queryer.DeleteChat(context.Background(), int32(chatID))
+	err = hc.Queryer.TrashChat(context.Background(), int32(chatID))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
 	}
@@ -112,41 +529,208 @@ func (hc *HandlerContext) CreateChatMessage(c echo.Context) error {
 	if err = c.Bind(&newMessageParameters); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
 	}
+	// Answers of the assistant are posted on the internal routes, users only send their own messages.
+	newMessageParameters.Sender = models.User
 
-	retrievedChat, err := hc.Queryer.GetChatByID(context.Background(), int32(chatID))
+	retrievedChat, _, err := hc.appendChatMessage(int32(chatID), newMessageParameters)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, retrievedChat)
+}
+
+// appendChatMessage adds a message after the active message of a chat and sends the chat to the
+// assistant to be answered. It returns the updated chat and the message with its assigned ID.
+func (hc *HandlerContext) appendChatMessage(
+	chatID int32,
+	newMessageParameters chat.NewMessageParameters,
+) (models.ChatWithMessages, models.Message, error) {
+	var retrievedChat models.Chat
+	var newMessage models.Message
+	err := hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		var err error
+		retrievedChat, err = queries.GetChatForUpdate(context.Background(), chatID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+		}
+		if err := hc.addMessageUsage(queries, retrievedChat.AccountID); err != nil {
+			return err
+		}
 
+		chatMessage, err := addChatMessage(queries, &retrievedChat, retrievedChat.ActiveMessageID, newMessageParameters)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+		}
+		newMessage = chatMessage.ToMessage()
+
+		event, err := chat.NewMessageAppendedEvent(retrievedChat.ID, newMessage)
+		if err != nil {
+			return err
+		}
+		if err := publishChatEvent(queries, event); err != nil {
+			return err
+		}
+
+		if newMessageParameters.Sender == models.User {
+			return setDerivedChatTitle(queries, &retrievedChat, newMessageParameters.Text)
+		}
+		return nil
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+		return models.ChatWithMessages{}, newMessage, err
 	}
 
-	newMessage := chat.NewMessageForChat(
-		retrievedChat,
-		newMessageParameters,
-	)
-	newMessageJSON, err := json.Marshal(newMessage)
+	updatedChat, err := hc.activeChatWithMessages(retrievedChat, true)
+	return updatedChat, newMessage, err
+}
 
+// addChatMessage adds a message to a chat under the given parent and makes it the active
+// message, updating retrievedChat accordingly.
+func addChatMessage(
+	queries *models.Queries,
+	retrievedChat *models.Chat,
+	parentID uuid.NullUUID,
+	newMessageParameters chat.NewMessageParameters,
+) (models.ChatMessage, error) {
+	chatMessage, err := queries.AppendChatMessage(context.Background(), models.AppendChatMessageParams{
+		ChatID:   retrievedChat.ID,
+		ParentID: parentID,
+		Sender:   string(newMessageParameters.Sender),
+		Text:     newMessageParameters.Text,
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+		return chatMessage, err
 	}
 
-	retrievedChat, err = hc.Queryer.AddMessageToChat(context.Background(), models.AddMessageToChatParams{
-		Chatid:     retrievedChat.ID,
-		Newmessage: newMessageJSON,
+	activeMessageID := uuid.NullUUID{UUID: chatMessage.ID, Valid: true}
+	err = queries.SetChatActiveMessage(context.Background(), models.SetChatActiveMessageParams{
+		ActiveMessageID: activeMessageID,
+		ID:              retrievedChat.ID,
 	})
+	if err != nil {
+		return chatMessage, err
+	}
+	retrievedChat.ActiveMessageID = activeMessageID
+	retrievedChat.LastMessageSequence = chatMessage.Sequence
+	return chatMessage, nil
+}
+
+// setDerivedChatTitle titles a chat that has no title yet after a message of the user.
+func setDerivedChatTitle(queries *models.Queries, retrievedChat *models.Chat, text string) error {
+	title := chat.TitleFromMessage(text)
+	if retrievedChat.Title != "" || title == "" {
+		return nil
+	}
+
+	updated, err := queries.SetChatTitleIfEmpty(context.Background(), models.SetChatTitleIfEmptyParams{
+		Title: title,
+		ID:    retrievedChat.ID,
+	})
+	if err != nil || updated == 0 {
+		return err
+	}
+	retrievedChat.Title = title
 
+	event, err := chat.NewChatUpdatedEvent(*retrievedChat)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+		return err
+	}
+	return publishChatEvent(queries, event)
+}
+
+// chatPassages retrieves the pages of the documents attached to a chat that best match the last
+// question of the user.
+func (hc *HandlerContext) chatPassages(
+	retrievedChat models.Chat,
+	attachedDocumentIDs []int32,
+	messages []models.Message,
+) ([]pubSubPublisher.Passage, error) {
+	if len(attachedDocumentIDs) == 0 {
+		return nil, nil
+	}
+
+	var question string
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Sender == models.User {
+			question = messages[i].Text
+			break
+		}
+	}
+	if strings.TrimSpace(question) == "" {
+		return nil, nil
 	}
 
+	pages, err := hc.Queryer.SearchChatDocumentPages(context.Background(), models.SearchChatDocumentPagesParams{
+		Query:         question,
+		MaxTextLength: maxPassageLength,
+		ChatID:        retrievedChat.ID,
+		PageLimit:     maxPassages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	passages := make([]pubSubPublisher.Passage, 0, len(pages))
+	for _, page := range pages {
+		passages = append(passages, pubSubPublisher.Passage{
+			DocumentId: page.DocumentID,
+			PageNumber: page.PageNumber,
+			Text:       page.Text,
+			Score:      page.Score,
+		})
+	}
+	return passages, nil
+}
+
+// requestAnswer sends the messages of the active branch of a chat to the assistant to be answered.
+func (hc *HandlerContext) requestAnswer(retrievedChat models.Chat, messages []models.Message) error {
+	var documentIDs, sharedDocumentIDs []int32
+	var err error
+	if retrievedChat.CollectionID.Valid {
+		documentIDs, err = hc.Queryer.GetCollectionDocumentIDs(context.Background(), models.GetCollectionDocumentIDsParams{
+			CollectionID: retrievedChat.CollectionID.Int32,
+			Recursive:    true,
+		})
+	} else {
+		sharedDocumentIDs, err = hc.Queryer.GetSharedDocumentIDs(context.Background(), retrievedChat.AccountID)
+	}
+	if err != nil {
+		return err
+	}
+
+	attachedDocumentIDs, err := hc.Queryer.GetChatDocumentIDs(context.Background(), retrievedChat.ID)
+	if err != nil {
+		return err
+	}
+	passages, err := hc.chatPassages(retrievedChat, attachedDocumentIDs, messages)
+	if err != nil {
+		return err
+	}
+
+	var parentMessageID string
+	if len(messages) > 0 {
+		parentMessageID = messages[len(messages)-1].ID
+	}
 	err = hc.PuSubPublisher.PublishAiAssistantMessage(pubSubPublisher.AIAssistantMessage{
-		Messages: retrievedChat.GetMessages(),
-		ChatId:   retrievedChat.ID,
+		Messages:            messages,
+		ChatId:              retrievedChat.ID,
+		DocumentIds:         documentIDs,
+		SharedDocumentIds:   sharedDocumentIDs,
+		ParentMessageId:     parentMessageID,
+		AttachedDocumentIds: attachedDocumentIDs,
+		Passages:            passages,
 	})
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, retrievedChat)
+	// Clients show the assistant as working on an answer until its reply is posted.
+	event, err := chat.NewProcessingEvent(retrievedChat.ID, true)
+	if err != nil {
+		return err
+	}
+	return publishChatEvent(hc.Queryer, event)
 }
 
 func (hc *HandlerContext) GetChatIsUnread(c echo.Context) error {
@@ -171,9 +755,19 @@ func (hc *HandlerContext) ChatMarkAsRead(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
 	}
 
-	err = hc.Queryer.MarkAsReadByID(context.Background(), int32(chatID))
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		if err := queries.MarkAsReadByID(context.Background(), int32(chatID)); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+		}
+
+		event, err := chat.NewReadStateEvent(int32(chatID), false)
+		if err != nil {
+			return err
+		}
+		return publishChatEvent(queries, event)
+	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+		return err
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{})
@@ -184,8 +778,27 @@ func RegisterChatRoutes(e *echo.Echo, hc *HandlerContext) {
 	chatGroup := e.Group("/chats")
 	chatGroup.GET("/:chatID", hc.GetChatByID, restricted, hc.ChatOwnershipMiddleware)
 	chatGroup.GET("/:chatID/unread", hc.GetChatIsUnread, restricted, hc.ChatOwnershipMiddleware)
+	chatGroup.GET("/:chatID/events", hc.StreamChatEvents, streamRestricted(hc), hc.ChatOwnershipMiddleware)
+	chatGroup.GET("/:chatID/socket", hc.ChatSocket, streamRestricted(hc), hc.ChatOwnershipMiddleware)
 	chatGroup.POST("", hc.CreateEmptyChat, restricted)
+	chatGroup.POST("/batch", hc.BatchChats, restricted)
+	chatGroup.PATCH("/:chatID", hc.UpdateChat, restricted, hc.ChatOwnershipMiddleware)
 	chatGroup.DELETE("/:chatID", hc.DeleteChatByID, restricted, hc.ChatOwnershipMiddleware)
+	chatGroup.GET("/:chatID/messages", hc.GetChatMessages, restricted, hc.ChatOwnershipMiddleware)
 	chatGroup.POST("/:chatID/messages", hc.CreateChatMessage, restricted, hc.ChatOwnershipMiddleware)
+	chatGroup.PATCH("/:chatID/messages/:messageID", hc.UpdateChatMessage, restricted, hc.ChatOwnershipMiddleware)
+	chatGroup.DELETE("/:chatID/messages/:messageID", hc.DeleteChatMessage, restricted, hc.ChatOwnershipMiddleware)
+	chatGroup.POST("/:chatID/messages/:messageID/regenerate", hc.RegenerateChatMessage, restricted, hc.ChatOwnershipMiddleware)
+	chatGroup.POST("/:chatID/messages/:messageID/activate", hc.ActivateChatMessage, restricted, hc.ChatOwnershipMiddleware)
+	chatGroup.POST("/:chatID/fork", hc.ForkChat, restricted, hc.ChatOwnershipMiddleware)
+	chatGroup.GET("/:chatID/documents", hc.GetChatDocuments, restricted, hc.ChatOwnershipMiddleware)
+	chatGroup.PUT(
+		"/:chatID/documents/:documentID",
+		hc.AttachDocumentToChat,
+		restricted,
+		hc.ChatOwnershipMiddleware,
+		hc.UserOwnsDocumentMiddleware,
+	)
+	chatGroup.DELETE("/:chatID/documents/:documentID", hc.DetachDocumentFromChat, restricted, hc.ChatOwnershipMiddleware)
 	chatGroup.POST("/:chatID/mark-as-read", hc.ChatMarkAsRead, restricted, hc.ChatOwnershipMiddleware)
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"cloud-solutions-api/models"
+	"slices"
+	"testing"
+)
+
+func TestMessagePageSize(t *testing.T) {
+	tests := []struct {
+		limit   string
+		want    int
+		wantErr bool
+	}{
+		{limit: "", want: defaultMessagePageSize},
+		{limit: "1", want: 1},
+		{limit: "20", want: 20},
+		{limit: "100000", want: maxMessagePageSize},
+		{limit: "0", wantErr: true},
+		{limit: "-3", wantErr: true},
+		{limit: "ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := messagePageSize(tt.limit)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("messagePageSize(%q) = %d, %v, want %d, error %v", tt.limit, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTrimMessagePage(t *testing.T) {
+	page := func(sequences ...int32) []models.ChatMessage {
+		chatMessages := make([]models.ChatMessage, len(sequences))
+		for i, sequence := range sequences {
+			chatMessages[i].Sequence = sequence
+		}
+		return chatMessages
+	}
+	sequences := func(chatMessages []models.ChatMessage) []int32 {
+		var result []int32
+		for _, chatMessage := range chatMessages {
+			result = append(result, chatMessage.Sequence)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name        string
+		page        []models.ChatMessage
+		limit       int
+		forward     bool
+		want        []int32
+		wantHasMore bool
+	}{
+		{name: "empty", page: page(), limit: 2, want: nil},
+		{name: "short page backward", page: page(4, 5), limit: 3, want: []int32{4, 5}},
+		{name: "full page backward", page: page(3, 4, 5), limit: 3, want: []int32{3, 4, 5}},
+		{name: "more before", page: page(2, 3, 4, 5), limit: 3, want: []int32{3, 4, 5}, wantHasMore: true},
+		{name: "full page forward", page: page(6, 7), limit: 2, forward: true, want: []int32{6, 7}},
+		{name: "more after", page: page(6, 7, 8), limit: 2, forward: true, want: []int32{6, 7}, wantHasMore: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hasMore := trimMessagePage(tt.page, tt.limit, tt.forward)
+			if !slices.Equal(sequences(got), tt.want) || hasMore != tt.wantHasMore {
+				t.Errorf("trimMessagePage() = %v, %v, want %v, %v", sequences(got), hasMore, tt.want, tt.wantHasMore)
+			}
+		})
+	}
+}
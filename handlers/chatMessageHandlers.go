@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"cloud-solutions-api/chat"
+	"cloud-solutions-api/models"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// chatMessageParams parses the chat and the message a request is about.
+func chatMessageParams(c echo.Context) (int32, uuid.UUID, error) {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return 0, uuid.UUID{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+	messageID, err := uuid.Parse(c.Param("messageID"))
+	if err != nil {
+		return 0, uuid.UUID{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid message ID")
+	}
+	return int32(chatID), messageID, nil
+}
+
+// getChatMessage loads a message of a chat, sent by the given sender.
+func getChatMessage(queries *models.Queries, chatID int32, messageID uuid.UUID, sender models.Sender) (models.ChatMessage, error) {
+	chatMessage, err := queries.GetChatMessage(context.Background(), models.GetChatMessageParams{ID: messageID, ChatID: chatID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return chatMessage, echo.NewHTTPError(http.StatusNotFound, "Message not found")
+	}
+	if err != nil {
+		return chatMessage, err
+	}
+	if models.Sender(chatMessage.Sender) != sender {
+		return chatMessage, echo.NewHTTPError(http.StatusBadRequest, "Message cannot be changed")
+	}
+	return chatMessage, nil
+}
+
+// setActiveMessage makes a message the active one of a chat and tells the clients following the
+// chat to load its conversation again.
+func setActiveMessage(queries *models.Queries, retrievedChat *models.Chat, messageID uuid.NullUUID) error {
+	err := queries.SetChatActiveMessage(context.Background(), models.SetChatActiveMessageParams{
+		ActiveMessageID: messageID,
+		ID:              retrievedChat.ID,
+	})
+	if err != nil {
+		return err
+	}
+	retrievedChat.ActiveMessageID = messageID
+
+	var activeMessageID string
+	if messageID.Valid {
+		activeMessageID = messageID.UUID.String()
+	}
+	event, err := chat.NewBranchChangedEvent(retrievedChat.ID, activeMessageID)
+	if err != nil {
+		return err
+	}
+	return publishChatEvent(queries, event)
+}
+
+// latestLeaf returns the latest message under the given one, or in the whole chat without one.
+func latestLeaf(queries *models.Queries, chatID int32, messageID uuid.NullUUID) (uuid.NullUUID, error) {
+	leaf, err := queries.GetLatestChatLeaf(context.Background(), models.GetLatestChatLeafParams{
+		ChatID:    chatID,
+		MessageID: messageID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.NullUUID{}, nil
+	}
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: leaf.ID, Valid: true}, nil
+}
+
+// activeChatWithMessages loads the active branch of a chat after it changed, sends it to the
+// assistant to be answered when asked to, and returns it the way clients expect it.
+func (hc *HandlerContext) activeChatWithMessages(retrievedChat models.Chat, answer bool) (models.ChatWithMessages, error) {
+	chatMessages, err := hc.Queryer.ListActiveChatMessages(context.Background(), retrievedChat.ID)
+	if err != nil {
+		return models.ChatWithMessages{}, err
+	}
+	if answer {
+		if err := hc.requestAnswer(retrievedChat, models.ToMessages(chatMessages)); err != nil {
+			return models.ChatWithMessages{}, err
+		}
+	}
+
+	messages, err := completeMessages(hc.Queryer, chatMessages)
+	if err != nil {
+		return models.ChatWithMessages{}, err
+	}
+	return models.ChatWithMessages{Chat: retrievedChat, Messages: messages}, nil
+}
+
+// UpdateChatMessage edits a message of the user. The edited message is added next to the original
+// one as a new branch of the conversation, which becomes the active one and is sent to the
+// assistant to be answered.
+func (hc *HandlerContext) UpdateChatMessage(c echo.Context) error {
+	chatID, messageID, err := chatMessageParams(c)
+	if err != nil {
+		return err
+	}
+
+	var updateParams = struct {
+		Text string `json:"text"`
+	}{}
+	if err := c.Bind(&updateParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	if strings.TrimSpace(updateParams.Text) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Text cannot be empty")
+	}
+
+	var retrievedChat models.Chat
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		retrievedChat, err = queries.GetChatForUpdate(context.Background(), chatID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+		}
+		original, err := getChatMessage(queries, chatID, messageID, models.User)
+		if err != nil {
+			return err
+		}
+		if err := hc.addMessageUsage(queries, retrievedChat.AccountID); err != nil {
+			return err
+		}
+
+		chatMessage, err := addChatMessage(queries, &retrievedChat, original.ParentID, chat.NewMessageParameters{
+			Sender: models.User,
+			Text:   updateParams.Text,
+		})
+		if err != nil {
+			return err
+		}
+
+		messages, err := completeMessages(queries, []models.ChatMessage{chatMessage})
+		if err != nil {
+			return err
+		}
+		event, err := chat.NewMessageAppendedEvent(chatID, messages[0])
+		if err != nil {
+			return err
+		}
+		if err := publishChatEvent(queries, event); err != nil {
+			return err
+		}
+		event, err = chat.NewBranchChangedEvent(chatID, chatMessage.ID.String())
+		if err != nil {
+			return err
+		}
+		return publishChatEvent(queries, event)
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := hc.activeChatWithMessages(retrievedChat, true)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// DeleteChatMessage deletes a message of the user along with the messages that followed it. When
+// the active message was among them, the latest remaining message next to the deleted one becomes
+// the active one.
+func (hc *HandlerContext) DeleteChatMessage(c echo.Context) error {
+	chatID, messageID, err := chatMessageParams(c)
+	if err != nil {
+		return err
+	}
+
+	var retrievedChat models.Chat
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		retrievedChat, err = queries.GetChatForUpdate(context.Background(), chatID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+		}
+		chatMessage, err := getChatMessage(queries, chatID, messageID, models.User)
+		if err != nil {
+			return err
+		}
+
+		deletedIDs, err := queries.DeleteChatMessageTree(context.Background(), models.DeleteChatMessageTreeParams{
+			ID:     messageID,
+			ChatID: chatID,
+		})
+		if err != nil {
+			return err
+		}
+		messageIDs := make([]string, len(deletedIDs))
+		activeDeleted := false
+		for i, deletedID := range deletedIDs {
+			messageIDs[i] = deletedID.String()
+			activeDeleted = activeDeleted || uuid.NullUUID{UUID: deletedID, Valid: true} == retrievedChat.ActiveMessageID
+		}
+		event, err := chat.NewMessagesDeletedEvent(chatID, messageIDs)
+		if err != nil {
+			return err
+		}
+		if err := publishChatEvent(queries, event); err != nil {
+			return err
+		}
+		if !activeDeleted {
+			return nil
+		}
+
+		leaf, err := latestLeaf(queries, chatID, chatMessage.ParentID)
+		if err != nil {
+			return err
+		}
+		return setActiveMessage(queries, &retrievedChat, leaf)
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := hc.activeChatWithMessages(retrievedChat, false)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// RegenerateChatMessage asks the assistant to write an answer again. The message it answered
+// becomes the active one, and the new answer is added next to the previous one as a new branch of
+// the conversation.
+func (hc *HandlerContext) RegenerateChatMessage(c echo.Context) error {
+	chatID, messageID, err := chatMessageParams(c)
+	if err != nil {
+		return err
+	}
+
+	var retrievedChat models.Chat
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		retrievedChat, err = queries.GetChatForUpdate(context.Background(), chatID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+		}
+		regenerated, err := getChatMessage(queries, chatID, messageID, models.Assistant)
+		if err != nil {
+			return err
+		}
+		if err := hc.addMessageUsage(queries, retrievedChat.AccountID); err != nil {
+			return err
+		}
+		return setActiveMessage(queries, &retrievedChat, regenerated.ParentID)
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := hc.activeChatWithMessages(retrievedChat, true)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, response)
+}
+
+// ActivateChatMessage switches a chat to the branch of the conversation going through a message.
+// The latest message of that branch becomes the active one.
+func (hc *HandlerContext) ActivateChatMessage(c echo.Context) error {
+	chatID, messageID, err := chatMessageParams(c)
+	if err != nil {
+		return err
+	}
+
+	var retrievedChat models.Chat
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		retrievedChat, err = queries.GetChatForUpdate(context.Background(), chatID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+		}
+		leaf, err := latestLeaf(queries, chatID, uuid.NullUUID{UUID: messageID, Valid: true})
+		if err != nil {
+			return err
+		}
+		if !leaf.Valid {
+			return echo.NewHTTPError(http.StatusNotFound, "Message not found")
+		}
+		return setActiveMessage(queries, &retrievedChat, leaf)
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := hc.activeChatWithMessages(retrievedChat, false)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"cloud-solutions-api/chat"
+	"cloud-solutions-api/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// chatSocketPingInterval is how often the server pings an idle chat socket.
+	chatSocketPingInterval = 30 * time.Second
+	// chatSocketReadTimeout closes sockets the client stopped answering, including pings.
+	chatSocketReadTimeout = 2 * chatSocketPingInterval
+	// maxQueuedSocketMessages is how many messages sent by a client can wait to be added to the
+	// chat, more are rejected.
+	maxQueuedSocketMessages = 16
+	// maxRememberedSentMessages is how many sent messages a socket remembers to skip duplicates.
+	// Duplicates are messages appended while a resume replays the messages, which are the newest.
+	maxRememberedSentMessages = 1000
+
+	socketFrameMessage = "message"
+	socketFrameAck     = "ack"
+	socketFrameError   = "error"
+	socketFramePing    = "ping"
+	socketFramePong    = "pong"
+)
+
+// chatSocketRequest is a frame sent by the client. ClientID is chosen by the client to match the
+// acknowledgement or the error of a message it sent.
+type chatSocketRequest struct {
+	Type     string `json:"type"`
+	ClientID string `json:"clientId"`
+	Text     string `json:"text"`
+}
+
+// chatSocketFrame is a frame sent by the server, either a chat event or a response to a request.
+type chatSocketFrame struct {
+	Type      string          `json:"type"`
+	ClientID  string          `json:"clientId,omitempty"`
+	MessageID string          `json:"messageId,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+func eventSocketFrame(event chat.Event) chatSocketFrame {
+	return chatSocketFrame{Type: string(event.Type), MessageID: event.MessageID, Data: event.Data}
+}
+
+// socketErrorMessage describes why a request failed, without exposing internal errors.
+func socketErrorMessage(c echo.Context, err error) string {
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		return fmt.Sprint(httpError.Message)
+	}
+	c.Logger().Errorf("error handling chat socket request: %s", err)
+	return "Message could not be sent"
+}
+
+// recentIDs is a set remembering the last IDs added to it, up to a limit.
+type recentIDs struct {
+	limit int
+	ids   map[string]bool
+	order []string
+}
+
+func newRecentIDs(limit int) *recentIDs {
+	return &recentIDs{limit: limit, ids: map[string]bool{}}
+}
+
+// add adds an ID, forgetting the oldest one when the set is full. It reports whether the ID was
+// added, false meaning it was already there.
+func (r *recentIDs) add(id string) bool {
+	if r.ids[id] {
+		return false
+	}
+	if len(r.order) == r.limit {
+		delete(r.ids, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.ids[id] = true
+	r.order = append(r.order, id)
+	return true
+}
+
+// chatSocket is a WebSocket connection following a chat.
+type chatSocket struct {
+	hc     *HandlerContext
+	c      echo.Context
+	conn   *websocket.Conn
+	chatID int32
+	// sentMessages are the messages recently sent, so the events of messages replayed on resume
+	// are not sent twice.
+	sentMessages *recentIDs
+	// queuedMessages are the messages sent by the client waiting to be added to the chat, and
+	// responses the acknowledgements and errors of the messages added.
+	queuedMessages chan chatSocketRequest
+	responses      chan chatSocketFrame
+}
+
+func (s *chatSocket) send(frame chatSocketFrame) error {
+	return websocket.JSON.Send(s.conn, frame)
+}
+
+func (s *chatSocket) sendEvent(event chat.Event) error {
+	if event.Type == chat.MessageAppended && !s.sentMessages.add(event.MessageID) {
+		return nil
+	}
+
+	event, err := s.hc.completeChatEvent(event)
+	if err != nil {
+		s.c.Logger().Errorf("error loading chat event: %s", err)
+		event = chat.Event{Type: chat.Resync, ChatID: s.chatID}
+	}
+	return s.send(eventSocketFrame(event))
+}
+
+// resume sends the messages that came after the last one the client has seen. The client is told
+// to resync when that message is not part of the chat anymore.
+func (s *chatSocket) resume(lastMessageID string) error {
+	messageID, err := uuid.Parse(lastMessageID)
+	if err != nil {
+		return s.send(chatSocketFrame{Type: string(chat.Resync)})
+	}
+	lastMessage, err := s.hc.Queryer.GetChatMessage(context.Background(), models.GetChatMessageParams{
+		ID:     messageID,
+		ChatID: s.chatID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.send(chatSocketFrame{Type: string(chat.Resync)})
+	}
+	if err != nil {
+		return err
+	}
+
+	chatMessages, err := s.hc.Queryer.ListChatMessagesAfter(context.Background(), models.ListChatMessagesAfterParams{
+		ChatID:        s.chatID,
+		AfterSequence: lastMessage.Sequence,
+	})
+	if err != nil {
+		return err
+	}
+	messages, err := completeMessages(s.hc.Queryer, chatMessages)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		event, err := chat.NewMessageAppendedEvent(s.chatID, message)
+		if err != nil {
+			return err
+		}
+		if err := s.sendEvent(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleRequest answers a frame sent by the client.
+func (s *chatSocket) handleRequest(request chatSocketRequest) error {
+	switch request.Type {
+	case socketFramePing:
+		return s.send(chatSocketFrame{Type: socketFramePong})
+	case socketFramePong:
+		return nil
+	case socketFrameMessage:
+		select {
+		case s.queuedMessages <- request:
+			return nil
+		default:
+			return s.send(chatSocketFrame{
+				Type:     socketFrameError,
+				ClientID: request.ClientID,
+				Error:    "Too many messages waiting to be sent",
+			})
+		}
+	default:
+		return s.send(chatSocketFrame{
+			Type:     socketFrameError,
+			ClientID: request.ClientID,
+			Error:    "Unknown frame type",
+		})
+	}
+}
+
+// appendMessages adds the messages sent by the client to the chat one after the other, away from
+// the loop serving the socket, and hands their acknowledgement or error to it until done is
+// closed.
+func (s *chatSocket) appendMessages(done <-chan struct{}) {
+	for {
+		var request chatSocketRequest
+		select {
+		case request = <-s.queuedMessages:
+		case <-done:
+			return
+		}
+
+		response := chatSocketFrame{Type: socketFrameAck, ClientID: request.ClientID}
+		_, message, err := s.hc.appendChatMessage(s.chatID, chat.NewMessageParameters{
+			Sender: models.User,
+			Text:   request.Text,
+		})
+		if err != nil {
+			response = chatSocketFrame{
+				Type:     socketFrameError,
+				ClientID: request.ClientID,
+				Error:    socketErrorMessage(s.c, err),
+			}
+		} else {
+			response.MessageID = message.ID
+		}
+
+		select {
+		case s.responses <- response:
+		case <-done:
+			return
+		}
+	}
+}
+
+// readRequests reads the frames sent by the client until the connection fails or times out, or
+// done is closed.
+func (s *chatSocket) readRequests(requests chan<- chatSocketRequest, done <-chan struct{}) {
+	defer close(requests)
+	for {
+		if err := s.conn.SetReadDeadline(time.Now().Add(chatSocketReadTimeout)); err != nil {
+			return
+		}
+		var request chatSocketRequest
+		if err := websocket.JSON.Receive(s.conn, &request); err != nil {
+			return
+		}
+		select {
+		case requests <- request:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *chatSocket) serve(lastMessageID string) {
+	events, unsubscribe := s.hc.ChatEvents.Subscribe(s.chatID)
+	defer unsubscribe()
+
+	if lastMessageID != "" {
+		if err := s.resume(lastMessageID); err != nil {
+			s.c.Logger().Errorf("error resuming chat socket: %s", err)
+			return
+		}
+	}
+
+	requests := make(chan chatSocketRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go s.readRequests(requests, done)
+	go s.appendMessages(done)
+
+	ping := time.NewTicker(chatSocketPingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case request, ok := <-requests:
+			if !ok {
+				return
+			}
+			err = s.handleRequest(request)
+		case response := <-s.responses:
+			err = s.send(response)
+		case event, ok := <-events:
+			if !ok {
+				// The client fell too far behind, it resumes from the last message it has seen.
+				return
+			}
+			err = s.sendEvent(event)
+		case <-ping.C:
+			err = s.send(chatSocketFrame{Type: socketFramePing})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// originAllowed reports whether a browser on origin may open a socket. Requests without an origin
+// do not come from a browser and are allowed.
+func originAllowed(allowedOrigins []string, origin string) bool {
+	if origin == "" {
+		return true
+	}
+	for _, allowedOrigin := range allowedOrigins {
+		if allowedOrigin == "*" || strings.EqualFold(allowedOrigin, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChatSocket follows a chat over a WebSocket. Besides the events of the event stream, clients can
+// send messages and get them acknowledged with the ID the server assigned to them. Passing the ID
+// of the last message seen as lastMessageId replays the messages that came after it, so clients
+// can reconnect without missing any. Both sides ping each other, and the connection is closed when
+// the client stays silent for too long. Browsers can only connect from the allowed origins.
+func (hc *HandlerContext) ChatSocket(c echo.Context) error {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	server := websocket.Server{
+		Handshake: func(_ *websocket.Config, request *http.Request) error {
+			if !originAllowed(hc.AllowedOrigins, request.Header.Get("Origin")) {
+				return echo.NewHTTPError(http.StatusForbidden, "Origin not allowed")
+			}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			socket := &chatSocket{
+				hc:             hc,
+				c:              c,
+				conn:           conn,
+				chatID:         int32(chatID),
+				sentMessages:   newRecentIDs(maxRememberedSentMessages),
+				queuedMessages: make(chan chatSocketRequest, maxQueuedSocketMessages),
+				responses:      make(chan chatSocketFrame),
+			}
+			socket.serve(c.QueryParam("lastMessageId"))
+		},
+	}
+	server.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
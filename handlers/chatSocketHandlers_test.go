@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRecentIDs(t *testing.T) {
+	ids := newRecentIDs(3)
+	for i := range 3 {
+		if !ids.add(strconv.Itoa(i)) {
+			t.Fatalf("add(%d) = false for a new ID", i)
+		}
+	}
+	if ids.add("1") {
+		t.Error("add(1) = true for an ID already there")
+	}
+
+	// Adding a fourth ID forgets the oldest one.
+	if !ids.add("3") {
+		t.Error("add(3) = false for a new ID")
+	}
+	if len(ids.ids) != 3 || len(ids.order) != 3 {
+		t.Errorf("set holds %d IDs in %d slots, want 3", len(ids.ids), len(ids.order))
+	}
+	if ids.add("2") {
+		t.Error("add(2) = true for an ID still remembered")
+	}
+	if !ids.add("0") {
+		t.Error("add(0) = false for a forgotten ID")
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowedOrigins []string
+		origin         string
+		want           bool
+	}{
+		{name: "no origin", allowedOrigins: []string{"https://app.example.com"}, origin: "", want: true},
+		{name: "listed origin", allowedOrigins: []string{"https://app.example.com"}, origin: "https://app.example.com", want: true},
+		{name: "case of origin", allowedOrigins: []string{"https://app.example.com"}, origin: "https://App.Example.com", want: true},
+		{name: "other origin", allowedOrigins: []string{"https://app.example.com"}, origin: "https://evil.example.com", want: false},
+		{name: "other scheme", allowedOrigins: []string{"https://app.example.com"}, origin: "http://app.example.com", want: false},
+		{name: "any origin", allowedOrigins: []string{"*"}, origin: "https://evil.example.com", want: true},
+		{name: "no allowed origins", allowedOrigins: nil, origin: "https://app.example.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := originAllowed(tt.allowedOrigins, tt.origin); got != tt.want {
+				t.Errorf("originAllowed(%v, %q) = %v, want %v", tt.allowedOrigins, tt.origin, got, tt.want)
+			}
+		})
+	}
+}
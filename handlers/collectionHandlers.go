@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"cloud-solutions-api/authentication"
+	"cloud-solutions-api/models"
+	"context"
+	"database/sql"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// accountOwnsCollection checks that the currently authenticated user owns a collection.
+func (hc *HandlerContext) accountOwnsCollection(c echo.Context, collectionID int32) (bool, error) {
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	return hc.Queryer.AccountOwnsCollection(
+		context.Background(),
+		models.AccountOwnsCollectionParams{AccountID: account.ID, ID: collectionID},
+	)
+}
+
+// UserOwnsCollectionMiddleware checks if the currently authenticated user owns the collection
+// specified in the request.
+func (hc *HandlerContext) UserOwnsCollectionMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		collectionID, err := strconv.Atoi(c.Param("collectionID"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+		}
+
+		owned, err := hc.accountOwnsCollection(c, int32(collectionID))
+		if err != nil {
+			return err
+		}
+
+		if !owned {
+			return echo.NewHTTPError(http.StatusForbidden, "Forbidden: You do not own this collection")
+		}
+
+		return next(c)
+	}
+}
+
+// checkParentCollection validates a parent collection given in a request body.
+func (hc *HandlerContext) checkParentCollection(c echo.Context, parentID sql.NullInt32) error {
+	if !parentID.Valid {
+		return nil
+	}
+	owned, err := hc.accountOwnsCollection(c, parentID.Int32)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid parent collection")
+	}
+	return nil
+}
+
+func (hc *HandlerContext) CreateCollection(c echo.Context) error {
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	var collectionParams = struct {
+		Name     string     `json:"name"`
+		ParentID optionalID `json:"parentId"`
+	}{}
+	if err := c.Bind(&collectionParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	name := strings.TrimSpace(collectionParams.Name)
+	if name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Collection name cannot be empty")
+	}
+
+	if err := hc.checkParentCollection(c, collectionParams.ParentID.Value); err != nil {
+		return err
+	}
+
+	collection, err := hc.Queryer.CreateCollection(
+		context.Background(),
+		models.CreateCollectionParams{
+			Name:      name,
+			AccountID: account.ID,
+			ParentID:  collectionParams.ParentID.Value,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, collection)
+}
+
+// GetAccountCollections lists every collection of the current user. Collections reference their
+// parent, so clients can build the hierarchy from a single request.
+func (hc *HandlerContext) GetAccountCollections(c echo.Context) error {
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	collections, err := hc.Queryer.GetCollectionsByAccountID(context.Background(), account.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, collections)
+}
+
+func (hc *HandlerContext) GetCollectionByID(c echo.Context) error {
+	collectionID, err := strconv.Atoi(c.Param("collectionID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	collection, err := hc.Queryer.GetCollectionByID(context.Background(), int32(collectionID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	return c.JSON(http.StatusOK, collection)
+}
+
+// UpdateCollection renames a collection and moves it under another parent. A null parentId moves it
+// to the top level, while fields missing from the request keep their value.
+func (hc *HandlerContext) UpdateCollection(c echo.Context) error {
+	collectionID, err := strconv.Atoi(c.Param("collectionID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	var updateParams = struct {
+		Name     *string    `json:"name"`
+		ParentID optionalID `json:"parentId"`
+	}{}
+	if err := c.Bind(&updateParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	collection, err := hc.Queryer.GetCollectionByID(context.Background(), int32(collectionID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	name := collection.Name
+	if updateParams.Name != nil {
+		name = strings.TrimSpace(*updateParams.Name)
+		if name == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Collection name cannot be empty")
+		}
+	}
+
+	parentID := collection.ParentID
+	if updateParams.ParentID.Set {
+		parentID = updateParams.ParentID.Value
+		if err := hc.checkParentCollection(c, parentID); err != nil {
+			return err
+		}
+	}
+
+	if parentID.Valid {
+		// Moving a collection below itself would detach the subtree from the hierarchy.
+		inSubtree, err := hc.Queryer.IsCollectionInSubtree(
+			context.Background(),
+			models.IsCollectionInSubtreeParams{CollectionID: collection.ID, CandidateID: parentID.Int32},
+		)
+		if err != nil {
+			return err
+		}
+		if inSubtree {
+			return echo.NewHTTPError(
+				http.StatusBadRequest,
+				"A collection cannot be moved into itself or one of its sub-collections",
+			)
+		}
+	}
+
+	updatedCollection, err := hc.Queryer.UpdateCollection(
+		context.Background(),
+		models.UpdateCollectionParams{
+			Name:     name,
+			ParentID: parentID,
+			ID:       collection.ID,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, updatedCollection)
+}
+
+// DeleteCollection deletes a collection. With mode=cascade its sub-collections are deleted too,
+// otherwise (mode=reparent, the default) its sub-collections and documents move to its parent.
+// Documents themselves are never deleted.
+func (hc *HandlerContext) DeleteCollection(c echo.Context) error {
+	collectionID, err := strconv.Atoi(c.Param("collectionID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	mode := c.QueryParam("mode")
+	if mode == "" {
+		mode = "reparent"
+	}
+	if mode != "reparent" && mode != "cascade" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid delete mode")
+	}
+
+	collection, err := hc.Queryer.GetCollectionByID(context.Background(), int32(collectionID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		if mode == "reparent" {
+			err := queries.ReparentChildCollections(
+				context.Background(),
+				models.ReparentChildCollectionsParams{
+					NewParentID:  collection.ParentID,
+					CollectionID: sql.NullInt32{Int32: collection.ID, Valid: true},
+				},
+			)
+			if err != nil {
+				return err
+			}
+
+			if collection.ParentID.Valid {
+				err = queries.CopyCollectionDocuments(
+					context.Background(),
+					models.CopyCollectionDocumentsParams{
+						TargetCollectionID: collection.ParentID.Int32,
+						CollectionID:       collection.ID,
+					},
+				)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return queries.DeleteCollection(context.Background(), collection.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{})
+}
+
+// GetCollectionDocuments lists the documents of a collection, including those of its sub-collections
+// when recursive=true.
+func (hc *HandlerContext) GetCollectionDocuments(c echo.Context) error {
+	collectionID, err := strconv.Atoi(c.Param("collectionID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	recursive := false
+	if recursiveString := c.QueryParam("recursive"); recursiveString != "" {
+		recursive, err = strconv.ParseBool(recursiveString)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid recursive flag")
+		}
+	}
+
+	offset, limit := getOffsetLimit(c)
+
+	documents, err := hc.Queryer.GetCollectionDocuments(
+		context.Background(),
+		models.GetCollectionDocumentsParams{
+			CollectionID: int32(collectionID),
+			Recursive:    recursive,
+			PageOffset:   int32(offset),
+			PageLimit:    int32(limit),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, documents)
+}
+
+func (hc *HandlerContext) AddDocumentToCollection(c echo.Context) error {
+	collectionID, err := strconv.Atoi(c.Param("collectionID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	err = hc.Queryer.AddDocumentToCollection(
+		context.Background(),
+		models.AddDocumentToCollectionParams{
+			CollectionID: int32(collectionID),
+			DocumentID:   int32(documentID),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{})
+}
+
+func (hc *HandlerContext) RemoveDocumentFromCollection(c echo.Context) error {
+	collectionID, err := strconv.Atoi(c.Param("collectionID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	err = hc.Queryer.RemoveDocumentFromCollection(
+		context.Background(),
+		models.RemoveDocumentFromCollectionParams{
+			CollectionID: int32(collectionID),
+			DocumentID:   int32(documentID),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{})
+}
+
+// RegisterCollectionRoutes sets up the routes for organizing documents in collections.
+func RegisterCollectionRoutes(e *echo.Echo, hc *HandlerContext) {
+	restricted := echojwt.JWT(hc.Secret)
+	collectionGroup := e.Group("/collections")
+	collectionGroup.POST("", hc.CreateCollection, restricted)
+	collectionGroup.GET("", hc.GetAccountCollections, restricted)
+	collectionGroup.GET("/:collectionID", hc.GetCollectionByID, restricted, hc.UserOwnsCollectionMiddleware)
+	collectionGroup.PATCH("/:collectionID", hc.UpdateCollection, restricted, hc.UserOwnsCollectionMiddleware)
+	collectionGroup.DELETE("/:collectionID", hc.DeleteCollection, restricted, hc.UserOwnsCollectionMiddleware)
+	collectionGroup.GET(
+		"/:collectionID/documents",
+		hc.GetCollectionDocuments,
+		restricted,
+		hc.UserOwnsCollectionMiddleware,
+	)
+	collectionGroup.PUT(
+		"/:collectionID/documents/:documentID",
+		hc.AddDocumentToCollection,
+		restricted,
+		hc.UserOwnsCollectionMiddleware,
+		hc.RequireDocumentPermission(DocumentViewer),
+	)
+	collectionGroup.DELETE(
+		"/:collectionID/documents/:documentID",
+		hc.RemoveDocumentFromCollection,
+		restricted,
+		hc.UserOwnsCollectionMiddleware,
+		hc.RequireDocumentPermission(DocumentViewer),
+	)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"cloud-solutions-api/encryption"
+	"cloud-solutions-api/models"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/labstack/gommon/log"
+)
+
+// dataKeyRotationBatchSize is how many data keys are wrapped again per query during a rotation.
+const dataKeyRotationBatchSize = 100
+
+var errEncryptionNotConfigured = errors.New("document is encrypted but encryption is not configured")
+
+// newDataKey generates the key the files of a new document are encrypted with. It returns the key
+// along with its wrapped form and the ID of the master key that wrapped it, which are stored with
+// the document. Everything is empty when encryption is disabled.
+func (hc *HandlerContext) newDataKey() ([]byte, []byte, sql.NullString, error) {
+	if hc.KeyManager == nil {
+		return nil, nil, sql.NullString{}, nil
+	}
+
+	dataKey, err := encryption.GenerateDataKey()
+	if err != nil {
+		return nil, nil, sql.NullString{}, err
+	}
+	wrappedKey, keyID, err := hc.KeyManager.WrapKey(dataKey)
+	if err != nil {
+		return nil, nil, sql.NullString{}, err
+	}
+	return dataKey, wrappedKey, sql.NullString{String: keyID, Valid: true}, nil
+}
+
+// documentDataKey unwraps the key the files of a document are encrypted with. Documents stored
+// before encryption was enabled have no key, and their files are read as they are.
+func (hc *HandlerContext) documentDataKey(doc models.Document) ([]byte, error) {
+	if doc.DataKey == nil {
+		return nil, nil
+	}
+	if hc.KeyManager == nil {
+		return nil, errEncryptionNotConfigured
+	}
+	return hc.KeyManager.UnwrapKey(doc.DataKey, doc.DataKeyID.String)
+}
+
+// ensureDocumentDataKey returns the key new files of a document are encrypted with, giving a key
+// to documents stored before encryption was enabled. It returns a nil key when encryption is
+// disabled.
+func (hc *HandlerContext) ensureDocumentDataKey(doc models.Document) ([]byte, error) {
+	if hc.KeyManager == nil {
+		return nil, nil
+	}
+	if doc.DataKey != nil {
+		return hc.documentDataKey(doc)
+	}
+
+	dataKey, wrappedKey, keyID, err := hc.newDataKey()
+	if err != nil {
+		return nil, err
+	}
+	updated, err := hc.Queryer.SetDocumentDataKeyIfMissing(context.Background(), models.SetDocumentDataKeyIfMissingParams{
+		DataKey:   wrappedKey,
+		DataKeyID: keyID,
+		ID:        doc.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if updated == 1 {
+		return dataKey, nil
+	}
+
+	// Another request gave the document a key first, its files may already be encrypted with it.
+	doc, err = hc.Queryer.GetDocumentByID(context.Background(), doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	return hc.documentDataKey(doc)
+}
+
+// DataKeyRotationReport is the outcome of wrapping data keys again with the current master key.
+type DataKeyRotationReport struct {
+	Rotated int
+	Failed  int
+}
+
+// RotateDataKeys wraps the data key of every document wrapped by an older master key again with
+// the current one. The files keep being encrypted with the same data keys, so none is rewritten.
+// Keys that cannot be unwrapped are logged and left as they are, so the older master keys must be
+// kept until a rotation reports no failure.
+func (hc *HandlerContext) RotateDataKeys() (DataKeyRotationReport, error) {
+	var report DataKeyRotationReport
+	if hc.KeyManager == nil {
+		return report, errors.New("encryption is not configured")
+	}
+
+	currentKeyID := hc.KeyManager.CurrentKeyID()
+	var afterID int32
+	for {
+		dataKeys, err := hc.Queryer.ListDocumentDataKeysToRotate(
+			context.Background(),
+			models.ListDocumentDataKeysToRotateParams{
+				CurrentKeyID: currentKeyID,
+				AfterID:      afterID,
+				PageLimit:    dataKeyRotationBatchSize,
+			},
+		)
+		if err != nil {
+			return report, err
+		}
+		if len(dataKeys) == 0 {
+			return report, nil
+		}
+
+		for _, dataKey := range dataKeys {
+			afterID = dataKey.ID
+			if err := hc.rotateDataKey(dataKey); err != nil {
+				log.Errorf("error rotating the data key of document %d: %s", dataKey.ID, err)
+				report.Failed++
+				continue
+			}
+			report.Rotated++
+		}
+	}
+}
+
+// rotateDataKey wraps the data key of one document again with the current master key. A key
+// changed since it was listed is left alone.
+func (hc *HandlerContext) rotateDataKey(dataKey models.ListDocumentDataKeysToRotateRow) error {
+	unwrappedKey, err := hc.KeyManager.UnwrapKey(dataKey.DataKey, dataKey.DataKeyID.String)
+	if err != nil {
+		return err
+	}
+	wrappedKey, keyID, err := hc.KeyManager.WrapKey(unwrappedKey)
+	if err != nil {
+		return err
+	}
+	_, err = hc.Queryer.RewrapDocumentDataKey(context.Background(), models.RewrapDocumentDataKeyParams{
+		DataKey:       wrappedKey,
+		DataKeyID:     sql.NullString{String: keyID, Valid: true},
+		ID:            dataKey.ID,
+		PreviousKeyID: dataKey.DataKeyID.String,
+	})
+	return err
+}
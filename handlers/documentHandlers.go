@@ -7,96 +7,286 @@ import (
 	"cloud-solutions-api/pubSubPublisher"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
+	"github.com/sqlc-dev/pqtype"
+	"mime"
 	"net/http"
+	"path/filepath"
 	"strconv"
 )
 
+// DocumentPermission is the level of access an account has on a document. Each level includes
+// the ones below it.
+type DocumentPermission int
+
+const (
+	DocumentViewer DocumentPermission = iota + 1
+	DocumentEditor
+	DocumentOwner
+)
+
+var documentPermissions = map[string]DocumentPermission{
+	"viewer": DocumentViewer,
+	"editor": DocumentEditor,
+	"owner":  DocumentOwner,
+}
+
+// RequireDocumentPermission returns a middleware that checks that the currently authenticated user
+// has at least the given permission on the document specified in the request, either as its owner
+// or through a share.
+func (hc *HandlerContext) RequireDocumentPermission(required DocumentPermission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			documentIDString := c.Param("documentID")
+
+			// Validate document ID format
+			documentID, err := strconv.Atoi(documentIDString)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+			}
+
+			// Retrieve the current account
+			account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+
+			permissionName, err := hc.Queryer.GetDocumentPermission(
+				context.Background(),
+				models.GetDocumentPermissionParams{AccountID: account.ID, DocumentID: int32(documentID)},
+			)
+			if errors.Is(err, sql.ErrNoRows) {
+				return echo.NewHTTPError(http.StatusForbidden, "Forbidden: You do not have access to this document")
+			}
+			if err != nil {
+				return err
+			}
+
+			permission := documentPermissions[permissionName]
+			if permission < required {
+				return echo.NewHTTPError(http.StatusForbidden, "Forbidden: You do not have enough permissions on this document")
+			}
+
+			return next(c)
+		}
+	}
+}
+
 // UserOwnsDocumentMiddleware is a middleware function to check if the currently
 // authenticated user owns the document specified in the request.
 func (hc *HandlerContext) UserOwnsDocumentMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		documentIDString := c.Param("documentID")
+	return hc.RequireDocumentPermission(DocumentOwner)(next)
+}
+
+// CreateDocument creates documents from the files uploaded in the file field. A single file gets
+// the created document in response. Several files, or ZIP archives that are expanded into one
+// document per file they contain, get a report with the result of every file.
+func (hc *HandlerContext) CreateDocument(c echo.Context) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	fileHeaders := form.File["file"]
+	if len(fileHeaders) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	fileHeader := fileHeaders[0]
+	if len(fileHeaders) > 1 || document.IsZipFile(fileHeader.Filename, fileHeader.Header.Get("Content-Type")) {
+		return hc.createDocuments(c, account.ID, fileHeaders)
+	}
+
+	data, err := document.ReadFileHeader(fileHeader)
+	if err != nil {
+		return err
+	}
+
+	newDocument, err := hc.createDocument(c, account.ID, fileHeader.Filename, fileHeader.Header.Get("Content-Type"), data)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, newDocument)
+}
+
+// createDocument stores a file and creates a document for it with its first version, then
+// renders its thumbnail and sends it to be indexed.
+func (hc *HandlerContext) createDocument(
+	c echo.Context,
+	accountID int32,
+	fileName string,
+	mimeType string,
+	data []byte,
+) (models.Document, error) {
+	dataKey, wrappedKey, dataKeyID, err := hc.newDataKey()
+	if err != nil {
+		return models.Document{}, err
+	}
+
+	path, err := document.SaveDocumentDataInBucket(fileName, mimeType, data, dataKey, hc.Bucket)
+	if err != nil {
+		return models.Document{}, err
+	}
 
-		// Validate document ID format
-		documentID, err := strconv.Atoi(documentIDString)
+	extraction, err := document.Extract(fileName, mimeType, data, hc.ExtractOptions)
+	if err != nil {
+		c.Logger().Errorf("error extracting text from document: %s", err)
+	}
+
+	sourceMetadata, err := json.Marshal(extraction.Metadata)
+	if err != nil {
+		return models.Document{}, err
+	}
+
+	contentHash := sql.NullString{String: document.ContentHash(data), Valid: true}
+	sizeBytes := sql.NullInt64{Int64: int64(len(data)), Valid: true}
+
+	var newDocument models.Document
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		newDocument, err = queries.CreateDocument(
+			context.Background(),
+			models.CreateDocumentParams{
+				Name:      fileName,
+				Text:      sql.NullString{String: extraction.Text, Valid: true},
+				FilePath:  sql.NullString{String: path, Valid: true},
+				Embedding: nil,
+				AccountID: accountID,
+				PageCount: sql.NullInt32{Int32: int32(len(extraction.Pages)), Valid: extraction.Pages != nil},
+				SourceMetadata: pqtype.NullRawMessage{
+					RawMessage: sourceMetadata,
+					Valid:      extraction.Metadata != nil,
+				},
+				FileName:    fileName,
+				SizeBytes:   sizeBytes,
+				ContentHash: contentHash,
+				DataKey:     wrappedKey,
+				DataKeyID:   dataKeyID,
+			},
+		)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+			return err
 		}
 
-		// Retrieve the current account
-		account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+		err = hc.addAccountUsage(queries, models.AddAccountUsageParams{
+			AccountID:     accountID,
+			DocumentCount: 1,
+			StoredBytes:   sizeBytes.Int64,
+		})
 		if err != nil {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			return err
 		}
 
-		// Check if the user owns the document
-		owned, err := hc.Queryer.AccountOwnsDocument(
-			context.Background(),
-			models.AccountOwnsDocumentParams{AccountID: account.ID, ID: int32(documentID)},
-		)
+		_, err = queries.CreateDocumentVersion(context.Background(), models.CreateDocumentVersionParams{
+			DocumentID:    newDocument.ID,
+			VersionNumber: newDocument.CurrentVersion,
+			FileName:      newDocument.FileName,
+			FilePath:      newDocument.FilePath,
+			Text:          newDocument.Text,
+			ContentHash:   contentHash,
+			SizeBytes:     sizeBytes,
+		})
 		if err != nil {
 			return err
 		}
 
-		// Deny access if the user doesn't own the document
-		if !owned {
-			return echo.NewHTTPError(http.StatusForbidden, "Forbidden: You do not own this document")
-		}
+		return createDocumentPages(queries, newDocument.ID, extraction.Pages)
+	})
+	if err != nil {
+		hc.deleteDocumentFile(path)
+		return models.Document{}, err
+	}
 
-		// If the user owns the document, proceed to the next handler
-		return next(c)
+	// A missing thumbnail is generated again on first request, so it must not fail the upload.
+	if _, thumbnailPath, err := hc.storeThumbnail(newDocument, data); err != nil {
+		c.Logger().Errorf("error storing document thumbnail: %s", err)
+	} else {
+		newDocument.ThumbnailPath = sql.NullString{String: thumbnailPath, Valid: true}
 	}
+
+	err = hc.PuSubPublisher.PublishDocumentIndexingMessage(pubSubPublisher.DocumentIndexingMessage{
+		DocumentId:   newDocument.ID,
+		DocumentText: newDocument.Text.String,
+	})
+	if err != nil {
+		return newDocument, err
+	}
+
+	return newDocument, nil
 }
 
-func (hc *HandlerContext) CreateDocument(c echo.Context) error {
-	fileHeader, err := c.FormFile("file")
+// DownloadDocumentFile serves the current file of a document, decrypted when it is stored encrypted.
+func (hc *HandlerContext) DownloadDocumentFile(c echo.Context) error {
+	documentID, err := strconv.Atoi(c.Param("documentID"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
 	}
 
-	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	retrievedDocument, err := hc.Queryer.GetDocumentByID(context.Background(), int32(documentID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Document not found")
+	}
 	if err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+		return err
 	}
 
-	path, err := document.SaveDocumentFileInBucket(fileHeader, hc.Bucket)
+	return hc.serveDocumentFile(c, retrievedDocument)
+}
+
+// serveDocumentFile sends the current file of a document as an attachment.
+func (hc *HandlerContext) serveDocumentFile(c echo.Context, doc models.Document) error {
+	dataKey, err := hc.documentDataKey(doc)
 	if err != nil {
 		return err
 	}
 
-	text, err := document.ExtractTextFromDocumentFile(fileHeader)
+	data, err := document.ReadDocumentFileFromBucket(doc.FilePath.String, dataKey, hc.Bucket)
 	if err != nil {
-		c.Logger().Errorf("error extracting text from document: %s", err)
+		return err
 	}
 
-	newDocument, err := hc.Queryer.CreateDocument(
-		context.Background(),
-		models.CreateDocumentParams{
-			Name:      fileHeader.Filename,
-			Text:      sql.NullString{String: text, Valid: true},
-			FilePath:  sql.NullString{String: path, Valid: true},
-			Embedding: nil,
-			AccountID: account.ID,
-		},
+	contentType := mime.TypeByExtension(filepath.Ext(doc.FileName))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	c.Response().Header().Set(
+		echo.HeaderContentDisposition,
+		mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}),
 	)
+	return c.Blob(http.StatusOK, contentType, data)
+}
+
+// DeleteDocumentByID moves a document to the trash. Its files are kept until it is purged.
+func (hc *HandlerContext) DeleteDocumentByID(c echo.Context) error {
+	documentIDString := c.Param("documentID")
+
+	documentID, err := strconv.Atoi(documentIDString)
 	if err != nil {
-		return err
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
 	}
 
-	err = hc.PuSubPublisher.PublishDocumentIndexingMessage(pubSubPublisher.DocumentIndexingMessage{
-		DocumentId:   newDocument.ID,
-		DocumentText: newDocument.Text.String,
-	})
+	err = hc.Queryer.TrashDocument(
+		context.Background(),
+		int32(documentID),
+	)
+
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusCreated, newDocument)
+	return c.JSON(http.StatusOK, echo.Map{})
 }
 
-func (hc *HandlerContext) DeleteDocumentByID(c echo.Context) error {
+func (hc *HandlerContext) GetDocumentByID(c echo.Context) error {
 	documentIDString := c.Param("documentID")
 
 	documentID, err := strconv.Atoi(documentIDString)
@@ -109,27 +299,119 @@ func (hc *HandlerContext) DeleteDocumentByID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
 	}
 
-	err = hc.Queryer.DeleteDocument(
+	return c.JSON(http.StatusOK, retrievedDocument)
+}
+
+// GetDocumentPages returns the extracted text of a document page by page, for documents
+// with a page structure such as PDFs.
+func (hc *HandlerContext) GetDocumentPages(c echo.Context) error {
+	documentIDString := c.Param("documentID")
+
+	documentID, err := strconv.Atoi(documentIDString)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	offset, limit := getOffsetLimit(c)
+
+	pages, err := hc.Queryer.GetDocumentPages(
 		context.Background(),
-		int32(documentID),
+		models.GetDocumentPagesParams{
+			DocumentID: int32(documentID),
+			Offset:     int32(offset),
+			Limit:      int32(limit),
+		},
 	)
-
 	if err != nil {
 		return err
 	}
 
-	err = document.DeleteDocumentFileFromBucket(retrievedDocument.FilePath.String, hc.Bucket)
+	return c.JSON(http.StatusOK, pages)
+}
+
+func (hc *HandlerContext) GetDocumentPage(c echo.Context) error {
+	documentIDString := c.Param("documentID")
+
+	documentID, err := strconv.Atoi(documentIDString)
 	if err != nil {
-		c.Logger().Errorf("error deleting document file from bucket: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{})
+	pageNumber, err := strconv.Atoi(c.Param("pageNumber"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid page number")
+	}
+
+	page, err := hc.Queryer.GetDocumentPage(
+		context.Background(),
+		models.GetDocumentPageParams{
+			DocumentID: int32(documentID),
+			PageNumber: int32(pageNumber),
+		},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// createDocumentPages stores the text of every page of a document, numbering them from 1.
+func createDocumentPages(queries *models.Queries, documentID int32, pages []string) error {
+	for i, pageText := range pages {
+		err := queries.CreateDocumentPage(context.Background(), models.CreateDocumentPageParams{
+			DocumentID: documentID,
+			PageNumber: int32(i + 1),
+			Text:       pageText,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // RegisterDocumentRoutes sets up the routes for document operations, applying JWT authentication for restricted access.
 func RegisterDocumentRoutes(e *echo.Echo, hc *HandlerContext) {
 	restricted := echojwt.JWT(hc.Secret)
+	viewer := hc.RequireDocumentPermission(DocumentViewer)
+	editor := hc.RequireDocumentPermission(DocumentEditor)
 	documentGroup := e.Group("/documents")
 	documentGroup.POST("", hc.CreateDocument, restricted)
+	documentGroup.POST("/batch", hc.BatchDocuments, restricted)
+	documentGroup.GET("/:documentID", hc.GetDocumentByID, restricted, viewer)
+	documentGroup.PATCH("/:documentID", hc.UpdateDocument, restricted, editor)
+	documentGroup.GET("/:documentID/file", hc.DownloadDocumentFile, restricted, viewer)
+	documentGroup.PUT("/:documentID/file", hc.ReplaceDocumentFile, restricted, editor)
+	documentGroup.GET("/:documentID/tags", hc.GetDocumentTags, restricted, viewer)
+	documentGroup.POST("/:documentID/tags", hc.AddDocumentTags, restricted, editor)
+	documentGroup.DELETE("/:documentID/tags/:tag", hc.RemoveDocumentTag, restricted, editor)
+	documentGroup.GET("/:documentID/versions", hc.GetDocumentVersions, restricted, viewer)
+	documentGroup.POST(
+		"/:documentID/versions/:versionNumber/restore",
+		hc.RestoreDocumentVersion,
+		restricted,
+		editor,
+	)
+	documentGroup.GET("/:documentID/pages", hc.GetDocumentPages, restricted, viewer)
+	documentGroup.GET("/:documentID/pages/:pageNumber", hc.GetDocumentPage, restricted, viewer)
+	documentGroup.GET("/:documentID/thumbnail", hc.GetDocumentThumbnail, restricted, viewer)
+	documentGroup.GET(
+		"/:documentID/pages/:pageNumber/image",
+		hc.GetDocumentPageImage,
+		restricted,
+		viewer,
+	)
+	documentGroup.GET("/:documentID/shares", hc.GetDocumentShares, restricted, hc.UserOwnsDocumentMiddleware)
+	documentGroup.POST("/:documentID/shares", hc.ShareDocument, restricted, hc.UserOwnsDocumentMiddleware)
+	documentGroup.DELETE(
+		"/:documentID/shares/:accountID",
+		hc.UnshareDocument,
+		restricted,
+		hc.UserOwnsDocumentMiddleware,
+	)
 	documentGroup.DELETE("/:documentID", hc.DeleteDocumentByID, restricted, hc.UserOwnsDocumentMiddleware)
 }
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"cloud-solutions-api/document"
+	"cloud-solutions-api/models"
+	"cloud.google.com/go/storage"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// previewCacheControl makes clients revalidate rendered images, which change when the document is
+// replaced under the same URL; the ETag spares the transfer while they have not changed.
+const previewCacheControl = "private, no-cache"
+
+// storeThumbnail renders the thumbnail of a document, saves it in the bucket and records its path.
+// It returns the rendered thumbnail together with its path.
+func (hc *HandlerContext) storeThumbnail(doc models.Document, data []byte) ([]byte, string, error) {
+	thumbnail, err := document.RenderThumbnail(doc.FileName, data, doc.Text.String)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dataKey, err := hc.documentDataKey(doc)
+	if err != nil {
+		return nil, "", err
+	}
+
+	thumbnailPath := document.ThumbnailObjectName(doc.ID)
+	err = document.SaveObjectInBucket(thumbnailPath, "image/png", thumbnail, dataKey, hc.Bucket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = hc.Queryer.SetDocumentThumbnailPath(
+		context.Background(),
+		models.SetDocumentThumbnailPathParams{
+			ThumbnailPath: sql.NullString{String: thumbnailPath, Valid: true},
+			ID:            doc.ID,
+		},
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	return thumbnail, thumbnailPath, nil
+}
+
+// servePNG serves a rendered image with an ETag derived from its content, answering 304 when the
+// client already holds it.
+func servePNG(c echo.Context, image []byte) error {
+	hash := sha256.Sum256(image)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+
+	c.Response().Header().Set(echo.HeaderCacheControl, previewCacheControl)
+	c.Response().Header().Set("ETag", etag)
+	if ifNoneMatchContains(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.Blob(http.StatusOK, "image/png", image)
+}
+
+// ifNoneMatchContains reports whether an If-None-Match header lists the given entity tag.
+func ifNoneMatchContains(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDocumentThumbnail serves the thumbnail of a document, rendering it first if it was never
+// generated or has been lost.
+func (hc *HandlerContext) GetDocumentThumbnail(c echo.Context) error {
+	documentIDString := c.Param("documentID")
+
+	documentID, err := strconv.Atoi(documentIDString)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	retrievedDocument, err := hc.Queryer.GetDocumentByID(context.Background(), int32(documentID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	dataKey, err := hc.documentDataKey(retrievedDocument)
+	if err != nil {
+		return err
+	}
+
+	if retrievedDocument.ThumbnailPath.Valid {
+		thumbnail, err := document.ReadObjectFromBucket(retrievedDocument.ThumbnailPath.String, dataKey, hc.Bucket)
+		if err == nil {
+			return servePNG(c, thumbnail)
+		}
+		if !errors.Is(err, storage.ErrObjectNotExist) {
+			return err
+		}
+	}
+
+	data, err := document.ReadDocumentFileFromBucket(retrievedDocument.FilePath.String, dataKey, hc.Bucket)
+	if err != nil {
+		return err
+	}
+
+	thumbnail, _, err := hc.storeThumbnail(retrievedDocument, data)
+	if err != nil {
+		return err
+	}
+	return servePNG(c, thumbnail)
+}
+
+// GetDocumentPageImage serves a page of a document rendered as an image of the requested width,
+// rounded up to one of a few supported widths. PDF pages are rendered once per width and cached in
+// the bucket; other formats have a single placeholder page.
+func (hc *HandlerContext) GetDocumentPageImage(c echo.Context) error {
+	documentIDString := c.Param("documentID")
+
+	documentID, err := strconv.Atoi(documentIDString)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	pageNumber, err := strconv.Atoi(c.Param("pageNumber"))
+	if err != nil || pageNumber < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid page number")
+	}
+
+	width := 0
+	if widthString := c.QueryParam("width"); widthString != "" {
+		width, err = strconv.Atoi(widthString)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid width")
+		}
+	}
+	width = document.SnapPreviewWidth(width)
+
+	retrievedDocument, err := hc.Queryer.GetDocumentByID(context.Background(), int32(documentID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	if !document.IsPDF(retrievedDocument.FileName) {
+		if pageNumber != 1 {
+			return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+		}
+		placeholder, err := document.RenderPlaceholder(retrievedDocument.FileName, retrievedDocument.Text.String, width)
+		if err != nil {
+			return err
+		}
+		return servePNG(c, placeholder)
+	}
+
+	dataKey, err := hc.documentDataKey(retrievedDocument)
+	if err != nil {
+		return err
+	}
+
+	previewName := document.PreviewObjectName(
+		retrievedDocument.ID,
+		retrievedDocument.CurrentVersion,
+		pageNumber,
+		width,
+	)
+	preview, err := document.ReadObjectFromBucket(previewName, dataKey, hc.Bucket)
+	if err == nil {
+		return servePNG(c, preview)
+	}
+	if !errors.Is(err, storage.ErrObjectNotExist) {
+		return err
+	}
+
+	data, err := document.ReadDocumentFileFromBucket(retrievedDocument.FilePath.String, dataKey, hc.Bucket)
+	if err != nil {
+		return err
+	}
+
+	preview, err = document.RenderPDFPage(data, pageNumber, width)
+	if errors.Is(err, document.ErrPageOutOfRange) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+	if errors.Is(err, document.ErrPageTooLarge) {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Page is too large to render")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := document.SaveObjectInBucket(previewName, "image/png", preview, dataKey, hc.Bucket); err != nil {
+		c.Logger().Errorf("error caching page preview: %s", err)
+	}
+
+	return servePNG(c, preview)
+}
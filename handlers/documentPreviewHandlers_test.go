@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestServePNG(t *testing.T) {
+	image := []byte("png bytes")
+
+	e := echo.New()
+	recorder := httptest.NewRecorder()
+	if err := servePNG(e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), recorder), image); err != nil {
+		t.Fatalf("servePNG() error = %v", err)
+	}
+	etag := recorder.Header().Get("ETag")
+	if recorder.Code != http.StatusOK || etag == "" {
+		t.Fatalf("servePNG() = %d with ETag %q", recorder.Code, etag)
+	}
+	if got := recorder.Header().Get(echo.HeaderCacheControl); got != previewCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", got, previewCacheControl)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        int
+	}{
+		{name: "same image", ifNoneMatch: etag, want: http.StatusNotModified},
+		{name: "weak tag in list", ifNoneMatch: `"other", W/` + etag, want: http.StatusNotModified},
+		{name: "replaced image", ifNoneMatch: `"other"`, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("If-None-Match", tt.ifNoneMatch)
+			recorder := httptest.NewRecorder()
+			if err := servePNG(e.NewContext(request, recorder), image); err != nil {
+				t.Fatalf("servePNG() error = %v", err)
+			}
+			if recorder.Code != tt.want {
+				t.Errorf("servePNG() = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
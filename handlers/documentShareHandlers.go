@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"cloud-solutions-api/authentication"
+	"cloud-solutions-api/models"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GetDocumentShares lists the accounts a document is shared with.
+func (hc *HandlerContext) GetDocumentShares(c echo.Context) error {
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	shares, err := hc.Queryer.ListDocumentShares(context.Background(), int32(documentID))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, shares)
+}
+
+// ShareDocument grants another account, given by username or email, viewer or editor permission
+// on a document. Sharing again with the same account changes its permission.
+func (hc *HandlerContext) ShareDocument(c echo.Context) error {
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	var shareParams = struct {
+		Account    string `json:"account"`
+		Permission string `json:"permission"`
+	}{}
+	if err := c.Bind(&shareParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	permission, ok := documentPermissions[shareParams.Permission]
+	if !ok || permission == DocumentOwner {
+		return echo.NewHTTPError(http.StatusBadRequest, "Permission must be viewer or editor")
+	}
+
+	owner, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	recipient, err := hc.Queryer.GetAccountByUsernameOrEmail(
+		context.Background(),
+		strings.TrimSpace(shareParams.Account),
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Account not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if recipient.ID == owner.ID {
+		return echo.NewHTTPError(http.StatusBadRequest, "A document cannot be shared with its owner")
+	}
+
+	share, err := hc.Queryer.UpsertDocumentShare(context.Background(), models.UpsertDocumentShareParams{
+		DocumentID: int32(documentID),
+		AccountID:  recipient.ID,
+		Permission: shareParams.Permission,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, share)
+}
+
+func (hc *HandlerContext) UnshareDocument(c echo.Context) error {
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	accountID, err := strconv.Atoi(c.Param("accountID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid account ID")
+	}
+
+	err = hc.Queryer.DeleteDocumentShare(context.Background(), models.DeleteDocumentShareParams{
+		DocumentID: int32(documentID),
+		AccountID:  int32(accountID),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{})
+}
+
+// GetSharedDocuments lists the documents other accounts shared with the current user, with the
+// permission granted and the username of their owner.
+func (hc *HandlerContext) GetSharedDocuments(c echo.Context) error {
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	offset, limit := getOffsetLimit(c)
+
+	documents, err := hc.Queryer.ListSharedDocuments(context.Background(), models.ListSharedDocumentsParams{
+		AccountID: account.ID,
+		Offset:    int32(offset),
+		Limit:     int32(limit),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, documents)
+}
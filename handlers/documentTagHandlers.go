@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"cloud-solutions-api/authentication"
+	"cloud-solutions-api/models"
+	"context"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxTagLength = 64
+
+// normalizeTag makes tags case-insensitive and rejects the ones that cannot be used as a filter.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLength || strings.Contains(tag, ",") {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Invalid tag")
+	}
+	return tag, nil
+}
+
+func (hc *HandlerContext) GetDocumentTags(c echo.Context) error {
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	tags, err := hc.Queryer.GetDocumentTags(context.Background(), int32(documentID))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tags)
+}
+
+// AddDocumentTags adds tags to a document, ignoring the ones it already has, and returns all its tags.
+func (hc *HandlerContext) AddDocumentTags(c echo.Context) error {
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	var tagParams = struct {
+		Tags []string `json:"tags"`
+	}{}
+	if err := c.Bind(&tagParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	tags := make([]string, 0, len(tagParams.Tags))
+	for _, tag := range tagParams.Tags {
+		normalized, err := normalizeTag(tag)
+		if err != nil {
+			return err
+		}
+		tags = append(tags, normalized)
+	}
+
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		for _, tag := range tags {
+			err := queries.AddDocumentTag(context.Background(), models.AddDocumentTagParams{
+				DocumentID: int32(documentID),
+				Tag:        tag,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	documentTags, err := hc.Queryer.GetDocumentTags(context.Background(), int32(documentID))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, documentTags)
+}
+
+func (hc *HandlerContext) RemoveDocumentTag(c echo.Context) error {
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	tagParam, err := url.PathUnescape(c.Param("tag"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid tag")
+	}
+
+	tag, err := normalizeTag(tagParam)
+	if err != nil {
+		return err
+	}
+
+	err = hc.Queryer.RemoveDocumentTag(context.Background(), models.RemoveDocumentTagParams{
+		DocumentID: int32(documentID),
+		Tag:        tag,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{})
+}
+
+// GetAccountTags lists the tags used by the current user with the number of documents having each.
+func (hc *HandlerContext) GetAccountTags(c echo.Context) error {
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	tags, err := hc.Queryer.GetAccountTags(context.Background(), account.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tags)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"cloud-solutions-api/document"
+	"cloud-solutions-api/models"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"mime/multipart"
+	"net/http"
+	"path"
+)
+
+// documentUploadResult reports what happened to one file of a bulk upload. Files expanded from a
+// ZIP archive name the archive they come from. A warning tells about a document that was created
+// but could not be fully processed.
+type documentUploadResult struct {
+	FileName string           `json:"fileName"`
+	Archive  string           `json:"archive,omitempty"`
+	Document *models.Document `json:"document,omitempty"`
+	Warning  string           `json:"warning,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// uploadErrorMessage describes why a file of a bulk upload failed, without exposing internal errors.
+func uploadErrorMessage(c echo.Context, err error) string {
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		return fmt.Sprint(httpError.Message)
+	}
+	if errors.Is(err, document.ErrTooManyZipEntries) ||
+		errors.Is(err, document.ErrZipTooLarge) ||
+		errors.Is(err, document.ErrUnsafeZipPath) {
+		return err.Error()
+	}
+
+	c.Logger().Errorf("error creating document: %s", err)
+	return "Document could not be created"
+}
+
+// createUploadedDocument creates a document from one file of a bulk upload and reports the result.
+func (hc *HandlerContext) createUploadedDocument(
+	c echo.Context,
+	accountID int32,
+	result documentUploadResult,
+	mimeType string,
+	data []byte,
+) documentUploadResult {
+	newDocument, err := hc.createDocument(c, accountID, path.Base(result.FileName), mimeType, data)
+	switch {
+	case newDocument.ID != 0:
+		result.Document = &newDocument
+		if err != nil {
+			c.Logger().Errorf("error requesting document indexing: %s", err)
+			result.Warning = "Document was created but could not be indexed yet"
+		}
+	case err != nil:
+		result.Error = uploadErrorMessage(c, err)
+	}
+	return result
+}
+
+// createZipDocuments expands a ZIP archive and creates a document for every supported file it
+// contains. Unsupported files are reported as failures.
+func (hc *HandlerContext) createZipDocuments(c echo.Context, accountID int32, archiveName string, data []byte) []documentUploadResult {
+	entries, err := document.ExpandZip(data, document.DefaultZipLimits)
+	if err != nil {
+		return []documentUploadResult{{FileName: archiveName, Error: uploadErrorMessage(c, err)}}
+	}
+
+	results := make([]documentUploadResult, 0, len(entries))
+	for _, entry := range entries {
+		result := documentUploadResult{FileName: entry.Name, Archive: archiveName}
+		switch {
+		case entry.Err != nil:
+			result.Error = uploadErrorMessage(c, entry.Err)
+		case !document.IsSupportedFormat(entry.Name, ""):
+			result.Error = "Unsupported file format"
+		default:
+			result = hc.createUploadedDocument(c, accountID, result, "", entry.Data)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// createDocuments creates documents from several uploaded files and ZIP archives. Each file
+// succeeds or fails on its own, and the response reports the result of every file.
+func (hc *HandlerContext) createDocuments(c echo.Context, accountID int32, fileHeaders []*multipart.FileHeader) error {
+	var results []documentUploadResult
+	for _, fileHeader := range fileHeaders {
+		mimeType := fileHeader.Header.Get("Content-Type")
+
+		data, err := document.ReadFileHeader(fileHeader)
+		if err != nil {
+			results = append(results, documentUploadResult{
+				FileName: fileHeader.Filename,
+				Error:    uploadErrorMessage(c, err),
+			})
+			continue
+		}
+
+		if document.IsZipFile(fileHeader.Filename, mimeType) {
+			results = append(results, hc.createZipDocuments(c, accountID, fileHeader.Filename, data)...)
+			continue
+		}
+
+		result := documentUploadResult{FileName: fileHeader.Filename}
+		results = append(results, hc.createUploadedDocument(c, accountID, result, mimeType, data))
+	}
+
+	created, failed := 0, 0
+	for _, result := range results {
+		if result.Document != nil {
+			created++
+		} else {
+			failed++
+		}
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"created": created,
+		"failed":  failed,
+		"results": results,
+	})
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"cloud-solutions-api/document"
+	"cloud-solutions-api/models"
+	"cloud-solutions-api/pubSubPublisher"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/sqlc-dev/pqtype"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// documentFile is the content of a document version that becomes the current one.
+type documentFile struct {
+	FileName string
+	FilePath string
+	Data     []byte
+	MimeType string
+	// Uploaded is set when the file was just stored in the bucket rather than shared with a
+	// previous version, so it counts towards the stored bytes of the account.
+	Uploaded bool
+}
+
+// setDocumentFile records file as a new version of a document and makes it the current one,
+// replacing its text and pages. Previews of the previous version are dropped, the thumbnail is
+// rendered again and the document is sent to be indexed again.
+func (hc *HandlerContext) setDocumentFile(c echo.Context, doc models.Document, file documentFile) (models.Document, error) {
+	extraction, err := document.Extract(file.FileName, file.MimeType, file.Data, hc.ExtractOptions)
+	if err != nil {
+		c.Logger().Errorf("error extracting text from document: %s", err)
+	}
+
+	sourceMetadata, err := json.Marshal(extraction.Metadata)
+	if err != nil {
+		return doc, err
+	}
+
+	contentHash := sql.NullString{String: document.ContentHash(file.Data), Valid: true}
+	sizeBytes := sql.NullInt64{Int64: int64(len(file.Data)), Valid: true}
+	text := sql.NullString{String: extraction.Text, Valid: true}
+	filePath := sql.NullString{String: file.FilePath, Valid: true}
+
+	var updatedDocument models.Document
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		// Locking the document keeps concurrent replacements from taking the same version number.
+		lockedDocument, err := queries.GetDocumentForUpdate(context.Background(), doc.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Document not found")
+		}
+		if err != nil {
+			return err
+		}
+		versionNumber := lockedDocument.CurrentVersion + 1
+
+		if file.Uploaded {
+			err = hc.addAccountUsage(queries, models.AddAccountUsageParams{
+				AccountID:   doc.AccountID,
+				StoredBytes: sizeBytes.Int64,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = queries.CreateDocumentVersion(context.Background(), models.CreateDocumentVersionParams{
+			DocumentID:    doc.ID,
+			VersionNumber: versionNumber,
+			FileName:      file.FileName,
+			FilePath:      filePath,
+			Text:          text,
+			ContentHash:   contentHash,
+			SizeBytes:     sizeBytes,
+		})
+		if err != nil {
+			return err
+		}
+
+		updatedDocument, err = queries.SetDocumentFile(context.Background(), models.SetDocumentFileParams{
+			FileName:  file.FileName,
+			FilePath:  filePath,
+			Text:      text,
+			PageCount: sql.NullInt32{Int32: int32(len(extraction.Pages)), Valid: extraction.Pages != nil},
+			SourceMetadata: pqtype.NullRawMessage{
+				RawMessage: sourceMetadata,
+				Valid:      extraction.Metadata != nil,
+			},
+			SizeBytes:      sizeBytes,
+			ContentHash:    contentHash,
+			CurrentVersion: versionNumber,
+			ID:             doc.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := queries.DeleteDocumentPages(context.Background(), doc.ID); err != nil {
+			return err
+		}
+		return createDocumentPages(queries, doc.ID, extraction.Pages)
+	})
+	if err != nil {
+		return doc, err
+	}
+
+	hc.deleteBlob(document.VersionPreviewObjectPrefix(doc.ID, doc.CurrentVersion), true)
+
+	if _, thumbnailPath, err := hc.storeThumbnail(updatedDocument, file.Data); err != nil {
+		c.Logger().Errorf("error storing document thumbnail: %s", err)
+	} else {
+		updatedDocument.ThumbnailPath = sql.NullString{String: thumbnailPath, Valid: true}
+	}
+
+	err = hc.PuSubPublisher.PublishDocumentIndexingMessage(pubSubPublisher.DocumentIndexingMessage{
+		DocumentId:   updatedDocument.ID,
+		DocumentText: updatedDocument.Text.String,
+	})
+	if err != nil {
+		return updatedDocument, err
+	}
+
+	return updatedDocument, nil
+}
+
+// UpdateDocument changes the name, description and metadata of a document. Fields missing from
+// the request keep their value, an empty description clears it and metadata, a JSON object of
+// arbitrary keys, replaces the previous one.
+func (hc *HandlerContext) UpdateDocument(c echo.Context) error {
+	documentIDString := c.Param("documentID")
+
+	documentID, err := strconv.Atoi(documentIDString)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	var updateParams = struct {
+		Name        *string         `json:"name"`
+		Description *string         `json:"description"`
+		Metadata    json.RawMessage `json:"metadata"`
+	}{}
+	if err := c.Bind(&updateParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	retrievedDocument, err := hc.Queryer.GetDocumentByID(context.Background(), int32(documentID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	name := retrievedDocument.Name
+	if updateParams.Name != nil {
+		name = strings.TrimSpace(*updateParams.Name)
+		if name == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Document name cannot be empty")
+		}
+	}
+
+	description := retrievedDocument.Description
+	if updateParams.Description != nil {
+		description = sql.NullString{String: *updateParams.Description, Valid: *updateParams.Description != ""}
+	}
+
+	metadata := retrievedDocument.Metadata
+	if updateParams.Metadata != nil {
+		var fields map[string]any
+		if err := json.Unmarshal(updateParams.Metadata, &fields); err != nil || fields == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Metadata must be a JSON object")
+		}
+		metadata = updateParams.Metadata
+	}
+
+	updatedDocument, err := hc.Queryer.UpdateDocumentDetails(
+		context.Background(),
+		models.UpdateDocumentDetailsParams{
+			Name:        name,
+			Description: description,
+			Metadata:    metadata,
+			ID:          retrievedDocument.ID,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, updatedDocument)
+}
+
+// ReplaceDocumentFile uploads a new file for a document as a new version, keeping the document ID.
+// Uploading the same content as the current version does not create a version.
+func (hc *HandlerContext) ReplaceDocumentFile(c echo.Context) error {
+	documentIDString := c.Param("documentID")
+
+	documentID, err := strconv.Atoi(documentIDString)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	retrievedDocument, err := hc.Queryer.GetDocumentByID(context.Background(), int32(documentID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	data, err := document.ReadFileHeader(fileHeader)
+	if err != nil {
+		return err
+	}
+
+	if retrievedDocument.ContentHash.Valid && retrievedDocument.ContentHash.String == document.ContentHash(data) {
+		return c.JSON(http.StatusOK, retrievedDocument)
+	}
+
+	dataKey, err := hc.ensureDocumentDataKey(retrievedDocument)
+	if err != nil {
+		return err
+	}
+
+	mimeType := fileHeader.Header.Get("Content-Type")
+	path, err := document.SaveDocumentDataInBucket(fileHeader.Filename, mimeType, data, dataKey, hc.Bucket)
+	if err != nil {
+		return err
+	}
+
+	updatedDocument, err := hc.setDocumentFile(c, retrievedDocument, documentFile{
+		FileName: fileHeader.Filename,
+		FilePath: path,
+		Data:     data,
+		MimeType: mimeType,
+		Uploaded: true,
+	})
+	if err != nil {
+		hc.deleteDocumentFile(path)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, updatedDocument)
+}
+
+// GetDocumentVersions lists the versions of a document, newest first.
+func (hc *HandlerContext) GetDocumentVersions(c echo.Context) error {
+	documentIDString := c.Param("documentID")
+
+	documentID, err := strconv.Atoi(documentIDString)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	offset, limit := getOffsetLimit(c)
+
+	versions, err := hc.Queryer.GetDocumentVersions(
+		context.Background(),
+		models.GetDocumentVersionsParams{
+			DocumentID: int32(documentID),
+			Offset:     int32(offset),
+			Limit:      int32(limit),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, versions)
+}
+
+// RestoreDocumentVersion makes a previous version current again. The restored content is
+// recorded as a new version, so the history is never rewritten.
+func (hc *HandlerContext) RestoreDocumentVersion(c echo.Context) error {
+	documentIDString := c.Param("documentID")
+
+	documentID, err := strconv.Atoi(documentIDString)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	versionNumber, err := strconv.Atoi(c.Param("versionNumber"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid version number")
+	}
+
+	retrievedDocument, err := hc.Queryer.GetDocumentByID(context.Background(), int32(documentID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	version, err := hc.Queryer.GetDocumentVersion(
+		context.Background(),
+		models.GetDocumentVersionParams{
+			DocumentID:    retrievedDocument.ID,
+			VersionNumber: int32(versionNumber),
+		},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Version not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if version.VersionNumber == retrievedDocument.CurrentVersion {
+		return c.JSON(http.StatusOK, retrievedDocument)
+	}
+
+	dataKey, err := hc.documentDataKey(retrievedDocument)
+	if err != nil {
+		return err
+	}
+
+	data, err := document.ReadDocumentFileFromBucket(version.FilePath.String, dataKey, hc.Bucket)
+	if err != nil {
+		return err
+	}
+
+	updatedDocument, err := hc.setDocumentFile(c, retrievedDocument, documentFile{
+		FileName: version.FileName,
+		FilePath: version.FilePath.String,
+		Data:     data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, updatedDocument)
+}
@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"cloud-solutions-api/chat"
 	"cloud-solutions-api/config"
+	"cloud-solutions-api/document"
+	"cloud-solutions-api/encryption"
 	"cloud-solutions-api/models"
 	"cloud-solutions-api/pubSubPublisher"
 	"cloud.google.com/go/storage"
 	"context"
+	"database/sql"
+	"encoding/json"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
 	"google.golang.org/api/option"
@@ -13,29 +18,66 @@ import (
 )
 
 type HandlerContext struct {
+	DB             *sql.DB
 	Queryer        *models.Queries
 	PuSubPublisher *pubSubPublisher.PubSubPublisher
 	StorageClient  *storage.Client
 	Bucket         *storage.BucketHandle
 	Secret         []byte
+	UsageLimits    config.UsageLimits
+	// ExtractOptions configures the extraction of uploaded files.
+	ExtractOptions document.Options
+	// KeyManager wraps the keys document files are encrypted with. It is nil when encryption is
+	// disabled.
+	KeyManager encryption.KeyManager
+	// InternalToken authenticates the services calling the internal routes. They are disabled when
+	// it is empty.
+	InternalToken []byte
+	// AllowedOrigins are the origins browsers can open chat sockets from, * allowing any.
+	AllowedOrigins []string
+	ChatEvents     *chat.Broker
 }
 
 func NewHandlerContext(configuration config.Config) *HandlerContext {
 	handlerContext := &HandlerContext{
-		Secret: []byte(configuration.Secret),
+		Secret:         []byte(configuration.Secret),
+		UsageLimits:    configuration.UsageLimits,
+		InternalToken:  []byte(configuration.InternalAPIToken),
+		AllowedOrigins: configuration.AllowedOrigins,
+		ExtractOptions: document.Options{
+			Docx: document.DocxOptions{
+				IncludeHeadersAndFooters: configuration.DocxHeadersAndFooters,
+				IncludeFootnotes:         configuration.DocxFootnotes,
+			},
+		},
 	}
-	queryer, err := models.NewQueryer(models.Config{
+	databaseConfig := models.Config{
 		DBHost:     configuration.DbHost,
 		DBPort:     configuration.DbPort,
 		DBUser:     configuration.DbUser,
 		DBName:     configuration.DbName,
 		DBPassword: configuration.DbPassword,
-	})
+	}
+	db, err := models.NewDatabase(databaseConfig)
+	if err != nil {
+		log.Error(err)
+		panic(err)
+	}
+	if configuration.EncryptionKeyFile != "" {
+		keyManager, err := encryption.LoadLocalKeyManager(configuration.EncryptionKeyFile)
+		if err != nil {
+			log.Error(err)
+			panic(err)
+		}
+		handlerContext.KeyManager = keyManager
+	}
+	handlerContext.DB = db
+	handlerContext.Queryer = models.New(db)
+	handlerContext.ChatEvents, err = chat.NewBroker(models.ConnectionString(databaseConfig))
 	if err != nil {
 		log.Error(err)
 		panic(err)
 	}
-	handlerContext.Queryer = queryer
 	handlerContext.StorageClient, err = storage.NewClient(context.Background(), option.WithCredentialsFile(configuration.GCPServiceAccountFile))
 	handlerContext.Bucket = handlerContext.StorageClient.Bucket(configuration.BucketName)
 	publisher, err := pubSubPublisher.NewPubSubPublisher(configuration.GCPProjectID, configuration.GCPServiceAccountFile)
@@ -66,11 +108,52 @@ func getOffsetLimit(c echo.Context) (int, int) {
 	return offset, limit
 }
 
+// optionalID is a nullable ID in a JSON request body that records whether the field was present,
+// so an explicit null can be told apart from a missing field.
+type optionalID struct {
+	Set   bool
+	Value sql.NullInt32
+}
+
+func (o *optionalID) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = sql.NullInt32{}
+		return nil
+	}
+	var id int32
+	if err := json.Unmarshal(data, &id); err != nil {
+		return err
+	}
+	o.Value = sql.NullInt32{Int32: id, Valid: true}
+	return nil
+}
+
+// withTransaction runs fn with queries bound to a new transaction, committing it when fn
+// succeeds and rolling it back otherwise.
+func (hc *HandlerContext) withTransaction(ctx context.Context, fn func(queries *models.Queries) error) error {
+	tx, err := hc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(hc.Queryer.WithTx(tx)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Error(rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
 func (hc *HandlerContext) Close() []error {
 	var errs []error
 	err := hc.PuSubPublisher.Close()
 	errs = append(errs, err)
 	err = hc.StorageClient.Close()
 	errs = append(errs, err)
+	err = hc.ChatEvents.Close()
+	errs = append(errs, err)
+	err = hc.DB.Close()
+	errs = append(errs, err)
 	return errs
 }
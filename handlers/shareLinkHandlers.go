@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"cloud-solutions-api/authentication"
+	"cloud-solutions-api/models"
+	"context"
+	"database/sql"
+	"errors"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// sharePasswordHeader carries the password of a password protected share link.
+const sharePasswordHeader = "X-Share-Password"
+
+// createdShareLink is returned once when a share link is created, as only the hash of its token
+// is stored.
+type createdShareLink struct {
+	models.ShareLink
+	Token string `json:"token"`
+}
+
+// createShareLink creates a share link for either a document or a chat of the current user.
+func (hc *HandlerContext) createShareLink(c echo.Context, documentID sql.NullInt32, chatID sql.NullInt32) error {
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	var linkParams = struct {
+		ExpiresAt     *time.Time `json:"expiresAt"`
+		Password      string     `json:"password"`
+		AllowDownload bool       `json:"allowDownload"`
+	}{}
+	if err := c.Bind(&linkParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	var expiresAt sql.NullTime
+	if linkParams.ExpiresAt != nil {
+		if !linkParams.ExpiresAt.After(time.Now()) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Expiry must be in the future")
+		}
+		expiresAt = sql.NullTime{Time: *linkParams.ExpiresAt, Valid: true}
+	}
+
+	var passwordHash sql.NullString
+	if linkParams.Password != "" {
+		hashedPassword, err := authentication.HashPassword(linkParams.Password)
+		if err != nil {
+			return err
+		}
+		passwordHash = sql.NullString{String: hashedPassword, Valid: true}
+	}
+
+	token, err := authentication.GenerateShareToken()
+	if err != nil {
+		return err
+	}
+
+	link, err := hc.Queryer.CreateShareLink(context.Background(), models.CreateShareLinkParams{
+		TokenHash:     authentication.HashShareToken(token),
+		AccountID:     account.ID,
+		DocumentID:    documentID,
+		ChatID:        chatID,
+		ExpiresAt:     expiresAt,
+		PasswordHash:  passwordHash,
+		AllowDownload: linkParams.AllowDownload && documentID.Valid,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, createdShareLink{ShareLink: link, Token: token})
+}
+
+// listShareLinks lists the active share links of a document or a chat of the current user.
+func (hc *HandlerContext) listShareLinks(c echo.Context, documentID sql.NullInt32, chatID sql.NullInt32) error {
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	links, err := hc.Queryer.ListShareLinks(context.Background(), models.ListShareLinksParams{
+		AccountID:  account.ID,
+		DocumentID: documentID,
+		ChatID:     chatID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, links)
+}
+
+// CreateDocumentShareLink creates a public link to a document. The link can expire, require a
+// password and allow downloading the file.
+func (hc *HandlerContext) CreateDocumentShareLink(c echo.Context) error {
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	return hc.createShareLink(c, sql.NullInt32{Int32: int32(documentID), Valid: true}, sql.NullInt32{})
+}
+
+func (hc *HandlerContext) GetDocumentShareLinks(c echo.Context) error {
+	documentID, err := strconv.Atoi(c.Param("documentID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+	}
+
+	return hc.listShareLinks(c, sql.NullInt32{Int32: int32(documentID), Valid: true}, sql.NullInt32{})
+}
+
+// CreateChatShareLink creates a public link to the transcript of a chat. The link can expire and
+// require a password.
+func (hc *HandlerContext) CreateChatShareLink(c echo.Context) error {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	return hc.createShareLink(c, sql.NullInt32{}, sql.NullInt32{Int32: int32(chatID), Valid: true})
+}
+
+func (hc *HandlerContext) GetChatShareLinks(c echo.Context) error {
+	chatID, err := strconv.Atoi(c.Param("chatID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chat ID")
+	}
+
+	return hc.listShareLinks(c, sql.NullInt32{}, sql.NullInt32{Int32: int32(chatID), Valid: true})
+}
+
+// RevokeShareLink revokes a share link of the current user. Revoked links stop working at once.
+func (hc *HandlerContext) RevokeShareLink(c echo.Context) error {
+	linkID, err := strconv.Atoi(c.Param("linkID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid share link ID")
+	}
+
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	link, err := hc.Queryer.RevokeShareLink(context.Background(), models.RevokeShareLinkParams{
+		ID:        int32(linkID),
+		AccountID: account.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Share link not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, link)
+}
+
+// getShareLink resolves the token of a public request. Unknown, expired and revoked links are
+// reported the same way, so that a token cannot be probed.
+func (hc *HandlerContext) getShareLink(c echo.Context) (models.ShareLink, error) {
+	link, err := hc.Queryer.GetActiveShareLinkByTokenHash(
+		context.Background(),
+		authentication.HashShareToken(c.Param("token")),
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return link, echo.NewHTTPError(http.StatusNotFound, "Share link not found")
+	}
+	if err != nil {
+		return link, err
+	}
+
+	if link.PasswordHash.Valid {
+		password := c.Request().Header.Get(sharePasswordHeader)
+		if password == "" || !authentication.CheckPasswordHash(password, link.PasswordHash.String) {
+			return link, echo.NewHTTPError(http.StatusUnauthorized, "Invalid share link password")
+		}
+	}
+
+	return link, nil
+}
+
+// GetSharedContent returns what a share link points to: the metadata and text of a document, or
+// the transcript of a chat.
+func (hc *HandlerContext) GetSharedContent(c echo.Context) error {
+	link, err := hc.getShareLink(c)
+	if err != nil {
+		return err
+	}
+
+	if link.ChatID.Valid {
+		chat, err := hc.Queryer.GetChatByID(context.Background(), link.ChatID.Int32)
+		if errors.Is(err, sql.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Share link not found")
+		}
+		if err != nil {
+			return err
+		}
+		chatMessages, err := hc.Queryer.ListActiveChatMessages(context.Background(), chat.ID)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"type": "chat",
+			"chat": echo.Map{
+				"id":        chat.ID,
+				"createdAt": chat.CreatedAt,
+				"messages":  models.ToMessages(chatMessages),
+			},
+		})
+	}
+
+	doc, err := hc.Queryer.GetDocumentByID(context.Background(), link.DocumentID.Int32)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Share link not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"type":          "document",
+		"allowDownload": link.AllowDownload,
+		"document": echo.Map{
+			"id":          doc.ID,
+			"createdAt":   doc.CreatedAt,
+			"name":        doc.Name,
+			"description": doc.Description,
+			"fileName":    doc.FileName,
+			"pageCount":   doc.PageCount,
+			"sizeBytes":   doc.SizeBytes,
+			"metadata":    doc.Metadata,
+			"text":        doc.Text,
+		},
+	})
+}
+
+// DownloadSharedDocument serves the file of a shared document when its link allows downloads.
+func (hc *HandlerContext) DownloadSharedDocument(c echo.Context) error {
+	link, err := hc.getShareLink(c)
+	if err != nil {
+		return err
+	}
+
+	if !link.DocumentID.Valid || !link.AllowDownload {
+		return echo.NewHTTPError(http.StatusForbidden, "Forbidden: This link does not allow downloads")
+	}
+
+	doc, err := hc.Queryer.GetDocumentByID(context.Background(), link.DocumentID.Int32)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Share link not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return hc.serveDocumentFile(c, doc)
+}
+
+// RegisterShareLinkRoutes sets up the routes for managing share links and the public routes
+// serving them.
+func RegisterShareLinkRoutes(e *echo.Echo, hc *HandlerContext) {
+	restricted := echojwt.JWT(hc.Secret)
+	e.POST("/documents/:documentID/links", hc.CreateDocumentShareLink, restricted, hc.UserOwnsDocumentMiddleware)
+	e.GET("/documents/:documentID/links", hc.GetDocumentShareLinks, restricted, hc.UserOwnsDocumentMiddleware)
+	e.POST("/chats/:chatID/links", hc.CreateChatShareLink, restricted, hc.ChatOwnershipMiddleware)
+	e.GET("/chats/:chatID/links", hc.GetChatShareLinks, restricted, hc.ChatOwnershipMiddleware)
+	e.DELETE("/share-links/:linkID", hc.RevokeShareLink, restricted)
+
+	sharedGroup := e.Group("/shared")
+	sharedGroup.GET("/:token", hc.GetSharedContent)
+	sharedGroup.GET("/:token/file", hc.DownloadSharedDocument)
+}
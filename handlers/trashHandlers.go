@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"cloud-solutions-api/authentication"
+	"cloud-solutions-api/document"
+	"cloud-solutions-api/models"
+	"context"
+	"database/sql"
+	"errors"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	trashTypeDocuments = "documents"
+	trashTypeChats     = "chats"
+
+	// trashPurgeInterval is how often the trash is checked for items past the retention period.
+	trashPurgeInterval = time.Hour
+	// trashPurgeBatchSize is how many documents are purged per query, as each needs its files deleted.
+	trashPurgeBatchSize = 100
+)
+
+// parseTrashItem reads the type and ID of a trashed item from the request path.
+func parseTrashItem(c echo.Context) (string, int32, error) {
+	itemType := c.Param("type")
+	if itemType != trashTypeDocuments && itemType != trashTypeChats {
+		return "", 0, echo.NewHTTPError(http.StatusBadRequest, "Trash type must be documents or chats")
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return "", 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	return itemType, int32(id), nil
+}
+
+// purgeDocument permanently deletes a document along with the files of all its versions, its
+// thumbnail and its previews, releasing the usage they count for. Files that cannot be deleted
+// are left for the reconciliation job to retry. It reports false when the document is no longer
+// in the trash, having been restored or purged meanwhile.
+func (hc *HandlerContext) purgeDocument(doc models.Document) (bool, error) {
+	versionFilePaths, err := hc.Queryer.GetDocumentVersionFilePaths(context.Background(), doc.ID)
+	if err != nil {
+		return false, err
+	}
+
+	purged := false
+	err = hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+		storedBytes, err := queries.GetDocumentStoredBytes(context.Background(), doc.ID)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := queries.DeleteTrashedDocument(context.Background(), doc.ID)
+		if err != nil {
+			return err
+		}
+		if deleted != 1 {
+			return nil
+		}
+		purged = true
+
+		return hc.addAccountUsage(queries, models.AddAccountUsageParams{
+			AccountID:     doc.AccountID,
+			DocumentCount: -1,
+			StoredBytes:   -storedBytes,
+		})
+	})
+	if err != nil || !purged {
+		return false, err
+	}
+
+	filePaths := map[string]bool{doc.FilePath.String: true}
+	for _, filePath := range versionFilePaths {
+		filePaths[filePath.String] = true
+	}
+	for filePath := range filePaths {
+		hc.deleteDocumentFile(filePath)
+	}
+	hc.deleteBlob(document.ThumbnailObjectName(doc.ID), false)
+	hc.deleteBlob(document.PreviewObjectPrefix(doc.ID), true)
+
+	return true, nil
+}
+
+// GetTrash lists the trashed documents and chats of the current user, most recently deleted first.
+func (hc *HandlerContext) GetTrash(c echo.Context) error {
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	offset, limit := getOffsetLimit(c)
+
+	documents, err := hc.Queryer.ListTrashedDocuments(context.Background(), models.ListTrashedDocumentsParams{
+		AccountID: account.ID,
+		Limit:     int32(limit),
+		Offset:    int32(offset),
+	})
+	if err != nil {
+		return err
+	}
+
+	trashedChats, err := hc.Queryer.ListTrashedChats(context.Background(), models.ListTrashedChatsParams{
+		AccountID: account.ID,
+		Limit:     int32(limit),
+		Offset:    int32(offset),
+	})
+	if err != nil {
+		return err
+	}
+	chats, err := chatsWithMessages(hc.Queryer, trashedChats)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"documents": documents,
+		"chats":     chats,
+	})
+}
+
+// RestoreTrashItem takes a document or a chat of the current user out of the trash.
+func (hc *HandlerContext) RestoreTrashItem(c echo.Context) error {
+	itemType, id, err := parseTrashItem(c)
+	if err != nil {
+		return err
+	}
+
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	var restored any
+	if itemType == trashTypeDocuments {
+		restored, err = hc.Queryer.RestoreDocument(
+			context.Background(),
+			models.RestoreDocumentParams{ID: id, AccountID: account.ID},
+		)
+	} else {
+		var restoredChat models.Chat
+		restoredChat, err = hc.Queryer.RestoreChat(
+			context.Background(),
+			models.RestoreChatParams{ID: id, AccountID: account.ID},
+		)
+		if err == nil {
+			restored, err = chatWithMessages(hc.Queryer, restoredChat)
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Item not found in trash")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, restored)
+}
+
+// DeleteTrashItem permanently deletes a document or a chat of the current user that is in the
+// trash, without waiting for the retention period.
+func (hc *HandlerContext) DeleteTrashItem(c echo.Context) error {
+	itemType, id, err := parseTrashItem(c)
+	if err != nil {
+		return err
+	}
+
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	if itemType == trashTypeChats {
+		err := hc.withTransaction(context.Background(), func(queries *models.Queries) error {
+			deleted, err := queries.DeleteTrashedChat(
+				context.Background(),
+				models.DeleteTrashedChatParams{ID: id, AccountID: account.ID},
+			)
+			if err != nil {
+				return err
+			}
+			if deleted == 0 {
+				return echo.NewHTTPError(http.StatusNotFound, "Item not found in trash")
+			}
+
+			return hc.addAccountUsage(queries, models.AddAccountUsageParams{AccountID: account.ID, ChatCount: -1})
+		})
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, echo.Map{})
+	}
+
+	trashedDocument, err := hc.Queryer.GetTrashedDocument(
+		context.Background(),
+		models.GetTrashedDocumentParams{ID: id, AccountID: account.ID},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Item not found in trash")
+	}
+	if err != nil {
+		return err
+	}
+
+	purged, err := hc.purgeDocument(trashedDocument)
+	if err != nil {
+		return err
+	}
+	if !purged {
+		return echo.NewHTTPError(http.StatusNotFound, "Item not found in trash")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{})
+}
+
+// PurgeTrash permanently deletes the documents and chats that have been in the trash for longer
+// than retention.
+func (hc *HandlerContext) PurgeTrash(retention time.Duration) error {
+	deletedBefore := time.Now().Add(-retention)
+
+	for {
+		documents, err := hc.Queryer.ListPurgeableDocuments(
+			context.Background(),
+			models.ListPurgeableDocumentsParams{DeletedBefore: deletedBefore, PageLimit: trashPurgeBatchSize},
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, doc := range documents {
+			if _, err := hc.purgeDocument(doc); err != nil {
+				return err
+			}
+		}
+
+		if len(documents) < trashPurgeBatchSize {
+			break
+		}
+	}
+
+	return hc.Queryer.PurgeTrashedChats(context.Background(), deletedBefore)
+}
+
+// RunTrashPurgeJob purges the trash right away and then periodically, until ctx is cancelled.
+func (hc *HandlerContext) RunTrashPurgeJob(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := hc.PurgeTrash(retention); err != nil {
+			log.Errorf("error purging trash: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RegisterTrashRoutes sets up the routes for listing, restoring and permanently deleting trashed
+// documents and chats.
+func RegisterTrashRoutes(e *echo.Echo, hc *HandlerContext) {
+	restricted := echojwt.JWT(hc.Secret)
+	trashGroup := e.Group("/trash")
+	trashGroup.GET("", hc.GetTrash, restricted)
+	trashGroup.POST("/:type/:id/restore", hc.RestoreTrashItem, restricted)
+	trashGroup.DELETE("/:type/:id", hc.DeleteTrashItem, restricted)
+}
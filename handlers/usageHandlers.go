@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"cloud-solutions-api/authentication"
+	"cloud-solutions-api/config"
+	"cloud-solutions-api/models"
+	"context"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// exceedsLimit reports whether value is over limit, a limit of zero meaning unlimited.
+func exceedsLimit(value int64, limit int64) bool {
+	return limit > 0 && value > limit
+}
+
+// accountUsageError returns the error for the first counter that grew by delta and is now over
+// its limit, or nil when the usage is within the limits.
+func accountUsageError(usage models.AccountUsage, delta models.AddAccountUsageParams, limits config.UsageLimits) error {
+	if delta.DocumentCount > 0 && exceedsLimit(int64(usage.DocumentCount), limits.MaxDocuments) {
+		return echo.NewHTTPError(http.StatusPaymentRequired, "Document limit reached")
+	}
+	if delta.StoredBytes > 0 && exceedsLimit(usage.StoredBytes, limits.MaxStorageBytes) {
+		return echo.NewHTTPError(http.StatusPaymentRequired, "Storage limit reached")
+	}
+	if delta.ChatCount > 0 && exceedsLimit(int64(usage.ChatCount), limits.MaxChats) {
+		return echo.NewHTTPError(http.StatusPaymentRequired, "Chat limit reached")
+	}
+	return nil
+}
+
+// addAccountUsage adds to the usage counters of an account and fails when a counter that grew is
+// over the limits of the plan, so the transaction it is part of is rolled back.
+func (hc *HandlerContext) addAccountUsage(queries *models.Queries, delta models.AddAccountUsageParams) error {
+	usage, err := queries.AddAccountUsage(context.Background(), delta)
+	if err != nil {
+		return err
+	}
+	return accountUsageError(usage, delta, hc.UsageLimits)
+}
+
+// addMessageUsage counts a message sent by an account today and fails when the daily limit of
+// the plan is exceeded.
+func (hc *HandlerContext) addMessageUsage(queries *models.Queries, accountID int32) error {
+	messageCount, err := queries.AddMessageUsage(context.Background(), accountID)
+	if err != nil {
+		return err
+	}
+
+	if exceedsLimit(int64(messageCount), hc.UsageLimits.MaxMessagesPerDay) {
+		return echo.NewHTTPError(http.StatusTooManyRequests, "Daily message limit reached")
+	}
+
+	return nil
+}
+
+// limitValue returns a limit for a response, where unlimited is null.
+func limitValue(limit int64) *int64 {
+	if limit <= 0 {
+		return nil
+	}
+	return &limit
+}
+
+// GetAccountUsage returns the usage of the current user along with the limits of the plan.
+func (hc *HandlerContext) GetAccountUsage(c echo.Context) error {
+	account, err := authentication.GetCurrentAccount(hc.Queryer, c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	usage, err := hc.Queryer.GetAccountUsage(context.Background(), account.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"usage": usage,
+		"limits": echo.Map{
+			"maxDocuments":      limitValue(hc.UsageLimits.MaxDocuments),
+			"maxStorageBytes":   limitValue(hc.UsageLimits.MaxStorageBytes),
+			"maxChats":          limitValue(hc.UsageLimits.MaxChats),
+			"maxMessagesPerDay": limitValue(hc.UsageLimits.MaxMessagesPerDay),
+		},
+	})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"cloud-solutions-api/config"
+	"cloud-solutions-api/models"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"testing"
+)
+
+func TestExceedsLimit(t *testing.T) {
+	tests := []struct {
+		value int64
+		limit int64
+		want  bool
+	}{
+		{value: 100, limit: 0, want: false},
+		{value: 9, limit: 10, want: false},
+		{value: 10, limit: 10, want: false},
+		{value: 11, limit: 10, want: true},
+		{value: 1, limit: -1, want: false},
+	}
+	for _, tt := range tests {
+		if got := exceedsLimit(tt.value, tt.limit); got != tt.want {
+			t.Errorf("exceedsLimit(%d, %d) = %v, want %v", tt.value, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestAccountUsageError(t *testing.T) {
+	limits := config.UsageLimits{MaxDocuments: 10, MaxStorageBytes: 1000, MaxChats: 5}
+	tests := []struct {
+		name    string
+		usage   models.AccountUsage
+		delta   models.AddAccountUsageParams
+		message string
+	}{
+		{
+			name:  "within limits",
+			usage: models.AccountUsage{DocumentCount: 10, StoredBytes: 1000, ChatCount: 5},
+			delta: models.AddAccountUsageParams{DocumentCount: 1, StoredBytes: 100, ChatCount: 1},
+		},
+		{
+			name:    "document over limit",
+			usage:   models.AccountUsage{DocumentCount: 11, StoredBytes: 100},
+			delta:   models.AddAccountUsageParams{DocumentCount: 1, StoredBytes: 100},
+			message: "Document limit reached",
+		},
+		{
+			name:    "storage over limit",
+			usage:   models.AccountUsage{DocumentCount: 3, StoredBytes: 1001},
+			delta:   models.AddAccountUsageParams{StoredBytes: 1},
+			message: "Storage limit reached",
+		},
+		{
+			name:    "chat over limit",
+			usage:   models.AccountUsage{ChatCount: 6},
+			delta:   models.AddAccountUsageParams{ChatCount: 1},
+			message: "Chat limit reached",
+		},
+		{
+			name:  "releasing usage while over limit",
+			usage: models.AccountUsage{DocumentCount: 20, StoredBytes: 5000, ChatCount: 9},
+			delta: models.AddAccountUsageParams{DocumentCount: -1, StoredBytes: -100, ChatCount: -1},
+		},
+		{
+			name:  "counter over limit that did not grow",
+			usage: models.AccountUsage{DocumentCount: 20, ChatCount: 1},
+			delta: models.AddAccountUsageParams{ChatCount: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := accountUsageError(tt.usage, tt.delta, limits)
+			if tt.message == "" {
+				if err != nil {
+					t.Fatalf("accountUsageError() = %v, want nil", err)
+				}
+				return
+			}
+			var httpError *echo.HTTPError
+			if !errors.As(err, &httpError) {
+				t.Fatalf("accountUsageError() = %v, want an HTTP error", err)
+			}
+			if httpError.Code != http.StatusPaymentRequired || httpError.Message != tt.message {
+				t.Errorf("accountUsageError() = %d %v, want %d %s", httpError.Code, httpError.Message, http.StatusPaymentRequired, tt.message)
+			}
+		})
+	}
+
+	if err := accountUsageError(models.AccountUsage{DocumentCount: 1 << 30}, models.AddAccountUsageParams{DocumentCount: 1}, config.UsageLimits{}); err != nil {
+		t.Errorf("accountUsageError() with no limits = %v, want nil", err)
+	}
+}
@@ -3,12 +3,15 @@ package main
 import (
 	"cloud-solutions-api/config"
 	"cloud-solutions-api/handlers"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	_ "github.com/lib/pq" // Importing the driver anonymously
 	"net/http"
+	"os"
+	"slices"
 )
 
 func customHTTPErrorHandler(err error, c echo.Context) {
@@ -34,10 +37,10 @@ func customHTTPErrorHandler(err error, c echo.Context) {
 func main() {
 	// Create a new Echo instance
 	e := echo.New()
-	e.Use(middleware.CORS())
 	e.HTTPErrorHandler = customHTTPErrorHandler
 
 	configuration := config.GetConfig()
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{AllowOrigins: configuration.AllowedOrigins}))
 
 	handlerContext := handlers.NewHandlerContext(*configuration)
 	defer func() {
@@ -47,16 +50,65 @@ func main() {
 		}
 	}()
 
+	// `reconcile-blobs` lists the orphaned uploads and exits. With `--delete` it deletes them and
+	// retries the failed deletions as well.
+	if len(os.Args) > 1 && os.Args[1] == "reconcile-blobs" {
+		deleteOrphans := slices.Contains(os.Args[2:], "--delete")
+		report, err := handlerContext.ReconcileBlobs(handlers.BlobReconciliationOptions{
+			GracePeriod:           configuration.BlobOrphanGracePeriod,
+			DeleteOrphans:         deleteOrphans,
+			RetryPendingDeletions: deleteOrphans,
+		})
+		for _, orphan := range report.Orphans {
+			fmt.Println(orphan)
+		}
+		fmt.Printf(
+			"blob reconciliation: %d orphaned uploads, %d deleted, %d pending deletions retried, %d failed again\n",
+			len(report.Orphans),
+			report.DeletedOrphans,
+			report.RetriedDeletions,
+			report.FailedDeletions,
+		)
+		if err != nil {
+			e.Logger.Errorf("error reconciling blobs: %s", err)
+		}
+		return
+	}
+
+	// `rotate-keys` wraps the data keys of documents again with the current master key and exits
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		report, err := handlerContext.RotateDataKeys()
+		fmt.Printf("data key rotation: %d rotated, %d failed\n", report.Rotated, report.Failed)
+		if err != nil {
+			e.Logger.Errorf("error rotating data keys: %s", err)
+		}
+		return
+	}
+
 	// Middleware
-	e.Use(middleware.Logger())  // Logs all HTTP requests
-	e.Use(middleware.Recover()) // Recovers from panics
+	e.Use(handlers.RequestLogger()) // Logs all HTTP requests
+	e.Use(middleware.Recover())     // Recovers from panics
 
 	// Routes
 	handlers.RegisterAccountRoutes(e, handlerContext)
 	handlers.RegisterDocumentRoutes(e, handlerContext)
 	handlers.RegisterChatRoutes(e, handlerContext)
+	handlers.RegisterCollectionRoutes(e, handlerContext)
+	handlers.RegisterShareLinkRoutes(e, handlerContext)
+	handlers.RegisterTrashRoutes(e, handlerContext)
+	handlers.RegisterInternalRoutes(e, handlerContext)
 	e.GET("/health", handlerContext.HealthCheck)
 
+	// Background jobs
+	jobContext, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go handlerContext.RunTrashPurgeJob(jobContext, configuration.TrashRetention)
+	go handlerContext.RunBlobReconciliationJob(jobContext, handlers.BlobReconciliationOptions{
+		GracePeriod:           configuration.BlobOrphanGracePeriod,
+		DeleteOrphans:         configuration.BlobOrphanDelete,
+		RetryPendingDeletions: true,
+	})
+
 	// Start server
 	port := fmt.Sprintf(":%s", configuration.Port)
 	e.Logger.Info("Server is running on port " + port)
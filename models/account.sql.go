@@ -72,6 +72,26 @@ func (q *Queries) GetAccountByUsername(ctx context.Context, username string) (Ac
 	return i, err
 }
 
+const getAccountByUsernameOrEmail = `-- name: GetAccountByUsernameOrEmail :one
+SELECT id, created_at, username, email, password_hash
+FROM accounts
+WHERE username = $1
+   OR email = $1
+`
+
+func (q *Queries) GetAccountByUsernameOrEmail(ctx context.Context, username string) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getAccountByUsernameOrEmail, username)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+	)
+	return i, err
+}
+
 const getAccountPasswordHashByUsername = `-- name: GetAccountPasswordHashByUsername :one
 SELECT id, password_hash
 FROM accounts
@@ -8,16 +8,18 @@ package models
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"time"
 
-	"github.com/sqlc-dev/pqtype"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const accountOwnsChat = `-- name: AccountOwnsChat :one
 SELECT EXISTS(SELECT 1
               FROM chats
               WHERE account_id = $1
-                AND id = $2)
+                AND id = $2
+                AND deleted_at IS NULL)
 `
 
 type AccountOwnsChatParams struct {
@@ -32,51 +34,34 @@ func (q *Queries) AccountOwnsChat(ctx context.Context, arg AccountOwnsChatParams
 	return exists, err
 }
 
-const addMessageToChat = `-- name: AddMessageToChat :one
-UPDATE chats
-SET messages = messages || $1::jsonb
-WHERE id = $2
-    RETURNING id, created_at, messages, account_id, unread_messages
-`
-
-type AddMessageToChatParams struct {
-	Newmessage json.RawMessage `json:"newmessage"`
-	Chatid     int32           `json:"chatid"`
-}
-
-func (q *Queries) AddMessageToChat(ctx context.Context, arg AddMessageToChatParams) (Chat, error) {
-	row := q.db.QueryRowContext(ctx, addMessageToChat, arg.Newmessage, arg.Chatid)
-	var i Chat
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.Messages,
-		&i.AccountID,
-		&i.UnreadMessages,
-	)
-	return i, err
-}
-
 const createChat = `-- name: CreateChat :one
-INSERT INTO chats (messages, account_id)
-VALUES ($1, $2) RETURNING id, created_at, messages, account_id, unread_messages
+INSERT INTO chats (account_id, collection_id)
+VALUES ($1, $2) RETURNING id, created_at, account_id, unread_messages, collection_id, deleted_at, last_message_sequence, active_message_id, title, title_edited, pinned, archived, updated_at
 `
 
 type CreateChatParams struct {
-	Messages  pqtype.NullRawMessage `json:"messages"`
-	AccountID int32                 `json:"accountId"`
+	AccountID    int32         `json:"accountId"`
+	CollectionID sql.NullInt32 `json:"collectionId"`
 }
 
 // Create a new chat
 func (q *Queries) CreateChat(ctx context.Context, arg CreateChatParams) (Chat, error) {
-	row := q.db.QueryRowContext(ctx, createChat, arg.Messages, arg.AccountID)
+	row := q.db.QueryRowContext(ctx, createChat, arg.AccountID, arg.CollectionID)
 	var i Chat
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
-		&i.Messages,
 		&i.AccountID,
 		&i.UnreadMessages,
+		&i.CollectionID,
+		&i.DeletedAt,
+		&i.LastMessageSequence,
+		&i.ActiveMessageID,
+		&i.Title,
+		&i.TitleEdited,
+		&i.Pinned,
+		&i.Archived,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
@@ -93,10 +78,32 @@ func (q *Queries) DeleteChat(ctx context.Context, id int32) error {
 	return err
 }
 
+const deleteTrashedChat = `-- name: DeleteTrashedChat :execrows
+DELETE
+FROM chats
+WHERE id = $1
+  AND account_id = $2
+  AND deleted_at IS NOT NULL
+`
+
+type DeleteTrashedChatParams struct {
+	ID        int32 `json:"id"`
+	AccountID int32 `json:"accountId"`
+}
+
+func (q *Queries) DeleteTrashedChat(ctx context.Context, arg DeleteTrashedChatParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteTrashedChat, arg.ID, arg.AccountID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getChatByID = `-- name: GetChatByID :one
-SELECT id, created_at, messages, account_id, unread_messages
+SELECT id, created_at, account_id, unread_messages, collection_id, deleted_at, last_message_sequence, active_message_id, title, title_edited, pinned, archived, updated_at
 FROM chats
 WHERE id = $1
+  AND deleted_at IS NULL
 `
 
 // Get chat by ID
@@ -106,17 +113,56 @@ func (q *Queries) GetChatByID(ctx context.Context, id int32) (Chat, error) {
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
-		&i.Messages,
 		&i.AccountID,
 		&i.UnreadMessages,
+		&i.CollectionID,
+		&i.DeletedAt,
+		&i.LastMessageSequence,
+		&i.ActiveMessageID,
+		&i.Title,
+		&i.TitleEdited,
+		&i.Pinned,
+		&i.Archived,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getChatForUpdate = `-- name: GetChatForUpdate :one
+SELECT id, created_at, account_id, unread_messages, collection_id, deleted_at, last_message_sequence, active_message_id, title, title_edited, pinned, archived, updated_at
+FROM chats
+WHERE id = $1
+  AND deleted_at IS NULL
+    FOR UPDATE
+`
+
+// Get a chat and lock it until the end of the transaction
+func (q *Queries) GetChatForUpdate(ctx context.Context, id int32) (Chat, error) {
+	row := q.db.QueryRowContext(ctx, getChatForUpdate, id)
+	var i Chat
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.AccountID,
+		&i.UnreadMessages,
+		&i.CollectionID,
+		&i.DeletedAt,
+		&i.LastMessageSequence,
+		&i.ActiveMessageID,
+		&i.Title,
+		&i.TitleEdited,
+		&i.Pinned,
+		&i.Archived,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
 
 const getChatsByAccountID = `-- name: GetChatsByAccountID :many
-SELECT id, created_at, messages, account_id, unread_messages
+SELECT id, created_at, account_id, unread_messages, collection_id, deleted_at, last_message_sequence, active_message_id, title, title_edited, pinned, archived, updated_at
 FROM chats
 WHERE account_id = $1
+  AND deleted_at IS NULL
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3
 `
@@ -139,9 +185,17 @@ func (q *Queries) GetChatsByAccountID(ctx context.Context, arg GetChatsByAccount
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
-			&i.Messages,
 			&i.AccountID,
 			&i.UnreadMessages,
+			&i.CollectionID,
+			&i.DeletedAt,
+			&i.LastMessageSequence,
+			&i.ActiveMessageID,
+			&i.Title,
+			&i.TitleEdited,
+			&i.Pinned,
+			&i.Archived,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -156,6 +210,43 @@ func (q *Queries) GetChatsByAccountID(ctx context.Context, arg GetChatsByAccount
 	return items, nil
 }
 
+const getOwnedChatIDs = `-- name: GetOwnedChatIDs :many
+SELECT id
+FROM chats
+WHERE account_id = $1
+  AND id = ANY ($2::INTEGER[])
+  AND deleted_at IS NULL
+`
+
+type GetOwnedChatIDsParams struct {
+	AccountID int32   `json:"accountId"`
+	ChatIds   []int32 `json:"chatIds"`
+}
+
+// The given chats an account owns
+func (q *Queries) GetOwnedChatIDs(ctx context.Context, arg GetOwnedChatIDsParams) ([]int32, error) {
+	rows, err := q.db.QueryContext(ctx, getOwnedChatIDs, arg.AccountID, pq.Array(arg.ChatIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int32{}
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const isUnread = `-- name: IsUnread :one
 SELECT unread_messages
 FROM chats
@@ -170,9 +261,10 @@ func (q *Queries) IsUnread(ctx context.Context, id int32) (sql.NullBool, error)
 }
 
 const listChatsByAccountID = `-- name: ListChatsByAccountID :many
-SELECT id, created_at, messages, account_id, unread_messages
+SELECT id, created_at, account_id, unread_messages, collection_id, deleted_at, last_message_sequence, active_message_id, title, title_edited, pinned, archived, updated_at
 FROM chats
 WHERE account_id = $1
+  AND deleted_at IS NULL
 ORDER BY created_at DESC
 `
 
@@ -189,9 +281,161 @@ func (q *Queries) ListChatsByAccountID(ctx context.Context, accountID int32) ([]
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
-			&i.Messages,
 			&i.AccountID,
 			&i.UnreadMessages,
+			&i.CollectionID,
+			&i.DeletedAt,
+			&i.LastMessageSequence,
+			&i.ActiveMessageID,
+			&i.Title,
+			&i.TitleEdited,
+			&i.Pinned,
+			&i.Archived,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChatSummaries = `-- name: ListChatSummaries :many
+SELECT chats.id,
+       chats.created_at,
+       chats.updated_at,
+       chats.collection_id,
+       COALESCE(chats.unread_messages, false)::BOOLEAN AS unread,
+       chats.title,
+       chats.pinned,
+       chats.archived,
+       COALESCE(LEFT(last_message.text, 200), '')::TEXT AS last_message_preview,
+       (WITH RECURSIVE path AS (SELECT id, parent_id
+                                FROM chat_messages
+                                WHERE id = chats.active_message_id
+                                UNION ALL
+                                SELECT parent.id, parent.parent_id
+                                FROM chat_messages parent
+                                         JOIN path ON path.parent_id = parent.id)
+        SELECT COUNT(*)
+        FROM path)::INTEGER AS message_count
+FROM chats
+         LEFT JOIN chat_messages last_message ON last_message.id = chats.active_message_id
+WHERE chats.account_id = $1
+  AND chats.deleted_at IS NULL
+  AND chats.archived = $2
+  AND ($3::BOOLEAN IS NULL OR chats.pinned = $3::BOOLEAN)
+ORDER BY chats.pinned DESC, chats.updated_at DESC, chats.id DESC
+LIMIT $4 OFFSET $5
+`
+
+type ListChatSummariesParams struct {
+	AccountID  int32        `json:"accountId"`
+	Archived   bool         `json:"archived"`
+	Pinned     sql.NullBool `json:"pinned"`
+	PageLimit  int32        `json:"pageLimit"`
+	PageOffset int32        `json:"pageOffset"`
+}
+
+type ListChatSummariesRow struct {
+	ID                 int32         `json:"id"`
+	CreatedAt          sql.NullTime  `json:"createdAt"`
+	UpdatedAt          time.Time     `json:"updatedAt"`
+	CollectionID       sql.NullInt32 `json:"collectionId"`
+	Unread             bool          `json:"unread"`
+	Title              string        `json:"title"`
+	Pinned             bool          `json:"pinned"`
+	Archived           bool          `json:"archived"`
+	LastMessagePreview string        `json:"lastMessagePreview"`
+	MessageCount       int32         `json:"messageCount"`
+}
+
+// Summaries of the chats of an account, the pinned chats first and then the chats with the latest
+// activity. Archived chats are only listed when asked for
+func (q *Queries) ListChatSummaries(ctx context.Context, arg ListChatSummariesParams) ([]ListChatSummariesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listChatSummaries,
+		arg.AccountID,
+		arg.Archived,
+		arg.Pinned,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListChatSummariesRow{}
+	for rows.Next() {
+		var i ListChatSummariesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.CollectionID,
+			&i.Unread,
+			&i.Title,
+			&i.Pinned,
+			&i.Archived,
+			&i.LastMessagePreview,
+			&i.MessageCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrashedChats = `-- name: ListTrashedChats :many
+SELECT id, created_at, account_id, unread_messages, collection_id, deleted_at, last_message_sequence, active_message_id, title, title_edited, pinned, archived, updated_at
+FROM chats
+WHERE account_id = $1
+  AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListTrashedChatsParams struct {
+	AccountID int32 `json:"accountId"`
+	Limit     int32 `json:"limit"`
+	Offset    int32 `json:"offset"`
+}
+
+func (q *Queries) ListTrashedChats(ctx context.Context, arg ListTrashedChatsParams) ([]Chat, error) {
+	rows, err := q.db.QueryContext(ctx, listTrashedChats, arg.AccountID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Chat{}
+	for rows.Next() {
+		var i Chat
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.AccountID,
+			&i.UnreadMessages,
+			&i.CollectionID,
+			&i.DeletedAt,
+			&i.LastMessageSequence,
+			&i.ActiveMessageID,
+			&i.Title,
+			&i.TitleEdited,
+			&i.Pinned,
+			&i.Archived,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -217,27 +461,256 @@ func (q *Queries) MarkAsReadByID(ctx context.Context, id int32) error {
 	return err
 }
 
-const updateChatMessages = `-- name: UpdateChatMessages :one
+const markAsUnreadByID = `-- name: MarkAsUnreadByID :exec
 UPDATE chats
-SET messages = $1
-WHERE id = $2 RETURNING id, created_at, messages, account_id, unread_messages
+SET unread_messages= true
+WHERE id = $1
+`
+
+func (q *Queries) MarkAsUnreadByID(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, markAsUnreadByID, id)
+	return err
+}
+
+const notifyChatEvent = `-- name: NotifyChatEvent :exec
+SELECT pg_notify('chat_events', $1::TEXT)
+`
+
+// Publish a chat event to every replica, once the transaction commits when run in one
+func (q *Queries) NotifyChatEvent(ctx context.Context, payload string) error {
+	_, err := q.db.ExecContext(ctx, notifyChatEvent, payload)
+	return err
+}
+
+const purgeTrashedChats = `-- name: PurgeTrashedChats :exec
+WITH purged AS (DELETE FROM chats WHERE deleted_at < $1::TIMESTAMP RETURNING account_id)
+UPDATE account_usage
+SET chat_count = account_usage.chat_count - purged_counts.chat_count
+FROM (SELECT account_id, COUNT(*) AS chat_count FROM purged GROUP BY account_id) purged_counts
+WHERE account_usage.account_id = purged_counts.account_id
+`
+
+// Permanently delete the chats that have been in the trash since before the given time, releasing their usage
+func (q *Queries) PurgeTrashedChats(ctx context.Context, deletedBefore time.Time) error {
+	_, err := q.db.ExecContext(ctx, purgeTrashedChats, deletedBefore)
+	return err
+}
+
+const recordChatReply = `-- name: RecordChatReply :execrows
+INSERT INTO chat_replies (reply_id, chat_id)
+VALUES ($1, $2)
+ON CONFLICT (chat_id, reply_id) DO NOTHING
+`
+
+type RecordChatReplyParams struct {
+	ReplyID string `json:"replyId"`
+	ChatID  int32  `json:"chatId"`
+}
+
+// Record a reply of the assistant, affecting no row when it was already recorded
+func (q *Queries) RecordChatReply(ctx context.Context, arg RecordChatReplyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, recordChatReply, arg.ReplyID, arg.ChatID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChat = `-- name: RestoreChat :one
+UPDATE chats
+SET deleted_at = NULL
+WHERE id = $1
+  AND account_id = $2
+  AND deleted_at IS NOT NULL
+    RETURNING id, created_at, account_id, unread_messages, collection_id, deleted_at, last_message_sequence, active_message_id, title, title_edited, pinned, archived, updated_at
+`
+
+type RestoreChatParams struct {
+	ID        int32 `json:"id"`
+	AccountID int32 `json:"accountId"`
+}
+
+func (q *Queries) RestoreChat(ctx context.Context, arg RestoreChatParams) (Chat, error) {
+	row := q.db.QueryRowContext(ctx, restoreChat, arg.ID, arg.AccountID)
+	var i Chat
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.AccountID,
+		&i.UnreadMessages,
+		&i.CollectionID,
+		&i.DeletedAt,
+		&i.LastMessageSequence,
+		&i.ActiveMessageID,
+		&i.Title,
+		&i.TitleEdited,
+		&i.Pinned,
+		&i.Archived,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const setChatActiveMessage = `-- name: SetChatActiveMessage :exec
+UPDATE chats
+SET active_message_id = $1
+WHERE id = $2
 `
 
-type UpdateChatMessagesParams struct {
-	Messages pqtype.NullRawMessage `json:"messages"`
-	ID       int32                 `json:"id"`
+type SetChatActiveMessageParams struct {
+	ActiveMessageID uuid.NullUUID `json:"activeMessageId"`
+	ID              int32         `json:"id"`
 }
 
-// Update chat's messages
-func (q *Queries) UpdateChatMessages(ctx context.Context, arg UpdateChatMessagesParams) (Chat, error) {
-	row := q.db.QueryRowContext(ctx, updateChatMessages, arg.Messages, arg.ID)
+func (q *Queries) SetChatActiveMessage(ctx context.Context, arg SetChatActiveMessageParams) error {
+	_, err := q.db.ExecContext(ctx, setChatActiveMessage, arg.ActiveMessageID, arg.ID)
+	return err
+}
+
+const setChatTitleIfEmpty = `-- name: SetChatTitleIfEmpty :execrows
+UPDATE chats
+SET title = $1
+WHERE id = $2
+  AND title = ''
+`
+
+type SetChatTitleIfEmptyParams struct {
+	Title string `json:"title"`
+	ID    int32  `json:"id"`
+}
+
+// Give a title to a chat that has none yet
+func (q *Queries) SetChatTitleIfEmpty(ctx context.Context, arg SetChatTitleIfEmptyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setChatTitleIfEmpty, arg.Title, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setChatTitleUnlessEdited = `-- name: SetChatTitleUnlessEdited :one
+UPDATE chats
+SET title = $1
+WHERE id = $2
+  AND NOT title_edited
+  AND deleted_at IS NULL
+    RETURNING id, created_at, account_id, unread_messages, collection_id, deleted_at, last_message_sequence, active_message_id, title, title_edited, pinned, archived, updated_at
+`
+
+type SetChatTitleUnlessEditedParams struct {
+	Title string `json:"title"`
+	ID    int32  `json:"id"`
+}
+
+// Replace the title of a chat, unless the user gave it one
+func (q *Queries) SetChatTitleUnlessEdited(ctx context.Context, arg SetChatTitleUnlessEditedParams) (Chat, error) {
+	row := q.db.QueryRowContext(ctx, setChatTitleUnlessEdited, arg.Title, arg.ID)
+	var i Chat
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.AccountID,
+		&i.UnreadMessages,
+		&i.CollectionID,
+		&i.DeletedAt,
+		&i.LastMessageSequence,
+		&i.ActiveMessageID,
+		&i.Title,
+		&i.TitleEdited,
+		&i.Pinned,
+		&i.Archived,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const trashChat = `-- name: TrashChat :exec
+UPDATE chats
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1
+  AND deleted_at IS NULL
+`
+
+// Move a chat to the trash, it stays there until it is restored or purged
+func (q *Queries) TrashChat(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, trashChat, id)
+	return err
+}
+
+const updateChatCollection = `-- name: UpdateChatCollection :one
+UPDATE chats
+SET collection_id = $1
+WHERE id = $2
+    RETURNING id, created_at, account_id, unread_messages, collection_id, deleted_at, last_message_sequence, active_message_id, title, title_edited, pinned, archived, updated_at
+`
+
+type UpdateChatCollectionParams struct {
+	CollectionID sql.NullInt32 `json:"collectionId"`
+	ID           int32         `json:"id"`
+}
+
+func (q *Queries) UpdateChatCollection(ctx context.Context, arg UpdateChatCollectionParams) (Chat, error) {
+	row := q.db.QueryRowContext(ctx, updateChatCollection, arg.CollectionID, arg.ID)
+	var i Chat
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.AccountID,
+		&i.UnreadMessages,
+		&i.CollectionID,
+		&i.DeletedAt,
+		&i.LastMessageSequence,
+		&i.ActiveMessageID,
+		&i.Title,
+		&i.TitleEdited,
+		&i.Pinned,
+		&i.Archived,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateChatDetails = `-- name: UpdateChatDetails :one
+UPDATE chats
+SET title        = COALESCE($1::TEXT, title),
+    title_edited = title_edited OR $1::TEXT IS NOT NULL,
+    pinned       = COALESCE($2::BOOLEAN, pinned),
+    archived     = COALESCE($3::BOOLEAN, archived)
+WHERE id = $4
+  AND deleted_at IS NULL
+    RETURNING id, created_at, account_id, unread_messages, collection_id, deleted_at, last_message_sequence, active_message_id, title, title_edited, pinned, archived, updated_at
+`
+
+type UpdateChatDetailsParams struct {
+	Title    sql.NullString `json:"title"`
+	Pinned   sql.NullBool   `json:"pinned"`
+	Archived sql.NullBool   `json:"archived"`
+	ID       int32          `json:"id"`
+}
+
+// Change the details of a chat given by the user, leaving those that are null unchanged
+func (q *Queries) UpdateChatDetails(ctx context.Context, arg UpdateChatDetailsParams) (Chat, error) {
+	row := q.db.QueryRowContext(ctx, updateChatDetails,
+		arg.Title,
+		arg.Pinned,
+		arg.Archived,
+		arg.ID,
+	)
 	var i Chat
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
-		&i.Messages,
 		&i.AccountID,
 		&i.UnreadMessages,
+		&i.CollectionID,
+		&i.DeletedAt,
+		&i.LastMessageSequence,
+		&i.ActiveMessageID,
+		&i.Title,
+		&i.TitleEdited,
+		&i.Pinned,
+		&i.Archived,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
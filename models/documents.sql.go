@@ -8,13 +8,19 @@ package models
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sqlc-dev/pqtype"
 )
 
 const accountOwnsDocument = `-- name: AccountOwnsDocument :one
 SELECT EXISTS(SELECT 1
               FROM documents
               WHERE account_id = $1
-                AND id = $2)
+                AND id = $2
+                AND deleted_at IS NULL)
 `
 
 type AccountOwnsDocumentParams struct {
@@ -30,17 +36,25 @@ func (q *Queries) AccountOwnsDocument(ctx context.Context, arg AccountOwnsDocume
 }
 
 const createDocument = `-- name: CreateDocument :one
-INSERT INTO documents (name, text, file_path, embedding, account_id)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, created_at, name, text, file_path, embedding, account_id
+INSERT INTO documents (name, text, file_path, embedding, account_id, page_count, source_metadata, file_name,
+                       size_bytes, content_hash, data_key, data_key_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
 `
 
 type CreateDocumentParams struct {
-	Name      string         `json:"name"`
-	Text      sql.NullString `json:"text"`
-	FilePath  sql.NullString `json:"filePath"`
-	Embedding interface{}    `json:"embedding"`
-	AccountID int32          `json:"accountId"`
+	Name           string                `json:"name"`
+	Text           sql.NullString        `json:"text"`
+	FilePath       sql.NullString        `json:"filePath"`
+	Embedding      interface{}           `json:"embedding"`
+	AccountID      int32                 `json:"accountId"`
+	PageCount      sql.NullInt32         `json:"pageCount"`
+	SourceMetadata pqtype.NullRawMessage `json:"sourceMetadata"`
+	FileName       string                `json:"fileName"`
+	SizeBytes      sql.NullInt64         `json:"sizeBytes"`
+	ContentHash    sql.NullString        `json:"contentHash"`
+	DataKey        []byte                `json:"-"`
+	DataKeyID      sql.NullString        `json:"-"`
 }
 
 // Create a new document
@@ -51,6 +65,13 @@ func (q *Queries) CreateDocument(ctx context.Context, arg CreateDocumentParams)
 		arg.FilePath,
 		arg.Embedding,
 		arg.AccountID,
+		arg.PageCount,
+		arg.SourceMetadata,
+		arg.FileName,
+		arg.SizeBytes,
+		arg.ContentHash,
+		arg.DataKey,
+		arg.DataKeyID,
 	)
 	var i Document
 	err := row.Scan(
@@ -61,10 +82,38 @@ func (q *Queries) CreateDocument(ctx context.Context, arg CreateDocumentParams)
 		&i.FilePath,
 		&i.Embedding,
 		&i.AccountID,
+		&i.PageCount,
+		&i.SourceMetadata,
+		&i.ThumbnailPath,
+		&i.Description,
+		&i.FileName,
+		&i.SizeBytes,
+		&i.ContentHash,
+		&i.CurrentVersion,
+		&i.Metadata,
+		&i.DeletedAt,
+		&i.DataKey,
+		&i.DataKeyID,
 	)
 	return i, err
 }
 
+const createDocumentPage = `-- name: CreateDocumentPage :exec
+INSERT INTO document_pages (document_id, page_number, text)
+VALUES ($1, $2, $3)
+`
+
+type CreateDocumentPageParams struct {
+	DocumentID int32  `json:"documentId"`
+	PageNumber int32  `json:"pageNumber"`
+	Text       string `json:"text"`
+}
+
+func (q *Queries) CreateDocumentPage(ctx context.Context, arg CreateDocumentPageParams) error {
+	_, err := q.db.ExecContext(ctx, createDocumentPage, arg.DocumentID, arg.PageNumber, arg.Text)
+	return err
+}
+
 const deleteDocument = `-- name: DeleteDocument :exec
 DELETE
 FROM documents
@@ -77,10 +126,38 @@ func (q *Queries) DeleteDocument(ctx context.Context, id int32) error {
 	return err
 }
 
+const deleteDocumentPages = `-- name: DeleteDocumentPages :exec
+DELETE
+FROM document_pages
+WHERE document_id = $1
+`
+
+func (q *Queries) DeleteDocumentPages(ctx context.Context, documentID int32) error {
+	_, err := q.db.ExecContext(ctx, deleteDocumentPages, documentID)
+	return err
+}
+
+const deleteTrashedDocument = `-- name: DeleteTrashedDocument :execrows
+DELETE
+FROM documents
+WHERE id = $1
+  AND deleted_at IS NOT NULL
+`
+
+// Permanently delete a document, unless it was restored or purged meanwhile
+func (q *Queries) DeleteTrashedDocument(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteTrashedDocument, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getDocumentByID = `-- name: GetDocumentByID :one
-SELECT id, created_at, name, text, file_path, embedding, account_id
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
 FROM documents
 WHERE id = $1
+  AND deleted_at IS NULL
 `
 
 // Get a document by ID
@@ -95,14 +172,120 @@ func (q *Queries) GetDocumentByID(ctx context.Context, id int32) (Document, erro
 		&i.FilePath,
 		&i.Embedding,
 		&i.AccountID,
+		&i.PageCount,
+		&i.SourceMetadata,
+		&i.ThumbnailPath,
+		&i.Description,
+		&i.FileName,
+		&i.SizeBytes,
+		&i.ContentHash,
+		&i.CurrentVersion,
+		&i.Metadata,
+		&i.DeletedAt,
+		&i.DataKey,
+		&i.DataKeyID,
 	)
 	return i, err
 }
 
+const getDocumentForUpdate = `-- name: GetDocumentForUpdate :one
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE id = $1
+  AND deleted_at IS NULL
+    FOR UPDATE
+`
+
+// Get a document and lock it until the end of the transaction
+func (q *Queries) GetDocumentForUpdate(ctx context.Context, id int32) (Document, error) {
+	row := q.db.QueryRowContext(ctx, getDocumentForUpdate, id)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Name,
+		&i.Text,
+		&i.FilePath,
+		&i.Embedding,
+		&i.AccountID,
+		&i.PageCount,
+		&i.SourceMetadata,
+		&i.ThumbnailPath,
+		&i.Description,
+		&i.FileName,
+		&i.SizeBytes,
+		&i.ContentHash,
+		&i.CurrentVersion,
+		&i.Metadata,
+		&i.DeletedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+	)
+	return i, err
+}
+
+const getDocumentPage = `-- name: GetDocumentPage :one
+SELECT document_id, page_number, text
+FROM document_pages
+WHERE document_id = $1
+  AND page_number = $2
+`
+
+type GetDocumentPageParams struct {
+	DocumentID int32 `json:"documentId"`
+	PageNumber int32 `json:"pageNumber"`
+}
+
+func (q *Queries) GetDocumentPage(ctx context.Context, arg GetDocumentPageParams) (DocumentPage, error) {
+	row := q.db.QueryRowContext(ctx, getDocumentPage, arg.DocumentID, arg.PageNumber)
+	var i DocumentPage
+	err := row.Scan(&i.DocumentID, &i.PageNumber, &i.Text)
+	return i, err
+}
+
+const getDocumentPages = `-- name: GetDocumentPages :many
+SELECT document_id, page_number, text
+FROM document_pages
+WHERE document_id = $1
+ORDER BY page_number
+LIMIT $2 OFFSET $3
+`
+
+type GetDocumentPagesParams struct {
+	DocumentID int32 `json:"documentId"`
+	Limit      int32 `json:"limit"`
+	Offset     int32 `json:"offset"`
+}
+
+func (q *Queries) GetDocumentPages(ctx context.Context, arg GetDocumentPagesParams) ([]DocumentPage, error) {
+	rows, err := q.db.QueryContext(ctx, getDocumentPages, arg.DocumentID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []DocumentPage{}
+	for rows.Next() {
+		var i DocumentPage
+		if err := rows.Scan(&i.DocumentID, &i.PageNumber, &i.Text); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getDocumentsByAccountID = `-- name: GetDocumentsByAccountID :many
-SELECT id, created_at, name, text, file_path, embedding, account_id
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
 FROM documents
 WHERE account_id = $1
+  AND deleted_at IS NULL
+ORDER BY created_at DESC, id DESC
 LIMIT $2 OFFSET $3
 `
 
@@ -130,6 +313,238 @@ func (q *Queries) GetDocumentsByAccountID(ctx context.Context, arg GetDocumentsB
 			&i.FilePath,
 			&i.Embedding,
 			&i.AccountID,
+			&i.PageCount,
+			&i.SourceMetadata,
+			&i.ThumbnailPath,
+			&i.Description,
+			&i.FileName,
+			&i.SizeBytes,
+			&i.ContentHash,
+			&i.CurrentVersion,
+			&i.Metadata,
+			&i.DeletedAt,
+			&i.DataKey,
+			&i.DataKeyID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTrashedDocument = `-- name: GetTrashedDocument :one
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE id = $1
+  AND account_id = $2
+  AND deleted_at IS NOT NULL
+`
+
+type GetTrashedDocumentParams struct {
+	ID        int32 `json:"id"`
+	AccountID int32 `json:"accountId"`
+}
+
+func (q *Queries) GetTrashedDocument(ctx context.Context, arg GetTrashedDocumentParams) (Document, error) {
+	row := q.db.QueryRowContext(ctx, getTrashedDocument, arg.ID, arg.AccountID)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Name,
+		&i.Text,
+		&i.FilePath,
+		&i.Embedding,
+		&i.AccountID,
+		&i.PageCount,
+		&i.SourceMetadata,
+		&i.ThumbnailPath,
+		&i.Description,
+		&i.FileName,
+		&i.SizeBytes,
+		&i.ContentHash,
+		&i.CurrentVersion,
+		&i.Metadata,
+		&i.DeletedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+	)
+	return i, err
+}
+
+const listAccountDocuments = `-- name: ListAccountDocuments :many
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id,
+       COALESCE((SELECT array_agg(document_tags.tag ORDER BY document_tags.tag)
+                 FROM document_tags
+                 WHERE document_tags.document_id = documents.id), '{}')::TEXT[] AS tags
+FROM documents
+WHERE account_id = $1
+  AND deleted_at IS NULL
+  AND (cardinality($2::TEXT[]) = 0
+    OR id IN (SELECT document_tags.document_id
+              FROM document_tags
+              WHERE document_tags.tag = ANY ($2::TEXT[])
+              GROUP BY document_tags.document_id
+              HAVING COUNT(*) = cardinality($2::TEXT[])))
+  AND strpos(lower(name), lower($3::TEXT)) > 0
+  AND ($4::TEXT = '' OR right(lower(file_name), length($4::TEXT)) = lower($4::TEXT))
+  AND ($5::TIMESTAMP IS NULL OR created_at >= $5::TIMESTAMP)
+  AND ($6::TIMESTAMP IS NULL OR created_at < $6::TIMESTAMP)
+  AND ($7::TEXT = ''
+    OR ($7::TEXT = 'indexed' AND embedding IS NOT NULL)
+    OR ($7::TEXT = 'pending' AND embedding IS NULL))
+  AND metadata @> $8::JSONB
+ORDER BY CASE WHEN $9::TEXT = 'name' AND NOT $10::BOOLEAN THEN lower(name) END,
+         CASE WHEN $9::TEXT = 'name' AND $10::BOOLEAN THEN lower(name) END DESC,
+         CASE WHEN $9::TEXT = 'size' AND NOT $10::BOOLEAN THEN size_bytes END,
+         CASE WHEN $9::TEXT = 'size' AND $10::BOOLEAN THEN size_bytes END DESC NULLS LAST,
+         CASE WHEN $9::TEXT = 'created_at' AND NOT $10::BOOLEAN THEN created_at END,
+         CASE WHEN $9::TEXT = 'created_at' AND $10::BOOLEAN THEN created_at END DESC,
+         CASE WHEN NOT $10::BOOLEAN THEN id END,
+         CASE WHEN $10::BOOLEAN THEN id END DESC
+LIMIT $11 OFFSET $12
+`
+
+type ListAccountDocumentsParams struct {
+	AccountID      int32           `json:"accountId"`
+	Tags           []string        `json:"tags"`
+	NameContains   string          `json:"nameContains"`
+	Extension      string          `json:"extension"`
+	CreatedAfter   sql.NullTime    `json:"createdAfter"`
+	CreatedBefore  sql.NullTime    `json:"createdBefore"`
+	Status         string          `json:"status"`
+	MetadataFilter json.RawMessage `json:"metadataFilter"`
+	SortBy         string          `json:"sortBy"`
+	Descending     bool            `json:"descending"`
+	PageLimit      int32           `json:"pageLimit"`
+	PageOffset     int32           `json:"pageOffset"`
+}
+
+type ListAccountDocumentsRow struct {
+	ID             int32                 `json:"id"`
+	CreatedAt      sql.NullTime          `json:"createdAt"`
+	Name           string                `json:"name"`
+	Text           sql.NullString        `json:"text"`
+	FilePath       sql.NullString        `json:"filePath"`
+	Embedding      interface{}           `json:"embedding"`
+	AccountID      int32                 `json:"accountId"`
+	PageCount      sql.NullInt32         `json:"pageCount"`
+	SourceMetadata pqtype.NullRawMessage `json:"sourceMetadata"`
+	ThumbnailPath  sql.NullString        `json:"thumbnailPath"`
+	Description    sql.NullString        `json:"description"`
+	FileName       string                `json:"fileName"`
+	SizeBytes      sql.NullInt64         `json:"sizeBytes"`
+	ContentHash    sql.NullString        `json:"contentHash"`
+	CurrentVersion int32                 `json:"currentVersion"`
+	Metadata       json.RawMessage       `json:"metadata"`
+	DeletedAt      sql.NullTime          `json:"deletedAt"`
+	DataKey        []byte                `json:"-"`
+	DataKeyID      sql.NullString        `json:"-"`
+	Tags           []string              `json:"tags"`
+}
+
+// Documents of an account matching every given filter, in a stable order so pages do not overlap
+func (q *Queries) ListAccountDocuments(ctx context.Context, arg ListAccountDocumentsParams) ([]ListAccountDocumentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountDocuments,
+		arg.AccountID,
+		pq.Array(arg.Tags),
+		arg.NameContains,
+		arg.Extension,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.Status,
+		arg.MetadataFilter,
+		arg.SortBy,
+		arg.Descending,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAccountDocumentsRow{}
+	for rows.Next() {
+		var i ListAccountDocumentsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Name,
+			&i.Text,
+			&i.FilePath,
+			&i.Embedding,
+			&i.AccountID,
+			&i.PageCount,
+			&i.SourceMetadata,
+			&i.ThumbnailPath,
+			&i.Description,
+			&i.FileName,
+			&i.SizeBytes,
+			&i.ContentHash,
+			&i.CurrentVersion,
+			&i.Metadata,
+			&i.DeletedAt,
+			&i.DataKey,
+			&i.DataKeyID,
+			pq.Array(&i.Tags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDocumentDataKeysToRotate = `-- name: ListDocumentDataKeysToRotate :many
+SELECT id, data_key, data_key_id
+FROM documents
+WHERE data_key IS NOT NULL
+  AND data_key_id <> $1::TEXT
+  AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListDocumentDataKeysToRotateParams struct {
+	CurrentKeyID string `json:"currentKeyId"`
+	AfterID      int32  `json:"afterId"`
+	PageLimit    int32  `json:"pageLimit"`
+}
+
+type ListDocumentDataKeysToRotateRow struct {
+	ID        int32          `json:"id"`
+	DataKey   []byte         `json:"-"`
+	DataKeyID sql.NullString `json:"-"`
+}
+
+// Data keys of documents, trashed or not, wrapped by another master key than the given one, in ID
+// order after the given document
+func (q *Queries) ListDocumentDataKeysToRotate(ctx context.Context, arg ListDocumentDataKeysToRotateParams) ([]ListDocumentDataKeysToRotateRow, error) {
+	rows, err := q.db.QueryContext(ctx, listDocumentDataKeysToRotate, arg.CurrentKeyID, arg.AfterID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListDocumentDataKeysToRotateRow{}
+	for rows.Next() {
+		var i ListDocumentDataKeysToRotateRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.DataKey,
+			&i.DataKeyID,
 		); err != nil {
 			return nil, err
 		}
@@ -143,3 +558,485 @@ func (q *Queries) GetDocumentsByAccountID(ctx context.Context, arg GetDocumentsB
 	}
 	return items, nil
 }
+
+const listInaccessibleDocumentIDs = `-- name: ListInaccessibleDocumentIDs :many
+SELECT requested.id
+FROM unnest($1::INTEGER[]) AS requested(id)
+WHERE NOT EXISTS(SELECT 1
+                 FROM documents
+                 WHERE documents.id = requested.id
+                   AND documents.deleted_at IS NULL
+                   AND (documents.account_id = $2
+                     OR documents.id IN (SELECT document_shares.document_id
+                                         FROM document_shares
+                                         WHERE document_shares.account_id = $2)))
+ORDER BY requested.id
+`
+
+type ListInaccessibleDocumentIDsParams struct {
+	DocumentIds []int32 `json:"documentIds"`
+	AccountID   int32   `json:"accountId"`
+}
+
+// The given documents an account neither owns nor has been shared
+func (q *Queries) ListInaccessibleDocumentIDs(ctx context.Context, arg ListInaccessibleDocumentIDsParams) ([]int32, error) {
+	rows, err := q.db.QueryContext(ctx, listInaccessibleDocumentIDs, pq.Array(arg.DocumentIds), arg.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int32{}
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPurgeableDocuments = `-- name: ListPurgeableDocuments :many
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE deleted_at < $1::TIMESTAMP
+ORDER BY deleted_at, id
+LIMIT $2
+`
+
+type ListPurgeableDocumentsParams struct {
+	DeletedBefore time.Time `json:"deletedBefore"`
+	PageLimit     int32     `json:"pageLimit"`
+}
+
+// Documents that have been in the trash since before the given time, oldest first
+func (q *Queries) ListPurgeableDocuments(ctx context.Context, arg ListPurgeableDocumentsParams) ([]Document, error) {
+	rows, err := q.db.QueryContext(ctx, listPurgeableDocuments, arg.DeletedBefore, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Document{}
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Name,
+			&i.Text,
+			&i.FilePath,
+			&i.Embedding,
+			&i.AccountID,
+			&i.PageCount,
+			&i.SourceMetadata,
+			&i.ThumbnailPath,
+			&i.Description,
+			&i.FileName,
+			&i.SizeBytes,
+			&i.ContentHash,
+			&i.CurrentVersion,
+			&i.Metadata,
+			&i.DeletedAt,
+			&i.DataKey,
+			&i.DataKeyID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrashedDocuments = `-- name: ListTrashedDocuments :many
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE account_id = $1
+  AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id DESC
+LIMIT $2 OFFSET $3
+`
+
+type ListTrashedDocumentsParams struct {
+	AccountID int32 `json:"accountId"`
+	Limit     int32 `json:"limit"`
+	Offset    int32 `json:"offset"`
+}
+
+func (q *Queries) ListTrashedDocuments(ctx context.Context, arg ListTrashedDocumentsParams) ([]Document, error) {
+	rows, err := q.db.QueryContext(ctx, listTrashedDocuments, arg.AccountID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Document{}
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Name,
+			&i.Text,
+			&i.FilePath,
+			&i.Embedding,
+			&i.AccountID,
+			&i.PageCount,
+			&i.SourceMetadata,
+			&i.ThumbnailPath,
+			&i.Description,
+			&i.FileName,
+			&i.SizeBytes,
+			&i.ContentHash,
+			&i.CurrentVersion,
+			&i.Metadata,
+			&i.DeletedAt,
+			&i.DataKey,
+			&i.DataKeyID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreDocument = `-- name: RestoreDocument :one
+UPDATE documents
+SET deleted_at = NULL
+WHERE id = $1
+  AND account_id = $2
+  AND deleted_at IS NOT NULL
+RETURNING id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+`
+
+type RestoreDocumentParams struct {
+	ID        int32 `json:"id"`
+	AccountID int32 `json:"accountId"`
+}
+
+func (q *Queries) RestoreDocument(ctx context.Context, arg RestoreDocumentParams) (Document, error) {
+	row := q.db.QueryRowContext(ctx, restoreDocument, arg.ID, arg.AccountID)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Name,
+		&i.Text,
+		&i.FilePath,
+		&i.Embedding,
+		&i.AccountID,
+		&i.PageCount,
+		&i.SourceMetadata,
+		&i.ThumbnailPath,
+		&i.Description,
+		&i.FileName,
+		&i.SizeBytes,
+		&i.ContentHash,
+		&i.CurrentVersion,
+		&i.Metadata,
+		&i.DeletedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+	)
+	return i, err
+}
+
+const rewrapDocumentDataKey = `-- name: RewrapDocumentDataKey :execrows
+UPDATE documents
+SET data_key    = $1,
+    data_key_id = $2
+WHERE id = $3
+  AND data_key_id = $4::TEXT
+`
+
+type RewrapDocumentDataKeyParams struct {
+	DataKey       []byte         `json:"-"`
+	DataKeyID     sql.NullString `json:"-"`
+	ID            int32          `json:"id"`
+	PreviousKeyID string         `json:"previousKeyId"`
+}
+
+// Replace the data key of a document with the same key wrapped by another master key
+func (q *Queries) RewrapDocumentDataKey(ctx context.Context, arg RewrapDocumentDataKeyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rewrapDocumentDataKey,
+		arg.DataKey,
+		arg.DataKeyID,
+		arg.ID,
+		arg.PreviousKeyID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const searchDocuments = `-- name: SearchDocuments :many
+WITH RECURSIVE scope AS (SELECT collections.id
+                        FROM collections
+                        WHERE collections.id = $1::integer
+                        UNION
+                        SELECT collections.id
+                        FROM collections
+                                 JOIN scope ON collections.parent_id = scope.id
+                        WHERE $2::boolean)
+SELECT id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+FROM documents
+WHERE (account_id = $3
+    OR id IN (SELECT document_shares.document_id
+              FROM document_shares
+              WHERE document_shares.account_id = $3))
+  AND deleted_at IS NULL
+  AND to_tsvector('simple', name || ' ' || COALESCE(text, '')) @@ websearch_to_tsquery('simple', $4)
+  AND ($1::integer IS NULL
+    OR id IN (SELECT document_id
+              FROM collection_documents
+              WHERE collection_id IN (SELECT id FROM scope)))
+ORDER BY ts_rank(to_tsvector('simple', name || ' ' || COALESCE(text, '')), websearch_to_tsquery('simple', $4)) DESC,
+         id
+LIMIT $5 OFFSET $6
+`
+
+type SearchDocumentsParams struct {
+	CollectionID sql.NullInt32 `json:"collectionId"`
+	Recursive    bool          `json:"recursive"`
+	AccountID    int32         `json:"accountId"`
+	Query        string        `json:"query"`
+	PageLimit    int32         `json:"pageLimit"`
+	PageOffset   int32         `json:"pageOffset"`
+}
+
+// Full-text search over the name and text of the documents of an account and the ones shared with it, optionally
+// restricted to a collection and, when recursive, its sub-collections
+func (q *Queries) SearchDocuments(ctx context.Context, arg SearchDocumentsParams) ([]Document, error) {
+	rows, err := q.db.QueryContext(ctx, searchDocuments,
+		arg.CollectionID,
+		arg.Recursive,
+		arg.AccountID,
+		arg.Query,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Document{}
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Name,
+			&i.Text,
+			&i.FilePath,
+			&i.Embedding,
+			&i.AccountID,
+			&i.PageCount,
+			&i.SourceMetadata,
+			&i.ThumbnailPath,
+			&i.Description,
+			&i.FileName,
+			&i.SizeBytes,
+			&i.ContentHash,
+			&i.CurrentVersion,
+			&i.Metadata,
+			&i.DeletedAt,
+			&i.DataKey,
+			&i.DataKeyID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setDocumentDataKeyIfMissing = `-- name: SetDocumentDataKeyIfMissing :execrows
+UPDATE documents
+SET data_key    = $1,
+    data_key_id = $2
+WHERE id = $3
+  AND data_key IS NULL
+`
+
+type SetDocumentDataKeyIfMissingParams struct {
+	DataKey   []byte         `json:"-"`
+	DataKeyID sql.NullString `json:"-"`
+	ID        int32          `json:"id"`
+}
+
+// Store the data key of a document that has none yet, so that a concurrent upload cannot replace
+// the key its files are already encrypted with
+func (q *Queries) SetDocumentDataKeyIfMissing(ctx context.Context, arg SetDocumentDataKeyIfMissingParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setDocumentDataKeyIfMissing, arg.DataKey, arg.DataKeyID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setDocumentFile = `-- name: SetDocumentFile :one
+UPDATE documents
+SET file_name       = $1,
+    file_path       = $2,
+    text            = $3,
+    page_count      = $4,
+    source_metadata = $5,
+    size_bytes      = $6,
+    content_hash    = $7,
+    current_version = $8,
+    embedding       = NULL,
+    thumbnail_path  = NULL
+WHERE id = $9
+RETURNING id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+`
+
+type SetDocumentFileParams struct {
+	FileName       string                `json:"fileName"`
+	FilePath       sql.NullString        `json:"filePath"`
+	Text           sql.NullString        `json:"text"`
+	PageCount      sql.NullInt32         `json:"pageCount"`
+	SourceMetadata pqtype.NullRawMessage `json:"sourceMetadata"`
+	SizeBytes      sql.NullInt64         `json:"sizeBytes"`
+	ContentHash    sql.NullString        `json:"contentHash"`
+	CurrentVersion int32                 `json:"currentVersion"`
+	ID             int32                 `json:"id"`
+}
+
+// Point a document at a new file, clearing everything derived from the previous one
+func (q *Queries) SetDocumentFile(ctx context.Context, arg SetDocumentFileParams) (Document, error) {
+	row := q.db.QueryRowContext(ctx, setDocumentFile,
+		arg.FileName,
+		arg.FilePath,
+		arg.Text,
+		arg.PageCount,
+		arg.SourceMetadata,
+		arg.SizeBytes,
+		arg.ContentHash,
+		arg.CurrentVersion,
+		arg.ID,
+	)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Name,
+		&i.Text,
+		&i.FilePath,
+		&i.Embedding,
+		&i.AccountID,
+		&i.PageCount,
+		&i.SourceMetadata,
+		&i.ThumbnailPath,
+		&i.Description,
+		&i.FileName,
+		&i.SizeBytes,
+		&i.ContentHash,
+		&i.CurrentVersion,
+		&i.Metadata,
+		&i.DeletedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+	)
+	return i, err
+}
+
+const setDocumentThumbnailPath = `-- name: SetDocumentThumbnailPath :exec
+UPDATE documents
+SET thumbnail_path = $1
+WHERE id = $2
+`
+
+type SetDocumentThumbnailPathParams struct {
+	ThumbnailPath sql.NullString `json:"thumbnailPath"`
+	ID            int32          `json:"id"`
+}
+
+func (q *Queries) SetDocumentThumbnailPath(ctx context.Context, arg SetDocumentThumbnailPathParams) error {
+	_, err := q.db.ExecContext(ctx, setDocumentThumbnailPath, arg.ThumbnailPath, arg.ID)
+	return err
+}
+
+const trashDocument = `-- name: TrashDocument :exec
+UPDATE documents
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1
+  AND deleted_at IS NULL
+`
+
+// Move a document to the trash, it stays there until it is restored or purged
+func (q *Queries) TrashDocument(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, trashDocument, id)
+	return err
+}
+
+const updateDocumentDetails = `-- name: UpdateDocumentDetails :one
+UPDATE documents
+SET name        = $1,
+    description = $2,
+    metadata    = $3
+WHERE id = $4
+RETURNING id, created_at, name, text, file_path, embedding, account_id, page_count, source_metadata, thumbnail_path, description, file_name, size_bytes, content_hash, current_version, metadata, deleted_at, data_key, data_key_id
+`
+
+type UpdateDocumentDetailsParams struct {
+	Name        string          `json:"name"`
+	Description sql.NullString  `json:"description"`
+	Metadata    json.RawMessage `json:"metadata"`
+	ID          int32           `json:"id"`
+}
+
+func (q *Queries) UpdateDocumentDetails(ctx context.Context, arg UpdateDocumentDetailsParams) (Document, error) {
+	row := q.db.QueryRowContext(ctx, updateDocumentDetails,
+		arg.Name,
+		arg.Description,
+		arg.Metadata,
+		arg.ID,
+	)
+	var i Document
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Name,
+		&i.Text,
+		&i.FilePath,
+		&i.Embedding,
+		&i.AccountID,
+		&i.PageCount,
+		&i.SourceMetadata,
+		&i.ThumbnailPath,
+		&i.Description,
+		&i.FileName,
+		&i.SizeBytes,
+		&i.ContentHash,
+		&i.CurrentVersion,
+		&i.Metadata,
+		&i.DeletedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+	)
+	return i, err
+}
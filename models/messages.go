@@ -1,10 +1,12 @@
 package models
 
 import (
+	"database/sql"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Sender string
@@ -35,16 +37,133 @@ type Message struct {
 	Timestamp time.Time `json:"timestamp"`
 	Sender    Sender    `json:"sender"`
 	Text      string    `json:"text"`
+	// ParentID is the message this message follows, empty for the first message of a chat.
+	ParentID string `json:"parentId,omitempty"`
+	// SiblingIDs are the messages following the same parent, the oldest first and this message
+	// included, when the conversation branches at this message.
+	SiblingIDs []string `json:"siblingIds,omitempty"`
+	// Citations are the passages of documents an assistant message is based on.
+	Citations []Citation `json:"citations,omitempty"`
+	// Metadata describes how the assistant wrote the message.
+	Metadata *MessageMetadata `json:"metadata,omitempty"`
+}
+
+// Citation is a passage of a document cited by an assistant message. The page or the chunk of the
+// document the passage comes from is given when the assistant knows it.
+type Citation struct {
+	DocumentID int32    `json:"documentId"`
+	PageNumber *int32   `json:"pageNumber,omitempty"`
+	ChunkIndex *int32   `json:"chunkIndex,omitempty"`
+	Quote      string   `json:"quote,omitempty"`
+	Score      *float64 `json:"score,omitempty"`
+}
+
+// MessageMetadata describes how the assistant wrote a message: the model used, the tokens it took
+// and how long it took in milliseconds.
+type MessageMetadata struct {
+	Model            string `json:"model,omitempty"`
+	PromptTokens     *int32 `json:"promptTokens,omitempty"`
+	CompletionTokens *int32 `json:"completionTokens,omitempty"`
+	LatencyMs        *int32 `json:"latencyMs,omitempty"`
+}
+
+// ToMessage returns a stored message in the shape messages are sent to clients and the assistant.
+func (message ChatMessage) ToMessage() Message {
+	var parentID string
+	if message.ParentID.Valid {
+		parentID = message.ParentID.UUID.String()
+	}
+	return Message{
+		ID:        message.ID.String(),
+		Timestamp: message.CreatedAt,
+		Sender:    Sender(message.Sender),
+		Text:      message.Text,
+		ParentID:  parentID,
+		Metadata:  message.metadata(),
+	}
+}
+
+// metadata returns how the assistant wrote a message, nil for messages it did not describe.
+func (message ChatMessage) metadata() *MessageMetadata {
+	if !message.Model.Valid && !message.PromptTokens.Valid && !message.CompletionTokens.Valid && !message.LatencyMs.Valid {
+		return nil
+	}
+	return &MessageMetadata{
+		Model:            message.Model.String,
+		PromptTokens:     nullInt32Pointer(message.PromptTokens),
+		CompletionTokens: nullInt32Pointer(message.CompletionTokens),
+		LatencyMs:        nullInt32Pointer(message.LatencyMs),
+	}
+}
+
+// ToCitation returns a stored citation in the shape citations are sent to clients.
+func (citation ChatMessageCitation) ToCitation() Citation {
+	result := Citation{
+		DocumentID: citation.DocumentID,
+		PageNumber: nullInt32Pointer(citation.PageNumber),
+		ChunkIndex: nullInt32Pointer(citation.ChunkIndex),
+		Quote:      citation.Quote,
+	}
+	if citation.Score.Valid {
+		result.Score = &citation.Score.Float64
+	}
+	return result
 }
 
-func (chat *Chat) GetMessages() []Message {
-	var messages []Message
-	if !chat.Messages.Valid {
-		panic(errors.New("chat does not have messages"))
+func nullInt32Pointer(value sql.NullInt32) *int32 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Int32
+}
+
+// ToMessages returns stored messages in the shape messages are sent to clients and the assistant.
+func ToMessages(chatMessages []ChatMessage) []Message {
+	messages := make([]Message, len(chatMessages))
+	for i, chatMessage := range chatMessages {
+		messages[i] = chatMessage.ToMessage()
+	}
+	return messages
+}
+
+// WithSiblings returns stored messages along with the IDs of their siblings among the given ones.
+func WithSiblings(chatMessages []ChatMessage, siblings []ListChatMessageSiblingsRow) []Message {
+	type branchPoint struct {
+		chatID   int32
+		parentID uuid.NullUUID
+	}
+	siblingIDs := map[branchPoint][]string{}
+	for _, sibling := range siblings {
+		key := branchPoint{chatID: sibling.ChatID, parentID: sibling.ParentID}
+		siblingIDs[key] = append(siblingIDs[key], sibling.ID.String())
 	}
-	err := json.Unmarshal(chat.Messages.RawMessage, &messages)
-	if err != nil {
-		panic(err)
+
+	messages := ToMessages(chatMessages)
+	for i, chatMessage := range chatMessages {
+		ids := siblingIDs[branchPoint{chatID: chatMessage.ChatID, parentID: chatMessage.ParentID}]
+		if len(ids) > 1 {
+			messages[i].SiblingIDs = ids
+		}
 	}
 	return messages
 }
+
+// WithCitations adds their citations to messages.
+func WithCitations(messages []Message, citations []ChatMessageCitation) []Message {
+	citationsByMessage := map[string][]Citation{}
+	for _, citation := range citations {
+		messageID := citation.MessageID.String()
+		citationsByMessage[messageID] = append(citationsByMessage[messageID], citation.ToCitation())
+	}
+
+	for i := range messages {
+		messages[i].Citations = citationsByMessage[messages[i].ID]
+	}
+	return messages
+}
+
+// ChatWithMessages is a chat along with its messages, the way chats are returned by the API.
+type ChatWithMessages struct {
+	Chat
+	Messages []Message `json:"messages"`
+}
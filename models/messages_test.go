@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testUUID(n byte) uuid.UUID {
+	return uuid.UUID{15: n}
+}
+
+func child(parent uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: parent, Valid: true}
+}
+
+func TestSenderUnmarshalJSON(t *testing.T) {
+	for _, value := range []string{`"user"`, `"assistant"`, `"system"`} {
+		var sender Sender
+		if err := json.Unmarshal([]byte(value), &sender); err != nil || `"`+string(sender)+`"` != value {
+			t.Errorf("unmarshalling %s = %q, %v", value, sender, err)
+		}
+	}
+	for _, value := range []string{`"robot"`, `""`, `1`} {
+		var sender Sender
+		if err := json.Unmarshal([]byte(value), &sender); err == nil {
+			t.Errorf("unmarshalling %s succeeded with %q", value, sender)
+		}
+	}
+}
+
+func TestToMessage(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	root := ChatMessage{ID: testUUID(1), ChatID: 7, Sequence: 1, CreatedAt: createdAt, Sender: "user", Text: "Hi"}
+	reply := ChatMessage{ID: testUUID(2), ChatID: 7, Sequence: 2, CreatedAt: createdAt, Sender: "assistant", Text: "Hello", ParentID: child(root.ID)}
+
+	got := ToMessages([]ChatMessage{root, reply})
+	want := []Message{
+		{ID: root.ID.String(), Timestamp: createdAt, Sender: User, Text: "Hi"},
+		{ID: reply.ID.String(), Timestamp: createdAt, Sender: Assistant, Text: "Hello", ParentID: root.ID.String()},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToMessages() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWithSiblings(t *testing.T) {
+	// Chat 7: question 1 edited into 2, 1 answered by 3 and regenerated as 4, 2 answered by 5.
+	// Chat 8 starts with 6 alone.
+	question := ChatMessage{ID: testUUID(1), ChatID: 7, Sequence: 1}
+	edited := ChatMessage{ID: testUUID(2), ChatID: 7, Sequence: 2}
+	answer := ChatMessage{ID: testUUID(3), ChatID: 7, Sequence: 3, ParentID: child(question.ID)}
+	regenerated := ChatMessage{ID: testUUID(4), ChatID: 7, Sequence: 4, ParentID: child(question.ID)}
+	editedAnswer := ChatMessage{ID: testUUID(5), ChatID: 7, Sequence: 5, ParentID: child(edited.ID)}
+	otherChat := ChatMessage{ID: testUUID(6), ChatID: 8, Sequence: 1}
+
+	siblings := []ListChatMessageSiblingsRow{
+		{ID: question.ID, ChatID: 7},
+		{ID: edited.ID, ChatID: 7},
+		{ID: answer.ID, ChatID: 7, ParentID: answer.ParentID},
+		{ID: regenerated.ID, ChatID: 7, ParentID: regenerated.ParentID},
+		{ID: editedAnswer.ID, ChatID: 7, ParentID: editedAnswer.ParentID},
+		{ID: otherChat.ID, ChatID: 8},
+	}
+
+	tests := []struct {
+		name         string
+		chatMessages []ChatMessage
+		want         [][]string
+	}{
+		{
+			name:         "first branch",
+			chatMessages: []ChatMessage{question, regenerated},
+			want: [][]string{
+				{question.ID.String(), edited.ID.String()},
+				{answer.ID.String(), regenerated.ID.String()},
+			},
+		},
+		{
+			name:         "edited branch",
+			chatMessages: []ChatMessage{edited, editedAnswer},
+			want:         [][]string{{question.ID.String(), edited.ID.String()}, nil},
+		},
+		{
+			name:         "root of another chat",
+			chatMessages: []ChatMessage{otherChat},
+			want:         [][]string{nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := WithSiblings(tt.chatMessages, siblings)
+			if len(messages) != len(tt.chatMessages) {
+				t.Fatalf("WithSiblings() returned %d messages, want %d", len(messages), len(tt.chatMessages))
+			}
+			for i, message := range messages {
+				if message.ID != tt.chatMessages[i].ID.String() {
+					t.Errorf("messages[%d].ID = %s, want %s", i, message.ID, tt.chatMessages[i].ID)
+				}
+				if !reflect.DeepEqual(message.SiblingIDs, tt.want[i]) {
+					t.Errorf("messages[%d].SiblingIDs = %v, want %v", i, message.SiblingIDs, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestToMessageMetadata(t *testing.T) {
+	plain := ChatMessage{ID: testUUID(1), Sender: "user"}
+	if message := plain.ToMessage(); message.Metadata != nil {
+		t.Errorf("ToMessage().Metadata = %+v, want nil", message.Metadata)
+	}
+
+	described := ChatMessage{
+		ID:           testUUID(2),
+		Sender:       "assistant",
+		Model:        sql.NullString{String: "model-a", Valid: true},
+		PromptTokens: sql.NullInt32{Int32: 120, Valid: true},
+	}
+	metadata := described.ToMessage().Metadata
+	if metadata == nil || metadata.Model != "model-a" || metadata.PromptTokens == nil || *metadata.PromptTokens != 120 ||
+		metadata.CompletionTokens != nil || metadata.LatencyMs != nil {
+		t.Errorf("ToMessage().Metadata = %+v", metadata)
+	}
+}
+
+func TestWithCitations(t *testing.T) {
+	messages := []Message{{ID: testUUID(1).String()}, {ID: testUUID(2).String()}}
+	citations := []ChatMessageCitation{
+		{MessageID: testUUID(2), Position: 0, DocumentID: 10, Quote: "first"},
+		{MessageID: testUUID(2), Position: 1, DocumentID: 11, PageNumber: sql.NullInt32{Int32: 4, Valid: true}, Score: sql.NullFloat64{Float64: 0.5, Valid: true}},
+		{MessageID: testUUID(3), Position: 0, DocumentID: 12},
+	}
+
+	messages = WithCitations(messages, citations)
+	if messages[0].Citations != nil {
+		t.Errorf("messages[0].Citations = %+v, want none", messages[0].Citations)
+	}
+	got := messages[1].Citations
+	if len(got) != 2 || got[0].DocumentID != 10 || got[0].Quote != "first" || got[0].PageNumber != nil ||
+		got[1].DocumentID != 11 || got[1].PageNumber == nil || *got[1].PageNumber != 4 || got[1].Score == nil || *got[1].Score != 0.5 {
+		t.Errorf("messages[1].Citations = %+v", got)
+	}
+}
@@ -6,7 +6,10 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/sqlc-dev/pqtype"
 )
 
@@ -18,20 +21,156 @@ type Account struct {
 	PasswordHash string       `json:"-"`
 }
 
+type AccountMessageUsage struct {
+	AccountID    int32     `json:"accountId"`
+	Day          time.Time `json:"day"`
+	MessageCount int32     `json:"messageCount"`
+}
+
+type AccountUsage struct {
+	AccountID     int32 `json:"accountId"`
+	DocumentCount int32 `json:"documentCount"`
+	StoredBytes   int64 `json:"storedBytes"`
+	ChatCount     int32 `json:"chatCount"`
+}
+
 type Chat struct {
+	ID                  int32         `json:"id"`
+	CreatedAt           sql.NullTime  `json:"createdAt"`
+	AccountID           int32         `json:"accountId"`
+	UnreadMessages      sql.NullBool  `json:"unreadMessages"`
+	CollectionID        sql.NullInt32 `json:"collectionId"`
+	DeletedAt           sql.NullTime  `json:"deletedAt"`
+	LastMessageSequence int32         `json:"lastMessageSequence"`
+	ActiveMessageID     uuid.NullUUID `json:"activeMessageId"`
+	Title               string        `json:"title"`
+	TitleEdited         bool          `json:"titleEdited"`
+	Pinned              bool          `json:"pinned"`
+	Archived            bool          `json:"archived"`
+	UpdatedAt           time.Time     `json:"updatedAt"`
+}
+
+type ChatDocument struct {
+	ChatID     int32        `json:"chatId"`
+	DocumentID int32        `json:"documentId"`
+	AttachedAt sql.NullTime `json:"attachedAt"`
+}
+
+type ChatMessage struct {
+	ID               uuid.UUID      `json:"id"`
+	ChatID           int32          `json:"chatId"`
+	Sequence         int32          `json:"sequence"`
+	CreatedAt        time.Time      `json:"createdAt"`
+	Sender           string         `json:"sender"`
+	Text             string         `json:"text"`
+	ParentID         uuid.NullUUID  `json:"parentId"`
+	Model            sql.NullString `json:"model"`
+	PromptTokens     sql.NullInt32  `json:"promptTokens"`
+	CompletionTokens sql.NullInt32  `json:"completionTokens"`
+	LatencyMs        sql.NullInt32  `json:"latencyMs"`
+}
+
+type ChatMessageCitation struct {
+	MessageID  uuid.UUID       `json:"messageId"`
+	Position   int32           `json:"position"`
+	DocumentID int32           `json:"documentId"`
+	PageNumber sql.NullInt32   `json:"pageNumber"`
+	ChunkIndex sql.NullInt32   `json:"chunkIndex"`
+	Quote      string          `json:"quote"`
+	Score      sql.NullFloat64 `json:"score"`
+}
+
+type ChatReply struct {
+	ReplyID   string       `json:"replyId"`
+	ChatID    int32        `json:"chatId"`
+	CreatedAt sql.NullTime `json:"createdAt"`
+}
+
+type Collection struct {
+	ID        int32         `json:"id"`
+	CreatedAt sql.NullTime  `json:"createdAt"`
+	Name      string        `json:"name"`
+	AccountID int32         `json:"accountId"`
+	ParentID  sql.NullInt32 `json:"parentId"`
+}
+
+type CollectionDocument struct {
+	CollectionID int32        `json:"collectionId"`
+	DocumentID   int32        `json:"documentId"`
+	AddedAt      sql.NullTime `json:"addedAt"`
+}
+
+type Document struct {
 	ID             int32                 `json:"id"`
 	CreatedAt      sql.NullTime          `json:"createdAt"`
-	Messages       pqtype.NullRawMessage `json:"messages"`
+	Name           string                `json:"name"`
+	Text           sql.NullString        `json:"text"`
+	FilePath       sql.NullString        `json:"filePath"`
+	Embedding      interface{}           `json:"embedding"`
 	AccountID      int32                 `json:"accountId"`
-	UnreadMessages sql.NullBool          `json:"unreadMessages"`
+	PageCount      sql.NullInt32         `json:"pageCount"`
+	SourceMetadata pqtype.NullRawMessage `json:"sourceMetadata"`
+	ThumbnailPath  sql.NullString        `json:"thumbnailPath"`
+	Description    sql.NullString        `json:"description"`
+	FileName       string                `json:"fileName"`
+	SizeBytes      sql.NullInt64         `json:"sizeBytes"`
+	ContentHash    sql.NullString        `json:"contentHash"`
+	CurrentVersion int32                 `json:"currentVersion"`
+	Metadata       json.RawMessage       `json:"metadata"`
+	DeletedAt      sql.NullTime          `json:"deletedAt"`
+	DataKey        []byte                `json:"-"`
+	DataKeyID      sql.NullString        `json:"-"`
 }
 
-type Document struct {
-	ID        int32          `json:"id"`
-	CreatedAt sql.NullTime   `json:"createdAt"`
-	Name      string         `json:"name"`
-	Text      sql.NullString `json:"text"`
-	FilePath  sql.NullString `json:"filePath"`
-	Embedding interface{}    `json:"embedding"`
-	AccountID int32          `json:"accountId"`
+type DocumentPage struct {
+	DocumentID int32  `json:"documentId"`
+	PageNumber int32  `json:"pageNumber"`
+	Text       string `json:"text"`
+}
+
+type DocumentShare struct {
+	DocumentID int32        `json:"documentId"`
+	AccountID  int32        `json:"accountId"`
+	Permission string       `json:"permission"`
+	CreatedAt  sql.NullTime `json:"createdAt"`
+}
+
+type DocumentTag struct {
+	DocumentID int32  `json:"documentId"`
+	Tag        string `json:"tag"`
+}
+
+type DocumentVersion struct {
+	ID            int32          `json:"id"`
+	CreatedAt     sql.NullTime   `json:"createdAt"`
+	DocumentID    int32          `json:"documentId"`
+	VersionNumber int32          `json:"versionNumber"`
+	FileName      string         `json:"fileName"`
+	FilePath      sql.NullString `json:"filePath"`
+	Text          sql.NullString `json:"text"`
+	ContentHash   sql.NullString `json:"contentHash"`
+	SizeBytes     sql.NullInt64  `json:"sizeBytes"`
+}
+
+type PendingBlobDeletion struct {
+	ID            int32          `json:"id"`
+	CreatedAt     sql.NullTime   `json:"createdAt"`
+	ObjectName    string         `json:"objectName"`
+	IsPrefix      bool           `json:"isPrefix"`
+	Attempts      int32          `json:"attempts"`
+	LastError     sql.NullString `json:"lastError"`
+	LastAttemptAt sql.NullTime   `json:"lastAttemptAt"`
+}
+
+type ShareLink struct {
+	ID            int32          `json:"id"`
+	CreatedAt     sql.NullTime   `json:"createdAt"`
+	TokenHash     string         `json:"-"`
+	AccountID     int32          `json:"accountId"`
+	DocumentID    sql.NullInt32  `json:"documentId"`
+	ChatID        sql.NullInt32  `json:"chatId"`
+	ExpiresAt     sql.NullTime   `json:"expiresAt"`
+	PasswordHash  sql.NullString `json:"-"`
+	AllowDownload bool           `json:"allowDownload"`
+	RevokedAt     sql.NullTime   `json:"revokedAt"`
 }
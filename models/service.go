@@ -15,13 +15,17 @@ type Config struct {
 	DBName     string
 }
 
-func NewQueryer(cfg Config) (*Queries, error) {
-	connStr := fmt.Sprintf(
+// ConnectionString returns the connection string of the database described by cfg.
+func ConnectionString(cfg Config) string {
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName,
 	)
+}
 
-	db, err := sql.Open("postgres", connStr)
+// NewDatabase opens a connection pool to the database and verifies it is reachable.
+func NewDatabase(cfg Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", ConnectionString(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("error opening database: %w", err)
 	}
@@ -39,5 +43,13 @@ func NewQueryer(cfg Config) (*Queries, error) {
 		return nil, fmt.Errorf("error connecting to the database: %w", err)
 	}
 
+	return db, nil
+}
+
+func NewQueryer(cfg Config) (*Queries, error) {
+	db, err := NewDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return New(db), nil
 }
@@ -21,9 +21,33 @@ type DocumentIndexingMessage struct {
 	DocumentText string `json:"document_text"`
 }
 
+// AIAssistantMessage asks the assistant to answer a chat. The answer comes back through
+// POST /internal/chats/:chatID/replies.
 type AIAssistantMessage struct {
-	ChatId   int32            `json:"chat_id"`
+	ChatId int32 `json:"chat_id"`
+	// Messages are the messages of the active branch of the chat.
 	Messages []models.Message `json:"messages"`
+	// DocumentIds restricts retrieval to these documents for chats scoped to a collection.
+	// It is null for chats over every document of the account.
+	DocumentIds []int32 `json:"document_ids"`
+	// SharedDocumentIds are the documents other accounts shared with the owner of the chat, which
+	// unscoped chats can use besides the documents of the account.
+	SharedDocumentIds []int32 `json:"shared_document_ids,omitempty"`
+	// ParentMessageId is the message to answer, the last of Messages. Given back as parentMessageId
+	// with the reply, the answer follows it even if the user switched to another branch meanwhile.
+	ParentMessageId string `json:"parent_message_id,omitempty"`
+	// AttachedDocumentIds are the documents the user attached to the chat, to be looked into first.
+	AttachedDocumentIds []int32 `json:"attached_document_ids,omitempty"`
+	// Passages are the pages of the attached documents that best match the question.
+	Passages []Passage `json:"passages,omitempty"`
+}
+
+// Passage is a page of a document attached to a chat, retrieved for the question being answered.
+type Passage struct {
+	DocumentId int32   `json:"document_id"`
+	PageNumber int32   `json:"page_number"`
+	Text       string  `json:"text"`
+	Score      float32 `json:"score"`
 }
 
 const DocumentIndexingTopicName = "DocumentIndexing"